@@ -5,29 +5,34 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/url"
+	"strings"
 )
 
 type AIStudioAPIRequest struct {
 	Config  *ProviderConfig
 	request AIStudioRequest
+	// usage is what the current response has added to the thread's usage.
+	usage ThreadUsage
 }
 
 func (p *AIStudioAPIRequest) Name() string {
 	return "aistudio." + p.Config.Name
 }
 func (p *AIStudioAPIRequest) Transport() GatewayTransport {
-	return TransportSSE
+	return p.Config.transport(TransportSSE)
 }
 
 func (p *AIStudioAPIRequest) PrepareForUpdates() {
+	p.usage = ThreadUsage{}
 }
 
 func (p *AIStudioAPIRequest) InitSession(thread *Thread) {
 	tools := []map[string]any{}
-	for k := range thread.Tools {
+	toolDefs := thread.toolDefinitions()
+	for k := range toolDefs {
 		tool := map[string]any{}
-		tool["description"] = thread.Tools[k].Description
-		tool["parameters"] = thread.Tools[k].Parameters
+		tool["description"] = toolDefs[k].Description
+		tool["parameters"] = toolDefs[k].Parameters
 		tool["name"] = k
 		tools = append(tools, tool)
 	}
@@ -44,6 +49,25 @@ func (p *AIStudioAPIRequest) InitSession(thread *Thread) {
 			ResponseSchema:   schema,
 		}
 	}
+	if thread.ImageOutput {
+		if p.request.GenerationConfig == nil {
+			p.request.GenerationConfig = &AIStudioGenerationConfig{}
+		}
+		p.request.GenerationConfig.ResponseModalities = []string{"TEXT", "IMAGE"}
+	}
+	if audio := thread.AudioOutput; audio != nil {
+		if p.request.GenerationConfig == nil {
+			p.request.GenerationConfig = &AIStudioGenerationConfig{}
+		}
+		p.request.GenerationConfig.ResponseModalities = []string{"AUDIO"}
+		if audio.Voice != "" {
+			p.request.GenerationConfig.SpeechConfig = &AIStudioSpeechConfig{
+				VoiceConfig: AIStudioVoiceConfig{
+					PrebuiltVoiceConfig: AIStudioPrebuiltVoiceConfig{VoiceName: audio.Voice},
+				},
+			}
+		}
+	}
 }
 
 func (p *AIStudioAPIRequest) Update(block *ThreadBlock) {
@@ -62,6 +86,46 @@ func (p *AIStudioAPIRequest) Update(block *ThreadBlock) {
 				{Text: block.Text},
 			},
 		})
+	case InferenceBlockInputImage:
+		if block.Image == nil {
+			return
+		}
+		p.appendUserPart(AIStudioPart{InlineData: &AIStudioBlob{
+			MimeType: block.Image.MediaType,
+			Data:     block.Image.GetBase64(),
+		}})
+	case InferenceBlockInputDocument:
+		if block.Document == nil {
+			return
+		}
+		p.appendUserPart(AIStudioPart{InlineData: &AIStudioBlob{
+			MimeType: block.Document.MediaType,
+			Data:     block.Document.GetBase64(),
+		}})
+	case InferenceBlockInputAudio:
+		if block.Audio == nil {
+			return
+		}
+		p.appendUserPart(AIStudioPart{InlineData: &AIStudioBlob{
+			MimeType: block.Audio.MediaType,
+			Data:     block.Audio.GetBase64(),
+		}})
+	case InferenceBlockOutputImage:
+		if block.Image == nil {
+			return
+		}
+		p.request.Contents = append(p.request.Contents, AIStudioContent{
+			Role: "model",
+			Parts: []AIStudioPart{
+				{
+					InlineData: &AIStudioBlob{
+						MimeType: block.Image.MediaType,
+						Data:     block.Image.GetBase64(),
+					},
+					ThoughtSignature: block.Signature,
+				},
+			},
+		})
 	case InferenceBlockSystem:
 		p.request.SystemInstruction = &AIStudioContent{
 			Parts: []AIStudioPart{
@@ -113,13 +177,32 @@ func (p *AIStudioAPIRequest) Update(block *ThreadBlock) {
 	}
 }
 
+// appendUserPart adds a part to the last user content if there is one, else
+// creates a new user content.
+func (p *AIStudioAPIRequest) appendUserPart(part AIStudioPart) {
+	if last := len(p.request.Contents) - 1; last >= 0 && p.request.Contents[last].Role == "user" {
+		p.request.Contents[last].Parts = append(p.request.Contents[last].Parts, part)
+		return
+	}
+	p.request.Contents = append(p.request.Contents, AIStudioContent{
+		Role:  "user",
+		Parts: []AIStudioPart{part},
+	})
+}
+
 func (p *AIStudioAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	method := ":generateContent"
+	if p.Config.streaming() {
+		method = ":streamGenerateContent"
+	}
 	modelsBase := p.Config.resolveEndpoint("/v1beta/models/")
-	endpoint, _ := url.JoinPath(modelsBase, thread.Model+":streamGenerateContent")
+	endpoint, _ := url.JoinPath(modelsBase, thread.Model+method)
 	u, _ := url.Parse(endpoint)
 	q := u.Query()
 	q.Set("key", p.Config.APIKey)
-	q.Set("alt", "sse")
+	if p.Config.streaming() {
+		q.Set("alt", "sse")
+	}
 	u.RawQuery = q.Encode()
 
 	body, _ := json.Marshal(p.request)
@@ -128,24 +211,95 @@ func (p *AIStudioAPIRequest) MakeRequest(thread *Thread) *http.Request {
 	return providerReq
 }
 
+// makeCountTokensRequest asks countTokens for the size of the request
+// MakeRequest would send.
+func (p *AIStudioAPIRequest) makeCountTokensRequest(thread *Thread) (*http.Request, error) {
+	if p.Config.Endpoint != "" {
+		return nil, ConfigurationError(p.Name(), "token counting needs BaseURL, not a custom Endpoint")
+	}
+	modelsBase := p.Config.resolveEndpoint("/v1beta/models/")
+	endpoint, _ := url.JoinPath(modelsBase, thread.Model+":countTokens")
+	u, _ := url.Parse(endpoint)
+	q := u.Query()
+	q.Set("key", p.Config.APIKey)
+	u.RawQuery = q.Encode()
+
+	body, _ := json.Marshal(AIStudioCountTokensRequest{
+		GenerateContentRequest: AIStudioCountTokensContents{
+			Model:           "models/" + thread.Model,
+			AIStudioRequest: p.request,
+		},
+	})
+	providerReq, _ := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	providerReq.Header.Set("Content-Type", "application/json")
+	return providerReq, nil
+}
+
+func (p *AIStudioAPIRequest) parseCountTokens(body []byte) (int64, error) {
+	var count AIStudioCountTokensResponse
+	if err := json.Unmarshal(body, &count); err != nil {
+		return 0, DecodingError(p.Name(), err.Error())
+	}
+	return count.TotalTokens, nil
+}
+
 func (p *AIStudioAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 	var chunk AIStudioGenerateContentResponse
 	if err := json.Unmarshal(data, &chunk); err != nil {
 		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
 	}
-	thread.Result.InputTokens += chunk.Usage.InputTokens
-	thread.Result.OutputTokens += chunk.Usage.OutputTokens
-	thread.Result.CacheReadTokens += chunk.Usage.CachedTokens
+	return p.onChunk(&chunk, thread)
+}
+
+// OnResponse handles a generateContent body, which has the same shape as a
+// single streamed chunk carrying the whole candidate.
+func (p *AIStudioAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	var chunk AIStudioGenerateContentResponse
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+	}
+	if result := p.onChunk(&chunk, thread); result.Error != nil {
+		return result
+	}
+	return DoneChunkResult()
+}
+
+// applyUsage adds the change in the response's usage to the thread, since
+// every chunk repeats the totals so far. Thoughts are billed as output.
+func (p *AIStudioAPIRequest) applyUsage(usage AIStudioUsageMetadata, thread *Thread) {
+	next := ThreadUsage{
+		InputTokens:       max(usage.InputTokens-usage.CachedTokens, 0),
+		CacheReadTokens:   usage.CachedTokens,
+		OutputTokens:      usage.OutputTokens + usage.ThinkingTokens,
+		ReasoningTokens:   usage.ThinkingTokens,
+		AudioInputTokens:  modalityTokens(usage.PromptDetails, "AUDIO"),
+		AudioOutputTokens: modalityTokens(usage.OutputDetails, "AUDIO"),
+	}
+	if next == (ThreadUsage{}) {
+		return
+	}
+	thread.Result = thread.Result.plus(next.since(p.usage))
+	p.usage = next
+}
+
+func modalityTokens(details []AIStudioModalityTokens, modality string) int64 {
+	total := int64(0)
+	for _, d := range details {
+		if d.Modality == modality {
+			total += d.TokenCount
+		}
+	}
+	return total
+}
+
+func (p *AIStudioAPIRequest) onChunk(chunk *AIStudioGenerateContentResponse, thread *Thread) ChunkResult {
+	p.applyUsage(chunk.Usage, thread)
 	thread.ThreadId = chunk.ResponseId
 
 	if len(chunk.Candidates) == 0 {
 		return AcceptedResult()
 	}
 	candidate := chunk.Candidates[0]
-	if candidate.FinishReason != nil {
-		thread.Complete(chunk.ResponseId)
-		return DoneChunkResult()
-	}
 	for i := range candidate.Content.Parts {
 		id := chunk.ResponseId
 		part := candidate.Content.Parts[i]
@@ -155,12 +309,54 @@ func (p *AIStudioAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 			} else {
 				thread.Text(id, part.Text)
 			}
+		} else if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/") {
+			if part.Thought {
+				// Draft images from the model's thinking.
+				continue
+			}
+			id := thread.NewBlockId(InferenceBlockOutputImage)
+			thread.OutputImageWithSignature(id, ThreadImage{
+				Base64:    part.InlineData.Data,
+				MediaType: part.InlineData.MimeType,
+			}, part.ThoughtSignature)
+			thread.Complete(id)
+		} else if part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "audio/") {
+			// Speech arrives as consecutive PCM parts of one clip.
+			thread.Audio(id+"-audio", ThreadAudio{
+				Base64:    part.InlineData.Data,
+				MediaType: part.InlineData.MimeType,
+			})
 		} else if part.FunctionCall != nil {
 			id := thread.NewBlockId(InferenceBlockToolCall)
 			fnCall := part.FunctionCall
 			thread.ToolCallWithThinking(id, fnCall.Name, string(fnCall.Args), "", part.ThoughtSignature)
 		}
 	}
+	if grounding := candidate.GroundingMetadata; grounding != nil {
+		for _, support := range grounding.GroundingSupports {
+			for _, idx := range support.GroundingChunkIndices {
+				if idx < 0 || idx >= len(grounding.GroundingChunks) || grounding.GroundingChunks[idx].Web == nil {
+					continue
+				}
+				web := grounding.GroundingChunks[idx].Web
+				thread.Cite(chunk.ResponseId, ThreadCitation{
+					Type:       CitationURL,
+					URL:        web.URI,
+					Title:      web.Title,
+					CitedText:  support.Segment.Text,
+					StartIndex: support.Segment.StartIndex,
+					EndIndex:   support.Segment.EndIndex,
+				})
+			}
+		}
+	}
+	// The final chunk may carry content alongside the finish reason.
+	if candidate.FinishReason != nil {
+		thread.stopReason = *candidate.FinishReason
+		thread.Complete(chunk.ResponseId)
+		thread.Complete(chunk.ResponseId + "-audio")
+		return DoneChunkResult()
+	}
 	return AcceptedResult()
 }
 
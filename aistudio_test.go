@@ -0,0 +1,209 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUnit_AIStudio_InlineDataInputs(t *testing.T) {
+	config := GoogleProvider("key")
+	p := &AIStudioAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Model = "gemini-2.5-flash"
+	thread.Input("Compare these")
+	thread.InputImageBase64("aW1n", "image/png")
+	thread.InputDocumentBase64("JVBERi0xLjQ=", "application/pdf", "report.pdf", false)
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	var req AIStudioRequest
+	json.Unmarshal(body, &req)
+	if len(req.Contents) != 1 || len(req.Contents[0].Parts) != 3 {
+		t.Fatalf("expected one user content with three parts, got %s", body)
+	}
+	image, doc := req.Contents[0].Parts[1].InlineData, req.Contents[0].Parts[2].InlineData
+	if image == nil || image.MimeType != "image/png" || image.Data != "aW1n" {
+		t.Errorf("unexpected image part %+v", image)
+	}
+	if doc == nil || doc.MimeType != "application/pdf" || doc.Data != "JVBERi0xLjQ=" {
+		t.Errorf("unexpected document part %+v", doc)
+	}
+}
+
+func TestUnit_AIStudio_SpeechOutput(t *testing.T) {
+	var req AIStudioRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AQACAAM="}}]}}]}`,
+			`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"audio/L16;codec=pcm;rate=24000","data":"AAQABQA="}}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":5,"candidatesTokenCount":40}}`,
+		))
+	}))
+	defer server.Close()
+
+	config := GoogleProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "gemini-2.5-flash-preview-tts"
+	session.Thread.AudioOutput = &AudioConfig{Voice: "Kore"}
+	session.Thread.Input("Say hello")
+	session.Thread.InputAudioBase64("AAAA", "audio/mp3")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	gen := req.GenerationConfig
+	if gen == nil || len(gen.ResponseModalities) != 1 || gen.ResponseModalities[0] != "AUDIO" || gen.SpeechConfig.VoiceConfig.PrebuiltVoiceConfig.VoiceName != "Kore" {
+		t.Errorf("unexpected generation config %+v", gen)
+	}
+	if parts := req.Contents[0].Parts; len(parts) != 2 || parts[1].InlineData == nil || parts[1].InlineData.MimeType != "audio/mp3" {
+		t.Errorf("expected audio input as inline data, got %+v", req.Contents)
+	}
+
+	out := result.Blocks[len(result.Blocks)-1]
+	if out.Type != InferenceBlockAudio || !out.Complete || out.Audio == nil {
+		t.Fatalf("expected a complete audio block, got %+v", out)
+	}
+	var wav bytes.Buffer
+	if err := out.Audio.WriteWAV(&wav); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if wav.Len() != 44+10 || !bytes.Equal(wav.Bytes()[44:], []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0}) {
+		t.Errorf("unexpected WAV data % x", wav.Bytes())
+	}
+}
+
+func TestUnit_AIStudio_ImageOutput(t *testing.T) {
+	var requests []AIStudioRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req AIStudioRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"A fox:"}]}}]}`,
+			`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"image/png","data":"ZHJhZnQ="},"thought":true}]}}]}`,
+			`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"inlineData":{"mimeType":"image/png","data":"Zm94"},"thoughtSignature":"sig"}]},"finishReason":"STOP"}]}`,
+		))
+	}))
+	defer server.Close()
+
+	config := GoogleProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "gemini-2.5-flash-image"
+	session.Thread.ImageOutput = true
+	session.Thread.Input("Draw a fox")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	if gen := requests[0].GenerationConfig; gen == nil || len(gen.ResponseModalities) != 2 || gen.ResponseModalities[1] != "IMAGE" {
+		t.Errorf("expected TEXT and IMAGE modalities, got %+v", gen)
+	}
+	if len(result.Blocks) != 3 {
+		t.Fatalf("expected input, text and one image, got %d blocks", len(result.Blocks))
+	}
+	image := result.Blocks[2]
+	if image.Type != InferenceBlockOutputImage || !image.Complete || image.Image.Base64 != "Zm94" || image.Image.MediaType != "image/png" || image.Signature != "sig" {
+		t.Errorf("unexpected image block %+v", image)
+	}
+
+	session.Thread.Input("Now in blue")
+	session.Stream(func(*Thread) {})
+	replayed := requests[1].Contents[2]
+	if replayed.Role != "model" || replayed.Parts[0].InlineData == nil || replayed.Parts[0].InlineData.Data != "Zm94" || replayed.Parts[0].ThoughtSignature != "sig" {
+		t.Errorf("unexpected replayed image %+v", replayed)
+	}
+}
+
+func TestUnit_AIStudio_GroundingCitations(t *testing.T) {
+	config := GoogleProvider("key")
+	p := &AIStudioAPIRequest{Config: &config}
+	thread := NewProviderState()
+	chunk := `{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"Spain won Euro 2024."}]},"finishReason":"STOP",
+		"groundingMetadata":{"webSearchQueries":["euro 2024 winner"],
+			"groundingChunks":[{"web":{"uri":"https://uefa.example/final","title":"uefa.example"}},{"web":{"uri":"https://news.example/euro","title":"news.example"}}],
+			"groundingSupports":[{"segment":{"startIndex":0,"endIndex":20,"text":"Spain won Euro 2024."},"groundingChunkIndices":[0,1]}]}}]}`
+	if result := p.OnChunk([]byte(chunk), thread); result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	citations := thread.Blocks[0].Citations
+	if len(citations) != 2 {
+		t.Fatalf("expected a citation per grounding chunk, got %+v", citations)
+	}
+	for i, url := range []string{"https://uefa.example/final", "https://news.example/euro"} {
+		c := citations[i]
+		if c.Type != CitationURL || c.URL != url || c.CitedText != "Spain won Euro 2024." || c.StartIndex != 0 || c.EndIndex != 20 {
+			t.Errorf("unexpected citation %+v", c)
+		}
+	}
+}
+
+func TestUnit_AIStudio_CountTokens(t *testing.T) {
+	var path, key string
+	var req AIStudioCountTokensRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, key = r.URL.Path, r.URL.Query().Get("key")
+		json.NewDecoder(r.Body).Decode(&req)
+		io.WriteString(w, `{"totalTokens":17}`)
+	}))
+	defer server.Close()
+
+	config := GoogleProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "gemini-2.5-flash"
+	session.Thread.Input("Hello")
+	count, err := session.CountTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 17 || path != "/v1beta/models/gemini-2.5-flash:countTokens" || key != "key" {
+		t.Errorf("unexpected count %d from %s", count, path)
+	}
+	if req.GenerateContentRequest.Model != "models/gemini-2.5-flash" || len(req.GenerateContentRequest.Contents) != 1 {
+		t.Errorf("unexpected count request %+v", req)
+	}
+
+	path = ""
+	config.Endpoint = server.URL + "/proxy/generate"
+	custom := config.Session()
+	custom.Thread.Model = "gemini-2.5-flash"
+	custom.Thread.Input("Hello")
+	if _, err := custom.CountTokens(); err == nil || path != "" {
+		t.Errorf("expected an error without a request for a custom endpoint, got %v from %q", err, path)
+	}
+}
+
+func TestUnit_AIStudio_UsageTotalsAndThoughts(t *testing.T) {
+	config := GoogleProvider("key")
+	p := &AIStudioAPIRequest{Config: &config}
+	thread := NewProviderState()
+	p.PrepareForUpdates()
+	chunks := []string{
+		`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}],"usageMetadata":{"promptTokenCount":30,"cachedContentTokenCount":10}}`,
+		`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]},"finishReason":"STOP"}],
+			"usageMetadata":{"promptTokenCount":30,"cachedContentTokenCount":10,"candidatesTokenCount":5,"thoughtsTokenCount":70,
+			"promptTokensDetails":[{"modality":"TEXT","tokenCount":10},{"modality":"AUDIO","tokenCount":20}]}}`,
+	}
+	for _, chunk := range chunks {
+		if result := p.OnChunk([]byte(chunk), thread); result.Error != nil {
+			t.Fatal(result.Error)
+		}
+	}
+	want := ThreadUsage{InputTokens: 20, CacheReadTokens: 10, OutputTokens: 75, ReasoningTokens: 70, AudioInputTokens: 20}
+	if thread.Result != want {
+		t.Errorf("expected the totals counted once, got %+v", thread.Result)
+	}
+}
@@ -8,6 +8,22 @@ type AIStudioRequest struct {
 	Tools             []AIStudioTools           `json:"tools"`
 	GenerationConfig  *AIStudioGenerationConfig `json:"generationConfig,omitempty"`
 }
+
+// AIStudioCountTokensRequest wraps a generateContent request for
+// countTokens, which needs the model inside it.
+type AIStudioCountTokensRequest struct {
+	GenerateContentRequest AIStudioCountTokensContents `json:"generateContentRequest"`
+}
+
+type AIStudioCountTokensContents struct {
+	Model string `json:"model"`
+	AIStudioRequest
+}
+
+type AIStudioCountTokensResponse struct {
+	TotalTokens int64 `json:"totalTokens"`
+}
+
 type AIStudioTools struct {
 	FunctionDeclarations []map[string]any `json:"functionDeclarations,omitempty"`
 }
@@ -23,20 +39,67 @@ type AIStudioErrorResponseError struct {
 	Code    int    `json:"code,omitempty"`
 	Message string `json:"message,omitempty"`
 }
+
+// AIStudioUsageMetadata counts are totals for the response so far; each
+// streamed chunk repeats them. candidatesTokenCount excludes the thoughts.
 type AIStudioUsageMetadata struct {
-	InputTokens    int64 `json:"promptTokenCount"`
-	OutputTokens   int64 `json:"candidatesTokenCount"`
-	CachedTokens   int64 `json:"cachedContentTokenCount"`
-	ThinkingTokens int64 `json:"thinkingTokenCount"`
+	InputTokens    int64                    `json:"promptTokenCount"`
+	OutputTokens   int64                    `json:"candidatesTokenCount"`
+	CachedTokens   int64                    `json:"cachedContentTokenCount"`
+	ThinkingTokens int64                    `json:"thoughtsTokenCount"`
+	PromptDetails  []AIStudioModalityTokens `json:"promptTokensDetails,omitempty"`
+	OutputDetails  []AIStudioModalityTokens `json:"candidatesTokensDetails,omitempty"`
+}
+type AIStudioModalityTokens struct {
+	Modality   string `json:"modality"`
+	TokenCount int64  `json:"tokenCount"`
 }
 type AIStudioGenerationConfig struct {
-	ResponseMimeType string      `json:"responseMimeType,omitempty"`
-	ResponseSchema   *JsonSchema `json:"responseSchema,omitempty"`
+	ResponseMimeType   string                `json:"responseMimeType,omitempty"`
+	ResponseSchema     *JsonSchema           `json:"responseSchema,omitempty"`
+	ResponseModalities []string              `json:"responseModalities,omitempty"`
+	SpeechConfig       *AIStudioSpeechConfig `json:"speechConfig,omitempty"`
+}
+type AIStudioSpeechConfig struct {
+	VoiceConfig AIStudioVoiceConfig `json:"voiceConfig"`
+}
+type AIStudioVoiceConfig struct {
+	PrebuiltVoiceConfig AIStudioPrebuiltVoiceConfig `json:"prebuiltVoiceConfig"`
+}
+type AIStudioPrebuiltVoiceConfig struct {
+	VoiceName string `json:"voiceName"`
 }
 type AIStudioCandidate struct {
-	Content      AIStudioContent `json:"content"`
-	FinishReason *string         `json:"finishReason,omitempty"`
-	Index        int             `json:"index,omitempty"`
+	Content           AIStudioContent            `json:"content"`
+	FinishReason      *string                    `json:"finishReason,omitempty"`
+	Index             int                        `json:"index,omitempty"`
+	GroundingMetadata *AIStudioGroundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+// AIStudioGroundingMetadata links segments of the answer to the web sources
+// that support them.
+type AIStudioGroundingMetadata struct {
+	GroundingChunks   []AIStudioGroundingChunk   `json:"groundingChunks,omitempty"`
+	GroundingSupports []AIStudioGroundingSupport `json:"groundingSupports,omitempty"`
+	WebSearchQueries  []string                   `json:"webSearchQueries,omitempty"`
+}
+type AIStudioGroundingChunk struct {
+	Web *AIStudioGroundingWeb `json:"web,omitempty"`
+}
+type AIStudioGroundingWeb struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
+}
+type AIStudioGroundingSupport struct {
+	Segment               AIStudioSegment `json:"segment"`
+	GroundingChunkIndices []int           `json:"groundingChunkIndices"`
+}
+
+// AIStudioSegment is a span of the response text in bytes.
+type AIStudioSegment struct {
+	StartIndex int    `json:"startIndex,omitempty"`
+	EndIndex   int    `json:"endIndex,omitempty"`
+	Text       string `json:"text,omitempty"`
 }
 type AIStudioContent struct {
 	Role  string         `json:"role,omitempty"`
@@ -48,6 +111,13 @@ type AIStudioPart struct {
 	Text             string                  `json:"text,omitempty"`
 	FunctionCall     *AIStudioFunctionCall   `json:"functionCall,omitempty"`
 	FunctionResult   *AIStudioFunctionResult `json:"functionResponse,omitempty"`
+	InlineData       *AIStudioBlob           `json:"inlineData,omitempty"`
+}
+
+// AIStudioBlob is inline binary data such as an image, PDF or audio.
+type AIStudioBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
 }
 type AIStudioFunctionCall struct {
 	Name string          `json:"name,omitempty"`
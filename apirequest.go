@@ -14,3 +14,11 @@ type APIRequest interface {
 	MakeRequest(state *Thread) *http.Request
 	OnChunk(data []byte, state *Thread) ChunkResult
 }
+
+// responseObserver is implemented by adapters whose Transport can return
+// TransportJSON. OnResponse handles the complete response body and must
+// produce the same blocks and usage as streaming the equivalent response
+// through OnChunk.
+type responseObserver interface {
+	OnResponse(data []byte, state *Thread) ChunkResult
+}
@@ -0,0 +1,162 @@
+package aikit
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// defaultPCMSampleRate is the rate OpenAI and Gemini use for raw PCM output.
+const defaultPCMSampleRate = 24000
+
+// EncodeWAV wraps 16-bit little-endian PCM samples in a WAV (RIFF) header.
+func EncodeWAV(pcm []byte, sampleRate int, channels int) []byte {
+	const bitsPerSample = 16
+	blockAlign := channels * bitsPerSample / 8
+	out := make([]byte, 44, 44+len(pcm))
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+len(pcm)))
+	copy(out[8:], "WAVE")
+	copy(out[12:], "fmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(out[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(out[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(out[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(out[34:], bitsPerSample)
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(len(pcm)))
+	return append(out, pcm...)
+}
+
+// Data returns the decoded audio bytes.
+func (a *ThreadAudio) Data() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(a.Base64)
+}
+
+// IsPCM reports whether the audio is raw 16-bit PCM without a container.
+func (a *ThreadAudio) IsPCM() bool {
+	mediaType, _, _ := mime.ParseMediaType(a.MediaType)
+	return mediaType == "audio/pcm" || mediaType == "audio/l16"
+}
+
+// IsWAV reports whether the audio is already a WAV file.
+func (a *ThreadAudio) IsWAV() bool {
+	mediaType, _, _ := mime.ParseMediaType(a.MediaType)
+	switch mediaType {
+	case "audio/wav", "audio/wave", "audio/x-wav", "audio/vnd.wave":
+		return true
+	}
+	return false
+}
+
+// Rate returns the PCM sample rate from the rate parameter of the media type
+// (e.g. "audio/L16;rate=24000"), SampleRate, or the 24kHz default.
+func (a *ThreadAudio) Rate() int {
+	if _, params, err := mime.ParseMediaType(a.MediaType); err == nil {
+		if rate, err := strconv.Atoi(params["rate"]); err == nil && rate > 0 {
+			return rate
+		}
+	}
+	if a.SampleRate > 0 {
+		return a.SampleRate
+	}
+	return defaultPCMSampleRate
+}
+
+// WAV returns the audio as a WAV file. WAV audio is returned as-is and mono
+// PCM gets a header added. Other formats (mp3, opus, ...) return an error
+// since they can't be converted without a decoder.
+func (a *ThreadAudio) WAV() ([]byte, error) {
+	data, err := a.Data()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case a.IsWAV():
+		return data, nil
+	case a.IsPCM():
+		return EncodeWAV(data, a.Rate(), 1), nil
+	}
+	return nil, fmt.Errorf("cannot convert %s audio to WAV", a.MediaType)
+}
+
+// WriteWAV writes the audio to w as a WAV file.
+func (a *ThreadAudio) WriteWAV(w io.Writer) error {
+	data, err := a.WAV()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// portable returns the base64 data and format name for APIs that only
+// accept containers (wav, mp3), wrapping raw PCM as WAV.
+func (a *ThreadAudio) portable() (string, string) {
+	if a.IsPCM() {
+		if wav, err := a.WAV(); err == nil {
+			return base64.StdEncoding.EncodeToString(wav), "wav"
+		}
+	}
+	return a.GetBase64(), a.format()
+}
+
+// format returns the short format name providers use for audio ("wav",
+// "mp3", "pcm16", ...).
+func (a *ThreadAudio) format() string {
+	switch {
+	case a.IsWAV():
+		return "wav"
+	case a.IsPCM():
+		return "pcm16"
+	}
+	mediaType, _, _ := mime.ParseMediaType(a.MediaType)
+	switch mediaType {
+	case "audio/mpeg", "audio/mp3":
+		return "mp3"
+	}
+	return strings.TrimPrefix(mediaType, "audio/")
+}
+
+// audioMediaType maps a provider format name back to a media type.
+func audioMediaType(format string) string {
+	switch format {
+	case "wav":
+		return "audio/wav"
+	case "mp3":
+		return "audio/mpeg"
+	case "pcm16", "pcm":
+		return "audio/pcm"
+	case "":
+		return ""
+	}
+	return "audio/" + format
+}
+
+// appendBase64 appends an independently encoded base64 chunk. Streamed
+// chunks each carry their own padding, so plain concatenation would corrupt
+// the data whenever a chunk isn't a multiple of three bytes. Instead the
+// padded final quantum is decoded and re-encoded together with the chunk.
+func (a *ThreadAudio) appendBase64(chunk string) {
+	if !strings.HasSuffix(a.Base64, "=") {
+		a.Base64 += chunk
+		return
+	}
+	tail, err := base64.StdEncoding.DecodeString(a.Base64[len(a.Base64)-4:])
+	if err != nil {
+		a.Base64 += chunk
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(chunk)
+	if err != nil {
+		a.Base64 += chunk
+		return
+	}
+	a.Base64 = a.Base64[:len(a.Base64)-4] + base64.StdEncoding.EncodeToString(append(tail, data...))
+}
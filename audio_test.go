@@ -0,0 +1,59 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"testing"
+)
+
+func TestUnit_Audio_AppendBase64AcrossPadding(t *testing.T) {
+	audio := &ThreadAudio{}
+	for _, chunk := range []string{"AQACAAM=", "AAQABQA=", "AQ=="} {
+		audio.appendBase64(chunk)
+	}
+	data, err := audio.Data()
+	if err != nil {
+		t.Fatalf("appended data is not valid base64: %v (%q)", err, audio.Base64)
+	}
+	want := []byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0, 1}
+	if !bytes.Equal(data, want) {
+		t.Errorf("got %v, want %v", data, want)
+	}
+}
+
+func TestUnit_Audio_WAVHeader(t *testing.T) {
+	pcm := []byte{1, 0, 2, 0, 3, 0, 4, 0}
+	audio := &ThreadAudio{
+		Base64:    base64.StdEncoding.EncodeToString(pcm),
+		MediaType: "audio/L16;codec=pcm;rate=16000",
+	}
+	wav, err := audio.WAV()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(wav) != 44+len(pcm) || string(wav[0:4]) != "RIFF" || string(wav[8:16]) != "WAVEfmt " || string(wav[36:40]) != "data" {
+		t.Fatalf("malformed header % x", wav[:44])
+	}
+	if size := binary.LittleEndian.Uint32(wav[4:]); size != uint32(36+len(pcm)) {
+		t.Errorf("unexpected RIFF size %d", size)
+	}
+	if rate := binary.LittleEndian.Uint32(wav[24:]); rate != 16000 {
+		t.Errorf("expected rate from media type, got %d", rate)
+	}
+	if byteRate := binary.LittleEndian.Uint32(wav[28:]); byteRate != 32000 {
+		t.Errorf("unexpected byte rate %d", byteRate)
+	}
+	if !bytes.Equal(wav[44:], pcm) {
+		t.Errorf("samples changed")
+	}
+
+	// WAV passes through, other containers can't be converted.
+	passthrough := &ThreadAudio{Base64: base64.StdEncoding.EncodeToString(wav), MediaType: "audio/wav"}
+	if out, err := passthrough.WAV(); err != nil || !bytes.Equal(out, wav) {
+		t.Errorf("expected WAV unchanged, got err=%v", err)
+	}
+	if _, err := (&ThreadAudio{Base64: "AAAA", MediaType: "audio/mpeg"}).WAV(); err == nil {
+		t.Errorf("expected an error for mp3")
+	}
+}
@@ -0,0 +1,190 @@
+package aikit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// AzureCompletionsAPIRequest routes Chat Completions requests through Azure
+// OpenAI deployments (/openai/deployments/{deployment}/chat/completions).
+// Models are mapped to deployments with ProviderConfig.Deployments and the
+// API version comes from ProviderConfig.APIVersion.
+type AzureCompletionsAPIRequest struct {
+	CompletionsAPIRequest
+}
+
+func (p *AzureCompletionsAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	deployment := p.Config.deployment(thread.Model)
+	p.request.Model = deployment
+	endpoint := p.Config.resolveEndpoint("/openai/deployments/" + url.PathEscape(deployment) + "/chat/completions")
+	return p.httpRequest(azureEndpoint(endpoint, p.Config.APIVersion))
+}
+
+func (p *AzureCompletionsAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
+	if err := p.contentFilter(data); err != nil {
+		return ErrorChunkResult(err)
+	}
+	return p.CompletionsAPIRequest.OnChunk(data, thread)
+}
+
+func (p *AzureCompletionsAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	if err := p.contentFilter(data); err != nil {
+		return ErrorChunkResult(err)
+	}
+	return p.CompletionsAPIRequest.OnResponse(data, thread)
+}
+
+// contentFilter inspects a chunk or full response for filtered prompts and
+// completions. Both carry the same prompt_filter_results and choice fields.
+func (p *AzureCompletionsAPIRequest) contentFilter(data []byte) *AIError {
+	var chunk AzureCompletionsStreamChunk
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil
+	}
+	for _, prompt := range chunk.PromptFilterResults {
+		if msg := azureContentFilterMessage("prompt", prompt.ContentFilterResults); msg != "" {
+			return ContentFilterError(p.Name(), msg)
+		}
+	}
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != nil && *choice.FinishReason == "content_filter" {
+			msg := azureContentFilterMessage("completion", choice.ContentFilterResults)
+			if msg == "" {
+				msg = "completion was filtered"
+			}
+			return ContentFilterError(p.Name(), msg)
+		}
+	}
+	return nil
+}
+
+func (p *AzureCompletionsAPIRequest) ParseHttpError(code int, body []byte) *AIError {
+	if err := azureParseHttpError(p.Name(), body); err != nil {
+		return err
+	}
+	return p.CompletionsAPIRequest.ParseHttpError(code, body)
+}
+
+// AzureResponsesAPIRequest routes Responses API requests through the Azure
+// OpenAI v1 endpoint (/openai/v1/responses). The deployment name is sent as
+// the model.
+type AzureResponsesAPIRequest struct {
+	ResponsesAPIRequest
+}
+
+func (p *AzureResponsesAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	p.Request.Model = p.Config.deployment(thread.Model)
+	endpoint := p.Config.resolveEndpoint("/openai/v1/responses")
+	return p.httpRequest(azureEndpoint(endpoint, p.Config.APIVersion))
+}
+
+func (p *AzureResponsesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
+	var event AzureResponsesStreamEvent
+	if err := json.Unmarshal(data, &event); err == nil {
+		switch event.Type {
+		case "response.incomplete":
+			if event.Response != nil && event.Response.IncompleteDetails != nil &&
+				event.Response.IncompleteDetails.Reason == "content_filter" {
+				return ErrorChunkResult(ContentFilterError(p.Name(), "response was filtered"))
+			}
+		case "error", "response.failed":
+			apiErr := event.Error
+			if apiErr == nil && event.Response != nil {
+				apiErr = event.Response.Error
+			}
+			if apiErr != nil && apiErr.Code == "content_filter" {
+				return ErrorChunkResult(azureContentFilterError(p.Name(), apiErr))
+			}
+		}
+	}
+	return p.ResponsesAPIRequest.OnChunk(data, thread)
+}
+
+func (p *AzureResponsesAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	var resp AzureResponsesResponse
+	if err := json.Unmarshal(data, &resp); err == nil {
+		if resp.IncompleteDetails != nil && resp.IncompleteDetails.Reason == "content_filter" {
+			return ErrorChunkResult(ContentFilterError(p.Name(), "response was filtered"))
+		}
+		if resp.Error != nil && resp.Error.Code == "content_filter" {
+			return ErrorChunkResult(azureContentFilterError(p.Name(), resp.Error))
+		}
+	}
+	return p.ResponsesAPIRequest.OnResponse(data, thread)
+}
+
+func (p *AzureResponsesAPIRequest) ParseHttpError(code int, body []byte) *AIError {
+	if err := azureParseHttpError(p.Name(), body); err != nil {
+		return err
+	}
+	return p.ResponsesAPIRequest.ParseHttpError(code, body)
+}
+
+func azureEndpoint(endpoint string, apiVersion string) string {
+	if apiVersion == "" {
+		return endpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		panic(err)
+	}
+	q := u.Query()
+	if q.Get("api-version") == "" {
+		q.Set("api-version", apiVersion)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// azureParseHttpError returns a content filter error when the body is an
+// Azure content management rejection, and nil otherwise.
+func azureParseHttpError(provider string, body []byte) *AIError {
+	var errResp AzureErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil {
+		return nil
+	}
+	if errResp.Error.Code != "content_filter" {
+		return nil
+	}
+	return azureContentFilterError(provider, &errResp.Error)
+}
+
+func azureContentFilterError(provider string, apiErr *AzureError) *AIError {
+	source := apiErr.Param
+	if source == "" {
+		source = "prompt"
+	}
+	msg := ""
+	if apiErr.InnerError != nil {
+		msg = azureContentFilterMessage(source, apiErr.InnerError.ContentFilterResult)
+	}
+	if msg == "" {
+		msg = apiErr.Message
+	}
+	return ContentFilterError(provider, msg)
+}
+
+// azureContentFilterMessage describes the filtered categories, e.g.
+// "prompt filtered: hate (high), jailbreak". Returns "" if nothing was filtered.
+func azureContentFilterMessage(source string, results AzureContentFilterResults) string {
+	categories := []string{}
+	for name, result := range results {
+		if !result.Filtered {
+			continue
+		}
+		if result.Severity != "" && result.Severity != "safe" {
+			categories = append(categories, fmt.Sprintf("%s (%s)", name, result.Severity))
+		} else {
+			categories = append(categories, name)
+		}
+	}
+	if len(categories) == 0 {
+		return ""
+	}
+	sort.Strings(categories)
+	return fmt.Sprintf("%s filtered: %s", source, strings.Join(categories, ", "))
+}
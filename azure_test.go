@@ -0,0 +1,119 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnit_Azure_CompletionsDeploymentRouting(t *testing.T) {
+	config := AzureOpenAIProvider("contoso", "secret")
+	config.Deployments = map[string]string{"gpt-4o": "prod-gpt4o"}
+	p := &AzureCompletionsAPIRequest{CompletionsAPIRequest{Config: &config}}
+	thread := &Thread{Model: "gpt-4o"}
+	p.InitSession(thread)
+
+	req := p.MakeRequest(thread)
+	expected := "https://contoso.openai.azure.com/openai/deployments/prod-gpt4o/chat/completions?api-version=2024-10-21"
+	if req.URL.String() != expected {
+		t.Errorf("unexpected URL:\ngot  %s\nwant %s", req.URL.String(), expected)
+	}
+	if req.Header.Get("api-key") != "secret" {
+		t.Errorf("expected api-key header, got %q", req.Header.Get("api-key"))
+	}
+	if req.Header.Get("Authorization") != "" {
+		t.Errorf("did not expect Authorization header, got %q", req.Header.Get("Authorization"))
+	}
+}
+
+func TestUnit_Azure_CompletionsEscapesDeployment(t *testing.T) {
+	config := AzureOpenAIProvider("contoso", "secret")
+	config.Deployments = map[string]string{"gpt-4o": "team a/gpt?4o"}
+	p := &AzureCompletionsAPIRequest{CompletionsAPIRequest{Config: &config}}
+	thread := &Thread{Model: "gpt-4o"}
+	p.InitSession(thread)
+
+	req := p.MakeRequest(thread)
+	expected := "https://contoso.openai.azure.com/openai/deployments/team%20a%2Fgpt%3F4o/chat/completions?api-version=2024-10-21"
+	if req.URL.String() != expected {
+		t.Errorf("unexpected URL:\ngot  %s\nwant %s", req.URL.String(), expected)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if !strings.Contains(string(body), `"model":"team a/gpt?4o"`) {
+		t.Errorf("expected the unescaped deployment as model, got %s", body)
+	}
+}
+
+func TestUnit_Azure_ResponsesUsesDeploymentAsModel(t *testing.T) {
+	config := AzureOpenAIResponsesProvider("contoso", "token")
+	config.AuthHeader = ""
+	config.APIVersion = "preview"
+	config.Deployments = map[string]string{"gpt-5-nano": "nano"}
+	p := &AzureResponsesAPIRequest{ResponsesAPIRequest{Config: &config}}
+	thread := &Thread{Model: "gpt-5-nano"}
+	p.InitSession(thread)
+
+	req := p.MakeRequest(thread)
+	if req.URL.String() != "https://contoso.openai.azure.com/openai/v1/responses?api-version=preview" {
+		t.Errorf("unexpected URL %s", req.URL.String())
+	}
+	if req.Header.Get("Authorization") != "Bearer token" {
+		t.Errorf("expected bearer auth, got %q", req.Header.Get("Authorization"))
+	}
+	body, _ := io.ReadAll(req.Body)
+	var decoded map[string]any
+	json.Unmarshal(body, &decoded)
+	if decoded["model"] != "nano" {
+		t.Errorf("expected deployment as model, got %v", decoded["model"])
+	}
+}
+
+func TestUnit_Azure_ContentFilterHttpError(t *testing.T) {
+	body := []byte(`{"error":{"message":"The response was filtered","param":"prompt","code":"content_filter","status":400,
+		"innererror":{"code":"ResponsibleAIPolicyViolation","content_filter_result":{
+			"hate":{"filtered":true,"severity":"high"},
+			"sexual":{"filtered":false,"severity":"safe"},
+			"jailbreak":{"filtered":true,"detected":true}}}}}`)
+	config := AzureOpenAIProvider("contoso", "secret")
+	p := &AzureCompletionsAPIRequest{CompletionsAPIRequest{Config: &config}}
+
+	err := p.ParseHttpError(400, body)
+	if err == nil || err.Category != AIErrorCategoryContentFilter {
+		t.Fatalf("expected content filter error, got %v", err)
+	}
+	if err.Message != "prompt filtered: hate (high), jailbreak" {
+		t.Errorf("unexpected message %q", err.Message)
+	}
+}
+
+func TestUnit_Azure_StreamedContentFilterFinish(t *testing.T) {
+	chunks := []string{
+		`{"id":"","choices":[],"prompt_filter_results":[{"prompt_index":0,"content_filter_results":{"hate":{"filtered":false,"severity":"safe"}}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Partial"}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"content_filter","content_filter_results":{"violence":{"filtered":true,"severity":"medium"}}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, c := range chunks {
+			io.WriteString(w, "data: "+c+"\n\n")
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	config := AzureOpenAIProvider("contoso", "secret")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "gpt-4o"
+	session.Thread.Input("Hello")
+
+	result := session.Stream(func(*Thread) {})
+	if result.Success {
+		t.Fatal("expected content filter failure")
+	}
+	if !strings.Contains(result.Error, "completion filtered: violence (medium)") {
+		t.Errorf("unexpected error %q", result.Error)
+	}
+}
@@ -0,0 +1,51 @@
+package aikit
+
+type AzureErrorResponse struct {
+	Error AzureError `json:"error"`
+}
+type AzureError struct {
+	Message    string                `json:"message"`
+	Code       string                `json:"code,omitempty"`
+	Param      string                `json:"param,omitempty"`
+	InnerError *AzureErrorInnerError `json:"innererror,omitempty"`
+}
+type AzureErrorInnerError struct {
+	Code                string                    `json:"code,omitempty"`
+	ContentFilterResult AzureContentFilterResults `json:"content_filter_result,omitempty"`
+}
+
+// AzureContentFilterResults maps a filter category (hate, sexual, violence,
+// self_harm, jailbreak, protected_material_text, ...) to its verdict.
+type AzureContentFilterResults map[string]AzureContentFilterResult
+
+type AzureContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"`
+	Detected bool   `json:"detected,omitempty"`
+}
+
+type AzureCompletionsStreamChunk struct {
+	PromptFilterResults []AzurePromptFilterResult `json:"prompt_filter_results,omitempty"`
+	Choices             []AzureCompletionsChoice  `json:"choices"`
+}
+type AzurePromptFilterResult struct {
+	PromptIndex          int                       `json:"prompt_index"`
+	ContentFilterResults AzureContentFilterResults `json:"content_filter_results"`
+}
+type AzureCompletionsChoice struct {
+	FinishReason         *string                   `json:"finish_reason,omitempty"`
+	ContentFilterResults AzureContentFilterResults `json:"content_filter_results,omitempty"`
+}
+
+type AzureResponsesStreamEvent struct {
+	Type     string                  `json:"type"`
+	Response *AzureResponsesResponse `json:"response,omitempty"`
+	Error    *AzureError             `json:"error,omitempty"`
+}
+type AzureResponsesResponse struct {
+	IncompleteDetails *AzureIncompleteDetails `json:"incomplete_details,omitempty"`
+	Error             *AzureError             `json:"error,omitempty"`
+}
+type AzureIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
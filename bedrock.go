@@ -0,0 +1,163 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// BedrockAPIRequest implements Anthropic models on AWS Bedrock via
+// InvokeModelWithResponseStream. The request and stream payloads use the
+// Messages API shape, so block handling is delegated to MessagesAPIRequest
+// and the resulting Thread matches what the Messages adapter produces.
+// Requests are signed with SigV4 using Config.Credentials, falling back to
+// the standard AWS environment variables.
+type BedrockAPIRequest struct {
+	Config *ProviderConfig
+
+	messages MessagesAPIRequest
+	now      func() time.Time
+}
+
+func (p *BedrockAPIRequest) Name() string {
+	return fmt.Sprintf("bedrock.%s", p.Config.Name)
+}
+
+func (p *BedrockAPIRequest) Transport() GatewayTransport {
+	return p.Config.transport(TransportEventStream)
+}
+
+func (p *BedrockAPIRequest) PrepareForUpdates() {
+	p.messages.PrepareForUpdates()
+}
+
+func (p *BedrockAPIRequest) InitSession(thread *Thread) {
+	p.messages.Config = p.Config
+	p.messages.InitSession(thread)
+}
+
+func (p *BedrockAPIRequest) Update(block *ThreadBlock) {
+	p.messages.Update(block)
+}
+
+// requestBody converts the Messages request into Bedrock's body shape: the
+// model and stream flag move into the URL, and the API version and beta
+// features move into the body.
+func (p *BedrockAPIRequest) requestBody() []byte {
+	raw, _ := json.Marshal(p.messages.request)
+	var fields map[string]json.RawMessage
+	json.Unmarshal(raw, &fields)
+	delete(fields, "model")
+	delete(fields, "stream")
+
+	version := p.Config.APIVersion
+	if version == "" {
+		version = "bedrock-2023-05-31"
+	}
+	fields["anthropic_version"], _ = json.Marshal(version)
+
+	features := append([]string{}, p.Config.BetaFeatures...)
+	if p.messages.request.OutputFormat != nil {
+		features = append(features, "structured-outputs-2025-11-13")
+	}
+	if len(features) > 0 {
+		fields["anthropic_beta"], _ = json.Marshal(features)
+	}
+	body, _ := json.Marshal(fields)
+	return body
+}
+
+func (p *BedrockAPIRequest) endpoint(model string) string {
+	if strings.TrimSpace(p.Config.Endpoint) != "" {
+		return p.Config.resolveEndpoint("")
+	}
+	u, err := url.Parse(strings.TrimSpace(p.Config.BaseURL))
+	if err != nil {
+		panic(err)
+	}
+	action := "/invoke"
+	if p.Config.streaming() {
+		action = "/invoke-with-response-stream"
+	}
+	basePath := strings.TrimRight(u.Path, "/")
+	u.Path = basePath + "/model/" + model + action
+	u.RawPath = basePath + "/model/" + awsURIEncode(model) + action
+	return u.String()
+}
+
+func (p *BedrockAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	p.messages.prepareRequest(thread)
+	body := p.requestBody()
+	providerReq, _ := http.NewRequest("POST", p.endpoint(thread.Model), bytes.NewReader(body))
+	providerReq.Header.Set("Content-Type", "application/json")
+	if p.Config.streaming() {
+		providerReq.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		providerReq.Header.Set("Accept", "application/json")
+	}
+
+	creds := p.Config.Credentials
+	if creds == nil {
+		creds = AWSCredentialsFromEnv()
+	}
+	if creds != nil {
+		region := p.Config.Region
+		if region == "" {
+			region = AWSRegionFromEnv()
+		}
+		now := time.Now
+		if p.now != nil {
+			now = p.now
+		}
+		signAWSRequest(providerReq, body, creds, region, "bedrock", now())
+	}
+	return providerReq
+}
+
+func (p *BedrockAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
+	var chunk BedrockPayloadPart
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+	}
+	if len(chunk.Bytes) == 0 {
+		return AcceptedResult()
+	}
+	return p.messages.OnChunk(chunk.Bytes, thread)
+}
+
+// OnResponse handles an InvokeModel body, which is a plain Messages API
+// response.
+func (p *BedrockAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	return p.messages.OnResponse(data, thread)
+}
+
+func (p *BedrockAPIRequest) ParseHttpError(code int, body []byte) *AIError {
+	var errResp BedrockErrorResponse
+	message := string(body)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Message != "" {
+		message = errResp.Message
+	}
+	switch code {
+	case 401, 403:
+		return AuthenticationError(p.Name(), message)
+	case 400, 404:
+		return ConfigurationError(p.Name(), message)
+	case 429:
+		return RateLimitError(p.Name(), message)
+	}
+	return UnknownError(p.Name(), fmt.Sprintf("status %d: %s", code, message))
+}
+
+// BedrockPayloadPart is the payload of a "chunk" event. Bytes holds one
+// Messages API stream event (base64-encoded on the wire).
+type BedrockPayloadPart struct {
+	Bytes []byte `json:"bytes"`
+}
+
+type BedrockErrorResponse struct {
+	Message string `json:"message"`
+}
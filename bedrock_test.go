@@ -0,0 +1,190 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// encodeEventStreamMessage builds an AWS event-stream frame with string headers.
+func encodeEventStreamMessage(headers map[string]string, payload []byte) []byte {
+	var hdr bytes.Buffer
+	for name, value := range headers {
+		hdr.WriteByte(byte(len(name)))
+		hdr.WriteString(name)
+		hdr.WriteByte(7)
+		binary.Write(&hdr, binary.BigEndian, uint16(len(value)))
+		hdr.WriteString(value)
+	}
+	total := 12 + hdr.Len() + len(payload) + 4
+	var frame bytes.Buffer
+	binary.Write(&frame, binary.BigEndian, uint32(total))
+	binary.Write(&frame, binary.BigEndian, uint32(hdr.Len()))
+	binary.Write(&frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+	frame.Write(hdr.Bytes())
+	frame.Write(payload)
+	binary.Write(&frame, binary.BigEndian, crc32.ChecksumIEEE(frame.Bytes()))
+	return frame.Bytes()
+}
+
+func bedrockChunkFrame(event string) []byte {
+	payload := `{"bytes":"` + base64.StdEncoding.EncodeToString([]byte(event)) + `"}`
+	return encodeEventStreamMessage(map[string]string{
+		":message-type": "event",
+		":event-type":   "chunk",
+		":content-type": "application/json",
+	}, []byte(payload))
+}
+
+func TestUnit_SigV4_VanillaRequest(t *testing.T) {
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	creds := &AWSCredentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	signAWSRequest(req, nil, creds, "us-east-1", "service", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+
+	expected := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, " +
+		"Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != expected {
+		t.Errorf("Authorization mismatch:\ngot  %s\nwant %s", got, expected)
+	}
+}
+
+func TestUnit_SigV4_CanonicalURIDoubleEncodes(t *testing.T) {
+	config := ProviderConfig{BaseURL: "https://bedrock-runtime.us-east-1.amazonaws.com"}
+	p := &BedrockAPIRequest{Config: &config}
+	req, _ := http.NewRequest("POST", p.endpoint("anthropic.claude-v2:1"), nil)
+
+	if req.URL.EscapedPath() != "/model/anthropic.claude-v2%3A1/invoke-with-response-stream" {
+		t.Errorf("unexpected request path %q", req.URL.EscapedPath())
+	}
+	if got := awsCanonicalURI(req); got != "/model/anthropic.claude-v2%253A1/invoke-with-response-stream" {
+		t.Errorf("unexpected canonical URI %q", got)
+	}
+}
+
+func TestUnit_EventStream_DecodesFrames(t *testing.T) {
+	var stream bytes.Buffer
+	stream.Write(encodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "chunk"}, []byte("one")))
+	stream.Write(encodeEventStreamMessage(map[string]string{":message-type": "event", ":event-type": "chunk"}, []byte("two")))
+
+	payloads := []string{}
+	err := readEventStream("test", &stream, func(msg eventStreamMessage) (bool, error) {
+		payloads = append(payloads, string(msg.payload))
+		if msg.eventType() != "chunk" {
+			t.Errorf("expected chunk event, got %q", msg.eventType())
+		}
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(payloads, ",") != "one,two" {
+		t.Errorf("unexpected payloads %v", payloads)
+	}
+}
+
+func TestUnit_EventStream_RejectsBadChecksum(t *testing.T) {
+	frame := encodeEventStreamMessage(map[string]string{":message-type": "event"}, []byte("payload"))
+	frame[len(frame)-6] ^= 0xff
+
+	err := readEventStream("test", bytes.NewReader(frame), func(msg eventStreamMessage) (bool, error) {
+		t.Error("handler should not be called for a corrupt frame")
+		return true, nil
+	})
+	if err == nil {
+		t.Fatal("expected checksum error")
+	}
+}
+
+func TestUnit_Bedrock_StreamFromFakeEndpoint(t *testing.T) {
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" from Bedrock"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		`{"type":"message_stop"}`,
+	}
+
+	var gotPath, gotAuth, gotBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		buf := new(bytes.Buffer)
+		buf.ReadFrom(r.Body)
+		gotBody = buf.String()
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		for _, ev := range events {
+			w.Write(bedrockChunkFrame(ev))
+		}
+	}))
+	defer server.Close()
+
+	config := BedrockProvider("us-west-2", &AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "anthropic.claude-haiku-4-5-20251001-v1:0"
+	session.Thread.Input("Hi")
+
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if gotPath != "/model/anthropic.claude-haiku-4-5-20251001-v1%3A0/invoke-with-response-stream" {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if !strings.HasPrefix(gotAuth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(gotAuth, "/us-west-2/bedrock/aws4_request") {
+		t.Errorf("unexpected authorization header %q", gotAuth)
+	}
+	if !strings.Contains(gotBody, `"anthropic_version":"bedrock-2023-05-31"`) || strings.Contains(gotBody, `"model"`) {
+		t.Errorf("unexpected body %s", gotBody)
+	}
+
+	if len(result.Blocks) != 2 {
+		t.Fatalf("expected input and text blocks, got %d", len(result.Blocks))
+	}
+	text := result.Blocks[1]
+	if text.Type != InferenceBlockText || text.Text != "Hello from Bedrock" || !text.Complete {
+		t.Errorf("unexpected text block %+v", text)
+	}
+	if text.ProviderID != "bedrock.bedrock" {
+		t.Errorf("unexpected provider id %q", text.ProviderID)
+	}
+	if result.Result.InputTokens != 12 {
+		t.Errorf("expected 12 input tokens, got %d", result.Result.InputTokens)
+	}
+}
+
+func TestUnit_Bedrock_ExceptionFrame(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(encodeEventStreamMessage(map[string]string{
+			":message-type":   "exception",
+			":exception-type": "throttlingException",
+		}, []byte(`{"message":"Too many requests"}`)))
+	}))
+	defer server.Close()
+
+	config := BedrockProvider("us-east-1", &AWSCredentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "anthropic.claude-haiku-4-5-20251001-v1:0"
+	session.Thread.Input("Hi")
+
+	result := session.Stream(func(*Thread) {})
+	if result.Success {
+		t.Fatal("expected failure")
+	}
+	if !strings.Contains(result.Error, string(AIErrorCategoryRateLimit)) {
+		t.Errorf("expected rate limit error, got %q", result.Error)
+	}
+}
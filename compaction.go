@@ -0,0 +1,254 @@
+package aikit
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContextStrategy is a way of shrinking a thread that has grown past the
+// context window.
+type ContextStrategy string
+
+const (
+	// ContextTrimToolOutputs shortens large tool outputs in older turns.
+	ContextTrimToolOutputs ContextStrategy = "trim_tool_outputs"
+	// ContextDropOldest removes whole turns from the start of the thread.
+	// Tool calls are dropped together with their results.
+	ContextDropOldest ContextStrategy = "drop_oldest"
+	// ContextSummarize replaces older turns with a summary written by
+	// ContextManager.SummarySession.
+	ContextSummarize ContextStrategy = "summarize"
+)
+
+// DefaultSummaryPrompt is the system prompt of the summary session.
+const DefaultSummaryPrompt = "Summarize the conversation below so it can continue without it. Keep facts, decisions, open questions and tool results that are still relevant. Reply with the summary only."
+
+// ContextManager keeps a thread's prompt under a token budget. Before each
+// request Session.Stream estimates the prompt size and, once it passes
+// MaxTokens, applies Strategies in order until it fits TargetTokens.
+// Everything removed or changed is kept in Thread.Archive.
+type ContextManager struct {
+	// MaxTokens is the estimated prompt size that triggers compaction.
+	MaxTokens int
+	// TargetTokens is the size to shrink to. Defaults to 3/4 of MaxTokens.
+	TargetTokens int
+	// Strategies are tried in order. Defaults to trimming tool outputs, then
+	// dropping the oldest turns.
+	Strategies []ContextStrategy
+	// KeepRecentTurns is the number of latest turns that are never changed.
+	// A turn starts at a user input. Defaults to 1.
+	KeepRecentTurns int
+	// MaxToolOutputChars is the number of characters tool outputs are
+	// trimmed to.
+	// Defaults to 2000.
+	MaxToolOutputChars int
+
+	// SummarySession returns a configured session (provider and model) used
+	// to write summaries for ContextSummarize.
+	SummarySession func() *Session
+	// SummaryPrompt defaults to DefaultSummaryPrompt.
+	SummaryPrompt string
+
+	// Estimate returns the prompt size of a thread in tokens. Defaults to
+	// Thread.EstimateTokens.
+	Estimate func(*Thread) int
+}
+
+func (m *ContextManager) estimate(thread *Thread) int {
+	if m.Estimate != nil {
+		return m.Estimate(thread)
+	}
+	return thread.EstimateTokens("")
+}
+
+func (m *ContextManager) target() int {
+	if m.TargetTokens > 0 {
+		return m.TargetTokens
+	}
+	return m.MaxTokens * 3 / 4
+}
+
+func (m *ContextManager) strategies() []ContextStrategy {
+	if len(m.Strategies) > 0 {
+		return m.Strategies
+	}
+	return []ContextStrategy{ContextTrimToolOutputs, ContextDropOldest}
+}
+
+func (m *ContextManager) keepRecentTurns() int {
+	if m.KeepRecentTurns > 0 {
+		return m.KeepRecentTurns
+	}
+	return 1
+}
+
+// compact shrinks the thread if it is over MaxTokens. It reports whether
+// any block changed.
+func (m *ContextManager) compact(thread *Thread) bool {
+	if m.MaxTokens <= 0 || m.estimate(thread) <= m.MaxTokens {
+		return false
+	}
+	changed := false
+	for _, strategy := range m.strategies() {
+		switch strategy {
+		case ContextTrimToolOutputs:
+			changed = m.trimToolOutputs(thread) || changed
+		case ContextDropOldest:
+			changed = m.dropOldest(thread) || changed
+		case ContextSummarize:
+			changed = m.summarize(thread) || changed
+		}
+		if m.estimate(thread) <= m.target() {
+			break
+		}
+	}
+	return changed
+}
+
+// compactable returns the index of the first block in the turns that must
+// be kept; blocks before it may be changed.
+func (m *ContextManager) compactable(thread *Thread) int {
+	starts := turnStarts(thread.Blocks)
+	keep := m.keepRecentTurns()
+	if len(starts) <= keep {
+		return 0
+	}
+	return starts[len(starts)-keep]
+}
+
+func (m *ContextManager) trimToolOutputs(thread *Thread) bool {
+	limit := m.MaxToolOutputChars
+	if limit <= 0 {
+		limit = 2000
+	}
+	changed := false
+	end := m.compactable(thread)
+	for _, b := range thread.Blocks[:end] {
+		if b.ToolResult == nil || utf8.RuneCountInString(b.ToolResult.Output) <= limit {
+			continue
+		}
+		thread.Archive = append(thread.Archive, b.Clone())
+		output := b.ToolResult.Output
+		cut := 0
+		for range limit {
+			_, size := utf8.DecodeRuneInString(output[cut:])
+			cut += size
+		}
+		b.ToolResult.Output = fmt.Sprintf("%s\n[... %d characters trimmed]", output[:cut], utf8.RuneCountInString(output[cut:]))
+		changed = true
+		if m.estimate(thread) <= m.target() {
+			break
+		}
+	}
+	return changed
+}
+
+func (m *ContextManager) dropOldest(thread *Thread) bool {
+	changed := false
+	for m.estimate(thread) > m.target() {
+		starts := turnStarts(thread.Blocks)
+		if len(starts) <= m.keepRecentTurns() {
+			break
+		}
+		first, next := starts[0], starts[1]
+		thread.Archive = append(thread.Archive, thread.Blocks[first:next]...)
+		thread.Blocks = append(thread.Blocks[:first:first], thread.Blocks[next:]...)
+		changed = true
+	}
+	return changed
+}
+
+func (m *ContextManager) summarize(thread *Thread) bool {
+	if m.SummarySession == nil {
+		return false
+	}
+	end := m.compactable(thread)
+	starts := turnStarts(thread.Blocks)
+	if end == 0 || len(starts) == 0 {
+		return false
+	}
+	start := starts[0]
+	older := thread.Blocks[start:end]
+
+	session := m.SummarySession()
+	prompt := m.SummaryPrompt
+	if prompt == "" {
+		prompt = DefaultSummaryPrompt
+	}
+	session.Thread.System(prompt)
+	session.Thread.Input(transcript(older))
+	result := session.Stream(func(*Thread) {})
+	// The summary requests are billed to this thread as turns of their own.
+	thread.Result = thread.Result.plus(result.Result)
+	thread.Turns = append(thread.Turns, result.Turns...)
+	summary := ""
+	for _, b := range result.Blocks {
+		if b.Type == InferenceBlockText {
+			summary += b.Text
+		}
+	}
+	if !result.Success || summary == "" {
+		return false
+	}
+
+	block := thread.newBlock(thread.NewBlockId("summary"), InferenceBlockInput)
+	block.Text = "Summary of the earlier conversation:\n\n" + summary
+	thread.complete(block)
+	thread.Archive = append(thread.Archive, older...)
+	blocks := append([]*ThreadBlock{}, thread.Blocks[:start]...)
+	blocks = append(blocks, block)
+	thread.Blocks = append(blocks, thread.Blocks[end:]...)
+	return true
+}
+
+// turnStarts returns the index of each block that starts a turn: a user
+// input that doesn't directly follow another input.
+func turnStarts(blocks []*ThreadBlock) []int {
+	starts := []int{}
+	for i, b := range blocks {
+		if !b.isInput() {
+			continue
+		}
+		if i == 0 || !blocks[i-1].isInput() {
+			starts = append(starts, i)
+		}
+	}
+	return starts
+}
+
+func (b *ThreadBlock) isInput() bool {
+	switch b.Type {
+	case InferenceBlockInput, InferenceBlockInputImage, InferenceBlockInputDocument, InferenceBlockInputAudio:
+		return true
+	}
+	return false
+}
+
+// transcript renders blocks as plain text for the summary session.
+func transcript(blocks []*ThreadBlock) string {
+	var sb strings.Builder
+	for _, b := range blocks {
+		switch b.Type {
+		case InferenceBlockInput:
+			fmt.Fprintf(&sb, "User: %s\n\n", b.Text)
+		case InferenceBlockText:
+			fmt.Fprintf(&sb, "Assistant: %s\n\n", b.Text)
+		case InferenceBlockToolCall:
+			fmt.Fprintf(&sb, "Tool call %s(%s)\n", b.ToolCall.Name, b.ToolCall.Arguments)
+			if b.ToolResult != nil {
+				fmt.Fprintf(&sb, "Tool result: %s\n", b.ToolResult.Output)
+			}
+			sb.WriteString("\n")
+		case InferenceBlockThinking, InferenceBlockEncryptedThinking, InferenceBlockSystem:
+		default:
+			if b.Type == InferenceBlockWebSearch && b.WebSearch == nil {
+				continue
+			}
+			if d := strings.TrimSpace(b.Description()); d != "" {
+				fmt.Fprintf(&sb, "%s\n\n", d)
+			}
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
@@ -0,0 +1,178 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// compactionTestThread has three turns, each with a tool call and an answer.
+func compactionTestThread() *Thread {
+	thread := &Thread{}
+	thread.System("Be brief.")
+	for i, city := range []string{"Paris", "Rome", "Oslo"} {
+		thread.Input("Weather in " + city + "?")
+		id := "call_" + city
+		thread.ToolCall(id, "weather", `{"city":"`+city+`"}`)
+		thread.ToolResult(&ThreadToolCall{ID: id}, strings.Repeat(city+" is sunny. ", 100*(i+1)))
+		thread.Text("msg_"+city, city+" is sunny.")
+	}
+	return thread
+}
+
+func TestUnit_Context_TrimsOldToolOutputs(t *testing.T) {
+	thread := compactionTestThread()
+	recent := thread.Blocks[8].ToolResult.Output
+	manager := &ContextManager{
+		MaxTokens:          1000,
+		TargetTokens:       1200,
+		Strategies:         []ContextStrategy{ContextTrimToolOutputs},
+		MaxToolOutputChars: 100,
+	}
+	if !manager.compact(thread) {
+		t.Fatal("expected the thread to be compacted")
+	}
+	paris := thread.Blocks[2].ToolResult.Output
+	if !strings.HasPrefix(paris, "Paris is sunny.") || !strings.HasSuffix(paris, "[... 1500 characters trimmed]") {
+		t.Errorf("unexpected trimmed output %q", paris)
+	}
+	if thread.Blocks[8].ToolResult.Output != recent {
+		t.Errorf("the latest turn should not be trimmed")
+	}
+	if len(thread.Archive) != 2 || len(thread.Archive[0].ToolResult.Output) != 1600 {
+		t.Errorf("expected the original outputs to be archived, got %d blocks", len(thread.Archive))
+	}
+
+	// Limits and the marker count characters, not bytes.
+	thread = NewProviderState()
+	thread.Input("Describe it")
+	thread.ToolCall("call_1", "describe", `{}`)
+	thread.ToolResult(thread.Blocks[1].ToolCall, strings.Repeat("é", 150))
+	thread.Input("Thanks")
+	manager.trimToolOutputs(thread)
+	if got := thread.Blocks[1].ToolResult.Output; got != strings.Repeat("é", 100)+"\n[... 50 characters trimmed]" {
+		t.Errorf("unexpected trimmed output %q", got)
+	}
+}
+
+func TestUnit_Context_DropsOldestTurns(t *testing.T) {
+	thread := compactionTestThread()
+	manager := &ContextManager{MaxTokens: 10000, Strategies: []ContextStrategy{ContextDropOldest}}
+	if manager.compact(thread) {
+		// Under the limit nothing happens.
+		t.Fatal("unexpected compaction")
+	}
+
+	manager.MaxTokens = 1000
+	if !manager.compact(thread) {
+		t.Fatal("expected the thread to be compacted")
+	}
+	types := []ThreadBlockType{}
+	for _, b := range thread.Blocks {
+		types = append(types, b.Type)
+	}
+	want := []ThreadBlockType{InferenceBlockSystem, InferenceBlockInput, InferenceBlockToolCall, InferenceBlockText}
+	if len(types) != len(want) || types[1] != want[1] || types[2] != want[2] || thread.Blocks[2].ToolCall.ID != "call_Oslo" {
+		t.Errorf("expected the system prompt and the last turn, got %v", types)
+	}
+	if len(thread.Archive) != 6 || thread.Archive[0].Text != "Weather in Paris?" || thread.Archive[1].ToolResult == nil {
+		t.Errorf("expected the dropped turns in the archive, got %d blocks", len(thread.Archive))
+	}
+
+	restored := &Thread{}
+	restored.Restore(thread.Snapshot())
+	if len(restored.Archive) != 6 {
+		t.Errorf("archive not kept in snapshots")
+	}
+}
+
+func TestUnit_Context_IdsStayUniqueAfterCompaction(t *testing.T) {
+	thread := compactionTestThread()
+	manager := &ContextManager{MaxTokens: 1000, Strategies: []ContextStrategy{ContextDropOldest}}
+	if !manager.compact(thread) {
+		t.Fatal("expected the thread to be compacted")
+	}
+	thread.Input("And in Rome?")
+	thread.Text(thread.NewBlockId(InferenceBlockText), "Also sunny.")
+	thread.Input("Thanks")
+	thread.Input("Bye")
+
+	seen := map[string]bool{}
+	for _, b := range thread.Blocks {
+		if seen[b.ID] {
+			t.Errorf("duplicate block id %q in %v", b.ID, blockIDs(thread.Blocks))
+		}
+		seen[b.ID] = true
+	}
+}
+
+func TestUnit_Context_SummarizesThroughSecondarySession(t *testing.T) {
+	var requests []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		text := "It was sunny in Paris and Rome."
+		if req["model"] == "main-model" {
+			text = "Oslo is sunny too."
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":"`+text+`"},"finish_reason":"stop"}]}`,
+			`{"id":"c1","choices":[],"usage":{"prompt_tokens":100,"completion_tokens":10}}`,
+			`[DONE]`,
+		))
+	}))
+	defer server.Close()
+
+	config := OpenAICompletionsProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "main-model"
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	session.Thread.Clock = func() time.Time { return now }
+	session.Thread.Blocks = compactionTestThread().Blocks
+	session.Thread.Input("And Oslo again?")
+	session.Thread.ContextManager = &ContextManager{
+		MaxTokens:  300,
+		Strategies: []ContextStrategy{ContextSummarize},
+		SummarySession: func() *Session {
+			s := config.Session()
+			s.Thread.Model = "summary-model"
+			return s
+		},
+	}
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if len(requests) != 2 || requests[0]["model"] != "summary-model" {
+		t.Fatalf("expected a summary request first, got %d requests", len(requests))
+	}
+	summaryRequest, _ := json.Marshal(requests[0]["messages"])
+	summaryPrompt := string(summaryRequest)
+	if !strings.Contains(summaryPrompt, "User: Weather in Paris?") || !strings.Contains(summaryPrompt, "Tool call weather") {
+		t.Errorf("unexpected transcript %q", summaryPrompt)
+	}
+
+	messages := requests[1]["messages"].([]any)
+	if len(messages) != 3 {
+		t.Fatalf("expected system, summary and the latest input, got %v", messages)
+	}
+	if content, _ := json.Marshal(messages[1]); !strings.Contains(string(content), "It was sunny in Paris and Rome.") {
+		t.Errorf("unexpected summary message %s", content)
+	}
+	if len(result.Archive) != 9 {
+		t.Errorf("expected the summarized blocks to be archived, got %d", len(result.Archive))
+	}
+	if summary := result.Blocks[1]; !summary.CreatedAt.Equal(now) || !summary.CompletedAt.Equal(now) || !summary.Complete {
+		t.Errorf("expected the summary block to be stamped by the clock, got %+v", summary)
+	}
+	if len(result.Turns) != 2 || result.Result.InputTokens != 200 {
+		t.Errorf("expected the summary to be billed as its own turn, got %+v", result.Turns)
+	}
+}
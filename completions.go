@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
+	"unicode"
 )
 
 type CompletionsAPIRequest struct {
@@ -12,6 +15,21 @@ type CompletionsAPIRequest struct {
 
 	request  CompletionsRequest
 	lastTool string
+
+	// reasoning is thinking text waiting to be attached to the next
+	// assistant message when Config.ReasoningReplay is set.
+	reasoning string
+	// toolResults holds tool messages for the current assistant turn until
+	// a later block ends the turn. toolResponse is the response their calls
+	// came from.
+	toolResults  []CompletionsMessage
+	toolResponse int
+	// thinkTags splits inline <think> content per choice.
+	thinkTags map[string]*thinkTagParser
+	// audioMediaType is the media type of requested audio output.
+	audioMediaType string
+	// now is the thread's clock, for audio expiry.
+	now func() time.Time
 }
 
 func (p *CompletionsAPIRequest) Name() string {
@@ -19,107 +37,159 @@ func (p *CompletionsAPIRequest) Name() string {
 }
 
 func (p *CompletionsAPIRequest) Transport() GatewayTransport {
-	return TransportSSE
+	return p.Config.transport(TransportSSE)
 }
 
 func (p *CompletionsAPIRequest) PrepareForUpdates() {}
 
 func (p *CompletionsAPIRequest) InitSession(thread *Thread) {
 	tools := make([]map[string]any, 0)
-	for name := range thread.Tools {
+	toolDefs := thread.toolDefinitions()
+	for name := range toolDefs {
 		toolSpec := map[string]any{}
-		toolSpec["description"] = thread.Tools[name].Description
-		toolSpec["parameters"] = thread.Tools[name].Parameters
+		toolSpec["description"] = toolDefs[name].Description
+		toolSpec["parameters"] = toolDefs[name].Parameters
 		toolSpec["name"] = name
 		tools = append(tools, map[string]any{
 			"type":     "function",
 			"function": toolSpec,
 		})
 	}
+	p.reasoning = ""
+	p.toolResults = nil
+	p.toolResponse = 0
+	p.now = thread.now
 	p.request = CompletionsRequest{
-		Messages: []CompletionsMessage{},
-		Model:    thread.Model,
-		Tools:    tools,
-		Stream:   true,
-		StreamOptions: map[string]any{
-			"include_usage": true,
-		},
+		Messages:        []CompletionsMessage{},
+		Model:           thread.Model,
+		Tools:           tools,
 		ReasoningEffort: thread.Reasoning.Effort,
 	}
+	if p.Config.streaming() {
+		p.request.Stream = true
+		p.request.StreamOptions = map[string]any{
+			"include_usage": true,
+		}
+	}
 	if responseFormat := thread.StructuredOutputFormat(); responseFormat != nil {
 		p.request.ResponseFormat = responseFormat
 	}
+	p.audioMediaType = ""
+	if audio := thread.AudioOutput; audio != nil {
+		format := audio.Format
+		if format == "" {
+			// Streamed audio is only available as raw PCM.
+			format = "wav"
+			if p.request.Stream {
+				format = "pcm16"
+			}
+		}
+		p.request.Modalities = []string{"text", "audio"}
+		p.request.Audio = &CompletionsAudioConfig{Voice: audio.Voice, Format: format}
+		p.audioMediaType = audioMediaType(format)
+	}
 }
 
 func (p *CompletionsAPIRequest) Update(block *ThreadBlock) {
 	switch block.Type {
 	case InferenceBlockSystem:
+		p.flushToolResults()
 		p.request.Messages = append(p.request.Messages, CompletionsMessage{
 			Role:    "system",
 			Content: block.Text,
+			Name:    messageName(block.Author),
 		})
 	case InferenceBlockInput:
+		p.flushToolResults()
+		p.reasoning = ""
 		p.request.Messages = append(p.request.Messages, CompletionsMessage{
 			Role: "user",
 			Content: []CompletionTextBlock{
 				{Type: "text", Text: block.Text},
 			},
+			Name: messageName(block.Author),
 		})
 	case InferenceBlockInputImage:
 		if block.Image == nil {
 			return
 		}
-		imgBlock := CompletionImageUrlBlock{
+		p.appendUserPart(messageName(block.Author), CompletionImageUrlBlock{
 			Type: "image_url",
 			ImageUrl: CompletionImageUrlDetail{
 				Url: block.Image.GetDataURL(),
 			},
+		})
+	case InferenceBlockInputDocument:
+		if block.Document == nil {
+			return
 		}
-		// Append to last user message if exists, else create new
-		if len(p.request.Messages) > 0 {
-			lastIdx := len(p.request.Messages) - 1
-			if p.request.Messages[lastIdx].Role == "user" {
-				// Content is []CompletionTextBlock or could be []any
-				switch c := p.request.Messages[lastIdx].Content.(type) {
-				case []CompletionTextBlock:
-					// Convert to []any and append image
-					arr := make([]any, len(c))
-					for i, tb := range c {
-						arr[i] = tb
-					}
-					arr = append(arr, imgBlock)
-					p.request.Messages[lastIdx].Content = arr
-				case []any:
-					p.request.Messages[lastIdx].Content = append(c, imgBlock)
-				}
-				return
-			}
+		// Chat Completions has no portable document input, so the
+		// document is sent as extracted text.
+		p.appendUserPart(messageName(block.Author), CompletionTextBlock{
+			Type: "text",
+			Text: block.Document.textFallback(),
+		})
+	case InferenceBlockInputAudio:
+		if block.Audio == nil {
+			return
 		}
-		p.request.Messages = append(p.request.Messages, CompletionsMessage{
-			Role:    "user",
-			Content: []any{imgBlock},
+		data, format := block.Audio.portable()
+		p.appendUserPart(messageName(block.Author), CompletionInputAudioBlock{
+			Type:       "input_audio",
+			InputAudio: CompletionsInputAudio{Data: data, Format: format},
 		})
+	case InferenceBlockThinking:
+		if p.Config.ReasoningReplay != ReasoningReplayNone {
+			p.flushToolResults()
+			p.reasoning += block.Text
+		}
 	case InferenceBlockText:
-		p.request.Messages = append(p.request.Messages, CompletionsMessage{
-			Role: "assistant",
-			Content: []CompletionTextBlock{
-				{Type: "text", Text: block.Text},
-			},
-		})
+		p.appendAssistantText(block.Text)
+	case InferenceBlockAudio:
+		if block.Audio == nil {
+			return
+		}
+		// Our own audio is referenced by id until the provider forgets it.
+		// Anything else falls back to the transcript.
+		audio := block.Audio
+		expired := audio.ExpiresAt != 0 && p.now().Unix() >= audio.ExpiresAt
+		if block.ProviderID == p.Name() && audio.ID != "" && !expired {
+			p.flushToolResults()
+			ref := &CompletionsAudio{Id: audio.ID}
+			if last := p.lastAssistant(); last != nil && last.Audio == nil && len(last.ToolCalls) == 0 {
+				last.Audio = ref
+				return
+			}
+			p.appendAssistant(CompletionsMessage{Role: "assistant", Audio: ref})
+			return
+		}
+		p.appendAssistantText(audio.Transcript)
 	case InferenceBlockToolCall:
-		p.request.Messages = append(p.request.Messages, CompletionsMessage{
-			Role: "assistant",
-			ToolCalls: []CompletionsToolCall{{
-				Id:   block.ID,
-				Type: "function",
-				Function: &CompletionsToolCallFunction{
-					Name:      block.ToolCall.Name,
-					Arguments: block.ToolCall.Arguments,
-				},
-			}},
-		})
+		// Parallel calls share one assistant message, and their results
+		// follow as consecutive tool messages once the turn ends. A call
+		// from a later response starts a new turn after those results.
+		if block.Response != p.toolResponse {
+			p.flushToolResults()
+			p.toolResponse = block.Response
+		}
+		call := CompletionsToolCall{
+			Id:   block.ID,
+			Type: "function",
+			Function: &CompletionsToolCallFunction{
+				Name:      block.ToolCall.Name,
+				Arguments: block.ToolCall.Arguments,
+			},
+		}
+		if last := p.lastAssistant(); last != nil {
+			last.ToolCalls = append(last.ToolCalls, call)
+		} else {
+			p.appendAssistant(CompletionsMessage{
+				Role:      "assistant",
+				ToolCalls: []CompletionsToolCall{call},
+			})
+		}
 		if block.ToolResult != nil {
-			p.request.Messages = append(p.request.Messages, CompletionsMessage{
+			p.toolResults = append(p.toolResults, CompletionsMessage{
 				Role:       "tool",
 				Content:    string(block.ToolResult.Output),
 				Name:       block.ToolCall.Name,
@@ -129,13 +199,127 @@ func (p *CompletionsAPIRequest) Update(block *ThreadBlock) {
 	}
 }
 
+// messageName makes an author usable as a message name. OpenAI rejects
+// names containing whitespace or any of <|\/>, so those become underscores.
+func messageName(author string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || strings.ContainsRune(`<|\/>`, r) {
+			return '_'
+		}
+		return r
+	}, author)
+}
+
+// appendAssistantText adds text to the current assistant message, or starts
+// a new one once the message has tool calls.
+func (p *CompletionsAPIRequest) appendAssistantText(text string) {
+	if text == "" {
+		return
+	}
+	p.flushToolResults()
+	content := CompletionTextBlock{Type: "text", Text: text}
+	if last := p.lastAssistant(); last != nil && len(last.ToolCalls) == 0 {
+		if blocks, ok := last.Content.([]CompletionTextBlock); ok {
+			last.Content = append(blocks, content)
+			return
+		}
+		if last.Content == nil {
+			last.Content = []CompletionTextBlock{content}
+			return
+		}
+	}
+	p.appendAssistant(CompletionsMessage{
+		Role:    "assistant",
+		Content: []CompletionTextBlock{content},
+	})
+}
+
+// appendUserPart adds a content part to the last user message if there is
+// one from the same author, else creates a new user message.
+func (p *CompletionsAPIRequest) appendUserPart(name string, part any) {
+	p.flushToolResults()
+	if len(p.request.Messages) > 0 {
+		lastIdx := len(p.request.Messages) - 1
+		if p.request.Messages[lastIdx].Role == "user" && p.request.Messages[lastIdx].Name == name {
+			// Content is []CompletionTextBlock or could be []any
+			switch c := p.request.Messages[lastIdx].Content.(type) {
+			case []CompletionTextBlock:
+				// Convert to []any and append the part
+				arr := make([]any, len(c))
+				for i, tb := range c {
+					arr[i] = tb
+				}
+				arr = append(arr, part)
+				p.request.Messages[lastIdx].Content = arr
+			case []any:
+				p.request.Messages[lastIdx].Content = append(c, part)
+			}
+			return
+		}
+	}
+	p.request.Messages = append(p.request.Messages, CompletionsMessage{
+		Role:    "user",
+		Content: []any{part},
+		Name:    name,
+	})
+}
+
+// lastAssistant returns the trailing assistant message if the current turn
+// can still be merged into it.
+func (p *CompletionsAPIRequest) lastAssistant() *CompletionsMessage {
+	if len(p.request.Messages) == 0 || p.reasoning != "" {
+		return nil
+	}
+	last := &p.request.Messages[len(p.request.Messages)-1]
+	if last.Role != "assistant" {
+		return nil
+	}
+	return last
+}
+
+// appendAssistant adds an assistant message, attaching any pending reasoning
+// in the configured replay format.
+func (p *CompletionsAPIRequest) appendAssistant(msg CompletionsMessage) {
+	if p.reasoning != "" {
+		switch p.Config.ReasoningReplay {
+		case ReasoningReplayContent:
+			msg.ReasoningContent = p.reasoning
+		case ReasoningReplayField:
+			msg.Reasoning = p.reasoning
+		case ReasoningReplayThinkTags:
+			if blocks, ok := msg.Content.([]CompletionTextBlock); ok && len(blocks) > 0 {
+				blocks[0].Text = wrapThinkTags(p.reasoning, blocks[0].Text)
+			} else {
+				msg.Content = wrapThinkTags(p.reasoning, "")
+			}
+		}
+		p.reasoning = ""
+	}
+	p.request.Messages = append(p.request.Messages, msg)
+}
+
+// flushToolResults sends the pending tool results after their assistant
+// message.
+func (p *CompletionsAPIRequest) flushToolResults() {
+	p.request.Messages = append(p.request.Messages, p.toolResults...)
+	p.toolResults = nil
+}
+
 func (p *CompletionsAPIRequest) MakeRequest(thread *Thread) *http.Request {
-	endpoint := p.Config.resolveEndpoint("/v1/chat/completions")
+	return p.httpRequest(p.Config.resolveEndpoint("/v1/chat/completions"))
+}
+
+func (p *CompletionsAPIRequest) httpRequest(endpoint string) *http.Request {
+	p.flushToolResults()
 	body, _ := json.Marshal(p.request)
 	providerReq, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
 	providerReq.Header.Add("Content-Type", "application/json")
-	providerReq.Header.Add("Accept", "text/event-stream")
-	providerReq.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.Config.APIKey))
+	if p.request.Stream {
+		providerReq.Header.Add("Accept", "text/event-stream")
+	} else {
+		providerReq.Header.Add("Accept", "application/json")
+	}
+	p.Config.authorize(providerReq)
 	return providerReq
 }
 
@@ -144,7 +328,58 @@ func (p *CompletionsAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult
 	if err := json.Unmarshal(data, &chunk); err != nil {
 		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
 	}
+	return p.onChunk(&chunk, thread)
+}
+
+// OnResponse converts a non-streaming completion into a single chunk whose
+// deltas carry the full message, so both transports build identical threads.
+func (p *CompletionsAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	var resp CompletionsResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+	}
+	chunk := CompletionsStreamChunk{
+		Id:    resp.Id,
+		Usage: &resp.Usage,
+	}
+	for _, choice := range resp.Choices {
+		delta := CompletionsStreamDelta{
+			Role:             choice.Message.Role,
+			ReasoningContent: choice.Message.ReasoningContent,
+			Reasoning:        choice.Message.Reasoning,
+			Audio:            choice.Message.Audio,
+		}
+		if content, ok := choice.Message.Content.(string); ok {
+			delta.Content = content
+		}
+		for i, tc := range choice.Message.ToolCalls {
+			toolDelta := CompletionsToolCallDelta{Index: i, Id: tc.Id, Type: tc.Type}
+			if tc.Function != nil {
+				toolDelta.Function = &CompletionsToolCallFunctionDelta{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				}
+			}
+			delta.ToolCalls = append(delta.ToolCalls, toolDelta)
+		}
+		finishReason := choice.FinishReason
+		if finishReason == nil {
+			stop := "stop"
+			finishReason = &stop
+		}
+		chunk.Choices = append(chunk.Choices, CompletionsStreamChoice{
+			Index:        choice.Index,
+			Delta:        delta,
+			FinishReason: finishReason,
+		})
+	}
+	if result := p.onChunk(&chunk, thread); result.Error != nil {
+		return result
+	}
+	return DoneChunkResult()
+}
 
+func (p *CompletionsAPIRequest) onChunk(chunk *CompletionsStreamChunk, thread *Thread) ChunkResult {
 	thread.ThreadId = chunk.Id
 
 	if chunk.Usage != nil {
@@ -152,15 +387,32 @@ func (p *CompletionsAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult
 		thread.Result.InputTokens += nonCachedInput
 		thread.Result.OutputTokens += chunk.Usage.CompletionTokens
 		thread.Result.CacheReadTokens += chunk.Usage.PromptTokenDetails.CachedTokens
+		thread.Result.AudioInputTokens += chunk.Usage.PromptTokenDetails.AudioTokens
+		details := chunk.Usage.CompletionTokenDetails
+		thread.Result.ReasoningTokens += details.ReasoningTokens
+		thread.Result.AudioOutputTokens += details.AudioTokens
+		thread.Result.AcceptedPredictionTokens += details.AcceptedPredictionTokens
+		thread.Result.RejectedPredictionTokens += details.RejectedPredictionTokens
 	}
 
 	for _, choice := range chunk.Choices {
 		baseId := fmt.Sprintf("%s-%d", chunk.Id, choice.Index)
-		if choice.Delta.ReasoningContent != "" {
-			thread.Thinking(baseId+"-thinking", choice.Delta.ReasoningContent)
-		}
+		thread.Thinking(baseId+"-thinking", choice.Delta.ReasoningContent)
+		thread.Thinking(baseId+"-thinking", choice.Delta.Reasoning)
 		if choice.Delta.Content != "" {
-			thread.Text(baseId, choice.Delta.Content)
+			thinking, text := p.thinkTagParser(baseId).feed(choice.Delta.Content)
+			thread.Thinking(baseId+"-thinking", thinking)
+			thread.Text(baseId, text)
+		}
+
+		if audio := choice.Delta.Audio; audio != nil {
+			thread.Audio(baseId+"-audio", ThreadAudio{
+				Base64:     audio.Data,
+				MediaType:  p.audioMediaType,
+				ID:         audio.Id,
+				ExpiresAt:  audio.ExpiresAt,
+				Transcript: audio.Transcript,
+			})
 		}
 
 		for i := range choice.Delta.ToolCalls {
@@ -177,13 +429,33 @@ func (p *CompletionsAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult
 			}
 		}
 		if choice.FinishReason != nil {
+			thread.stopReason = *choice.FinishReason
+			if parser, ok := p.thinkTags[baseId]; ok {
+				thinking, text := parser.flush()
+				thread.Thinking(baseId+"-thinking", thinking)
+				thread.Text(baseId, text)
+				delete(p.thinkTags, baseId)
+			}
 			thread.Complete(baseId)
 			thread.Complete(baseId + "-thinking")
+			thread.Complete(baseId + "-audio")
 		}
 	}
 	return AcceptedResult()
 }
 
+func (p *CompletionsAPIRequest) thinkTagParser(id string) *thinkTagParser {
+	if p.thinkTags == nil {
+		p.thinkTags = map[string]*thinkTagParser{}
+	}
+	parser, ok := p.thinkTags[id]
+	if !ok {
+		parser = &thinkTagParser{}
+		p.thinkTags[id] = parser
+	}
+	return parser
+}
+
 func (p *CompletionsAPIRequest) ParseHttpError(code int, body []byte) *AIError {
 	var errResp CompletionsErrorResponse
 	if err := json.Unmarshal(body, &errResp); err == nil {
@@ -0,0 +1,399 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestUnit_ThinkTags_SplitAcrossChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		thinking string
+		text     string
+	}{
+		{"whole", []string{"<think>plan</think>\n\nanswer"}, "plan", "answer"},
+		{"split tags", []string{"<thi", "nk>pl", "an</th", "ink>", "\n\n", "ans", "wer"}, "plan", "answer"},
+		{"leading whitespace", []string{"\n<think>", "plan", "</think>answer"}, "plan", "answer"},
+		{"no tags", []string{"<", "b>bold</b>"}, "", "<b>bold</b>"},
+		{"unterminated", []string{"<think>still ", "going</"}, "still going</", ""},
+		{"tags mid text", []string{"answer <think>x</think>"}, "", "answer <think>x</think>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &thinkTagParser{}
+			thinking, text := "", ""
+			for _, chunk := range tt.chunks {
+				th, tx := parser.feed(chunk)
+				thinking += th
+				text += tx
+			}
+			th, tx := parser.flush()
+			thinking += th
+			text += tx
+			if thinking != tt.thinking || text != tt.text {
+				t.Errorf("got thinking=%q text=%q, want thinking=%q text=%q", thinking, text, tt.thinking, tt.text)
+			}
+		})
+	}
+}
+
+func TestUnit_Completions_InlineThinkTagsBecomeThinking(t *testing.T) {
+	config := GroqProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	chunks := []string{
+		`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":"<think>"}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"Two plus two.</th"}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"content":"ink>\n\nFour"}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}
+	for _, chunk := range chunks {
+		if result := p.OnChunk([]byte(chunk), thread); result.Error != nil {
+			t.Fatalf("unexpected error: %v", result.Error)
+		}
+	}
+	if len(thread.Blocks) != 2 {
+		t.Fatalf("expected thinking and text blocks, got %d", len(thread.Blocks))
+	}
+	if b := thread.Blocks[0]; b.Type != InferenceBlockThinking || b.Text != "Two plus two." || !b.Complete {
+		t.Errorf("unexpected thinking block %+v", b)
+	}
+	if b := thread.Blocks[1]; b.Type != InferenceBlockText || b.Text != "Four" || !b.Complete {
+		t.Errorf("unexpected text block %+v", b)
+	}
+}
+
+func TestUnit_Completions_ReasoningContentDelta(t *testing.T) {
+	config := GroqProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	p.OnChunk([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"reasoning_content":"deep"}}]}`), thread)
+	p.OnChunk([]byte(`{"id":"c1","choices":[{"index":0,"delta":{"reasoning":"seek"}}]}`), thread)
+	if len(thread.Blocks) != 1 || thread.Blocks[0].Text != "deepseek" {
+		t.Errorf("expected both reasoning fields in one thinking block, got %+v", thread.Blocks)
+	}
+}
+
+func TestUnit_Completions_ReasoningReplay(t *testing.T) {
+	tests := []struct {
+		mode   ReasoningReplay
+		expect func(t *testing.T, first map[string]any, second map[string]any)
+	}{
+		{ReasoningReplayNone, func(t *testing.T, first map[string]any, second map[string]any) {
+			if first["reasoning_content"] != nil || first["reasoning"] != nil || second["reasoning_content"] != nil {
+				t.Errorf("expected no reasoning, got %v / %v", first, second)
+			}
+		}},
+		{ReasoningReplayContent, func(t *testing.T, first map[string]any, second map[string]any) {
+			if first["reasoning_content"] != "first" || second["reasoning_content"] != "second" {
+				t.Errorf("expected reasoning_content, got %v / %v", first, second)
+			}
+		}},
+		{ReasoningReplayField, func(t *testing.T, first map[string]any, second map[string]any) {
+			if first["reasoning"] != "first" || second["reasoning"] != "second" {
+				t.Errorf("expected reasoning, got %v / %v", first, second)
+			}
+		}},
+		{ReasoningReplayThinkTags, func(t *testing.T, first map[string]any, second map[string]any) {
+			text := func(msg map[string]any) any {
+				return msg["content"].([]any)[0].(map[string]any)["text"]
+			}
+			if text(first) != "<think>first</think>\n\nLooking" || text(second) != "<think>second</think>\n\nDone" {
+				t.Errorf("unexpected content %q / %q", text(first), text(second))
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			config := GroqProvider("key")
+			config.ReasoningReplay = tt.mode
+			p := &CompletionsAPIRequest{Config: &config}
+			thread := NewProviderState()
+			thread.Model = "qwen3"
+			thread.Input("Hi")
+			thread.Thinking("t1", "first")
+			thread.Text("x1", "Looking")
+			thread.ToolCall("call_1", "lookup", "{}")
+			thread.ToolResult(thread.Blocks[3].ToolCall, "found")
+			thread.Thinking("t2", "second")
+			thread.Text("x2", "Done")
+
+			p.InitSession(thread)
+			for _, b := range thread.Blocks {
+				p.Update(b)
+			}
+			body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+			var req struct {
+				Messages []map[string]any `json:"messages"`
+			}
+			json.Unmarshal(body, &req)
+			if len(req.Messages) != 4 {
+				t.Fatalf("expected user, assistant, tool and assistant messages, got %s", body)
+			}
+			tt.expect(t, req.Messages[1], req.Messages[3])
+		})
+	}
+}
+
+// parallelToolThread is a turn with thinking, text and two parallel tool
+// calls, followed by a final answer and a new user message.
+func parallelToolThread() *Thread {
+	thread := NewProviderState()
+	thread.Model = "test-model"
+	thread.Input("Weather in Paris and Rome?")
+	thread.ThinkingWithSignature("think-1", "Two cities.", "sig")
+	thread.Text("text-1", "Checking both.")
+	thread.ToolCall("call_a", "weather", `{"city":"Paris"}`)
+	thread.ToolCall("call_b", "weather", `{"city":"Rome"}`)
+	thread.ToolResult(thread.Blocks[3].ToolCall, "sunny")
+	thread.ToolResult(thread.Blocks[4].ToolCall, "rainy")
+	thread.Text("text-2", "Paris is sunny, Rome is rainy.")
+	thread.Input("Thanks")
+	return thread
+}
+
+// sequentialToolThread has two calls made one after the other, each in its
+// own response, with no text between them.
+func sequentialToolThread() *Thread {
+	thread := NewProviderState()
+	thread.Model = "test-model"
+	thread.Input("Plan a day in Paris.")
+	thread.beginResponse()
+	thread.ToolCall("call_a", "weather", `{"city":"Paris"}`)
+	thread.endResponse()
+	thread.ToolResult(thread.Blocks[1].ToolCall, "sunny")
+	thread.beginResponse()
+	thread.ToolCall("call_b", "museums", `{"city":"Paris"}`)
+	thread.endResponse()
+	thread.ToolResult(thread.Blocks[2].ToolCall, "Louvre")
+	thread.beginResponse()
+	thread.Text("text-1", "Sunny, so walk to the Louvre.")
+	thread.endResponse()
+	return thread
+}
+
+// assertGoldenMessages compares the "messages" field of a request body with
+// the expected JSON, ignoring formatting.
+func assertGoldenMessages(t *testing.T, body []byte, golden string) {
+	t.Helper()
+	var got struct {
+		Messages any `json:"messages"`
+	}
+	var want any
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("invalid request body: %v", err)
+	}
+	if err := json.Unmarshal([]byte(golden), &want); err != nil {
+		t.Fatalf("invalid golden JSON: %v", err)
+	}
+	gotJSON, _ := json.Marshal(got.Messages)
+	wantJSON, _ := json.Marshal(want)
+	if string(gotJSON) != string(wantJSON) {
+		t.Errorf("messages differ:\ngot  %s\nwant %s", gotJSON, wantJSON)
+	}
+}
+
+func TestUnit_Completions_GroupsParallelToolCalls(t *testing.T) {
+	config := GroqProvider("key")
+	config.ReasoningReplay = ReasoningReplayField
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := parallelToolThread()
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Weather in Paris and Rome?"}]},
+		{"role":"assistant","reasoning":"Two cities.",
+		 "content":[{"type":"text","text":"Checking both."}],
+		 "tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}},
+			{"id":"call_b","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Rome\"}"}}]},
+		{"role":"tool","content":"sunny","tool_call_id":"call_a","name":"weather"},
+		{"role":"tool","content":"rainy","tool_call_id":"call_b","name":"weather"},
+		{"role":"assistant","content":[{"type":"text","text":"Paris is sunny, Rome is rainy."}]},
+		{"role":"user","content":[{"type":"text","text":"Thanks"}]}
+	]`)
+}
+
+func TestUnit_Completions_SequentialToolCalls(t *testing.T) {
+	config := GroqProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := sequentialToolThread()
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Plan a day in Paris."}]},
+		{"role":"assistant","tool_calls":[
+			{"id":"call_a","type":"function","function":{"name":"weather","arguments":"{\"city\":\"Paris\"}"}}]},
+		{"role":"tool","content":"sunny","tool_call_id":"call_a","name":"weather"},
+		{"role":"assistant","tool_calls":[
+			{"id":"call_b","type":"function","function":{"name":"museums","arguments":"{\"city\":\"Paris\"}"}}]},
+		{"role":"tool","content":"Louvre","tool_call_id":"call_b","name":"museums"},
+		{"role":"assistant","content":[{"type":"text","text":"Sunny, so walk to the Louvre."}]}
+	]`)
+}
+
+func TestUnit_Completions_DocumentTextFallback(t *testing.T) {
+	config := GroqProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Input("Summarize")
+	thread.InputDocument([]byte("Line one.\nLine two."), "text/plain", "notes.txt", false)
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[
+			{"type":"text","text":"Summarize"},
+			{"type":"text","text":"Document: notes.txt\n\nLine one.\nLine two."}]}
+	]`)
+}
+
+func TestUnit_Completions_AuthorNames(t *testing.T) {
+	config := GroqProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.InputFrom("alice", "Look at this")
+	thread.InputImageBase64("aGVsbG8=", "image/png")
+	thread.Blocks[1].Author = "alice"
+	thread.InputFrom("Bob Smith <ops/eu>", "Nice")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","name":"alice","content":[
+			{"type":"text","text":"Look at this"},
+			{"type":"image_url","image_url":{"url":"data:image/png;base64,aGVsbG8="}}]},
+		{"role":"user","name":"Bob_Smith__ops_eu_","content":[{"type":"text","text":"Nice"}]}
+	]`)
+}
+
+func TestUnit_Completions_StreamedAudioOutput(t *testing.T) {
+	var req map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","audio":{"id":"audio_1","transcript":"Hel"}}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"audio":{"data":"AQACAAM="}}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"audio":{"transcript":"lo","data":"AAQABQA="}}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"audio":{"expires_at":1900000000}},"finish_reason":"stop"}]}`,
+			`{"id":"c1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":30}}`,
+			`[DONE]`,
+		))
+	}))
+	defer server.Close()
+
+	config := OpenAICompletionsProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "gpt-4o-audio-preview"
+	session.Thread.AudioOutput = &AudioConfig{Voice: "alloy"}
+	session.Thread.InputAudio(EncodeWAV([]byte{0, 0}, 16000, 1), "audio/wav")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	if audio, _ := req["audio"].(map[string]any); audio["voice"] != "alloy" || audio["format"] != "pcm16" {
+		t.Errorf("expected streamed pcm16 audio, got %v", req["audio"])
+	}
+	part := req["messages"].([]any)[0].(map[string]any)["content"].([]any)[0].(map[string]any)
+	if part["type"] != "input_audio" || part["input_audio"].(map[string]any)["format"] != "wav" {
+		t.Errorf("unexpected audio input %v", part)
+	}
+
+	out := result.Blocks[len(result.Blocks)-1]
+	if out.Type != InferenceBlockAudio || !out.Complete || out.Audio == nil {
+		t.Fatalf("expected a complete audio block, got %+v", out)
+	}
+	if out.Audio.ID != "audio_1" || out.Audio.Transcript != "Hello" || out.Audio.ExpiresAt != 1900000000 || out.Audio.MediaType != "audio/pcm" {
+		t.Errorf("unexpected audio %+v", out.Audio)
+	}
+	wav, err := out.Audio.WAV()
+	if err != nil || len(wav) != 44+10 {
+		t.Fatalf("expected 10 bytes of PCM in a WAV file, got %d bytes, err=%v", len(wav), err)
+	}
+	if string(wav[44:]) != string([]byte{1, 0, 2, 0, 3, 0, 4, 0, 5, 0}) {
+		t.Errorf("audio chunks not joined: % x", wav[44:])
+	}
+}
+
+func TestUnit_Completions_AudioReplay(t *testing.T) {
+	config := OpenAICompletionsProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Input("Say hello")
+	thread.CurrentProvider = p.Name()
+	thread.Audio("a1", ThreadAudio{ID: "audio_1", Transcript: "Hello"})
+	thread.CurrentProvider = "aistudio.google"
+	thread.Audio("a2", ThreadAudio{Transcript: "Hi again"})
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Say hello"}]},
+		{"role":"assistant","content":[{"type":"text","text":"Hi again"}],"audio":{"id":"audio_1"}}
+	]`)
+
+	// Expiry is decided by the thread's clock: once the provider has
+	// forgotten the audio, its transcript is sent instead.
+	thread.Blocks[1].Audio.ExpiresAt = 1900000000
+	thread.Clock = func() time.Time { return time.Unix(1900000000, 0) }
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ = io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Say hello"}]},
+		{"role":"assistant","content":[{"type":"text","text":"Hello"},{"type":"text","text":"Hi again"}]}
+	]`)
+}
+
+func TestUnit_Completions_UsageDetails(t *testing.T) {
+	config := OpenAICompletionsProvider("key")
+	p := &CompletionsAPIRequest{Config: &config}
+	thread := NewProviderState()
+	chunk := `{"id":"c1","choices":[],"usage":{"prompt_tokens":120,"completion_tokens":300,
+		"prompt_tokens_details":{"cached_tokens":20,"audio_tokens":40},
+		"completion_tokens_details":{"reasoning_tokens":200,"audio_tokens":50,"accepted_prediction_tokens":8,"rejected_prediction_tokens":3}}}`
+	if result := p.OnChunk([]byte(chunk), thread); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	want := ThreadUsage{
+		InputTokens:              100,
+		CacheReadTokens:          20,
+		OutputTokens:             300,
+		ReasoningTokens:          200,
+		AudioInputTokens:         40,
+		AudioOutputTokens:        50,
+		AcceptedPredictionTokens: 8,
+		RejectedPredictionTokens: 3,
+	}
+	if thread.Result != want {
+		t.Errorf("unexpected usage %+v", thread.Result)
+	}
+}
@@ -16,13 +16,23 @@ type CompletionsRequest struct {
 	StreamOptions   map[string]any            `json:"stream_options,omitempty"`
 	ResponseFormat  *JsonSchemaResponseFormat `json:"response_format,omitempty"`
 	ReasoningEffort string                    `json:"reasoning_effort,omitempty"`
+	Modalities      []string                  `json:"modalities,omitempty"`
+	Audio           *CompletionsAudioConfig   `json:"audio,omitempty"`
+}
+
+// CompletionsAudioConfig selects the voice and format of spoken output.
+type CompletionsAudioConfig struct {
+	Voice  string `json:"voice"`
+	Format string `json:"format"`
 }
 type CompletionsMessage struct {
 	Id               string                `json:"id,omitempty"`
 	Role             string                `json:"role,omitempty"`
 	Content          any                   `json:"content,omitempty"`
 	ReasoningContent string                `json:"reasoning_content,omitempty"`
+	Reasoning        string                `json:"reasoning,omitempty"`
 	ToolCalls        []CompletionsToolCall `json:"tool_calls,omitempty"`
+	Audio            *CompletionsAudio     `json:"audio,omitempty"`
 	ToolCallId       string                `json:"tool_call_id,omitempty"`
 	Name             string                `json:"name,omitempty"`
 }
@@ -41,21 +51,52 @@ type CompletionImageUrlBlock struct {
 type CompletionImageUrlDetail struct {
 	Url string `json:"url"` // data URL or https URL
 }
+
+// CompletionInputAudioBlock represents an audio content block.
+type CompletionInputAudioBlock struct {
+	Type       string                `json:"type"` // "input_audio"
+	InputAudio CompletionsInputAudio `json:"input_audio"`
+}
+
+type CompletionsInputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"` // "wav" or "mp3"
+}
+
+// CompletionsAudio is generated audio on a response message or stream
+// delta. Replayed assistant messages only carry the id.
+type CompletionsAudio struct {
+	Id         string `json:"id,omitempty"`
+	Data       string `json:"data,omitempty"`
+	Transcript string `json:"transcript,omitempty"`
+	ExpiresAt  int64  `json:"expires_at,omitempty"`
+}
 type CompletionsUsage struct {
-	PromptTokens       int64                         `json:"prompt_tokens"`
-	CompletionTokens   int64                         `json:"completion_tokens"`
-	PromptTokenDetails CompletionsPromptTokensDetail `json:"prompt_tokens_details"`
+	PromptTokens           int64                             `json:"prompt_tokens"`
+	CompletionTokens       int64                             `json:"completion_tokens"`
+	PromptTokenDetails     CompletionsPromptTokensDetail     `json:"prompt_tokens_details"`
+	CompletionTokenDetails CompletionsCompletionTokensDetail `json:"completion_tokens_details"`
 }
 type CompletionsPromptTokensDetail struct {
 	CachedTokens int64 `json:"cached_tokens"`
+	AudioTokens  int64 `json:"audio_tokens"`
+}
+type CompletionsCompletionTokensDetail struct {
+	ReasoningTokens          int64 `json:"reasoning_tokens"`
+	AudioTokens              int64 `json:"audio_tokens"`
+	AcceptedPredictionTokens int64 `json:"accepted_prediction_tokens"`
+	RejectedPredictionTokens int64 `json:"rejected_prediction_tokens"`
 }
 
 type CompletionsResponse struct {
+	Id      string              `json:"id"`
 	Choices []CompletionsChoice `json:"choices"`
 	Usage   CompletionsUsage    `json:"usage"`
 }
 type CompletionsChoice struct {
-	Message CompletionsMessage `json:"message"`
+	Index        int                `json:"index"`
+	Message      CompletionsMessage `json:"message"`
+	FinishReason *string            `json:"finish_reason,omitempty"`
 }
 type CompletionsToolCall struct {
 	Id       string                       `json:"id"`
@@ -81,8 +122,10 @@ type CompletionsStreamChoice struct {
 type CompletionsStreamDelta struct {
 	Role             string                     `json:"role,omitempty"`
 	Content          string                     `json:"content,omitempty"`
-	ReasoningContent string                     `json:"reasoning,omitempty"`
+	ReasoningContent string                     `json:"reasoning_content,omitempty"`
+	Reasoning        string                     `json:"reasoning,omitempty"`
 	ToolCalls        []CompletionsToolCallDelta `json:"tool_calls,omitempty"`
+	Audio            *CompletionsAudio          `json:"audio,omitempty"`
 }
 
 type CompletionsToolCallDelta struct {
@@ -1,10 +1,30 @@
 package aikit
 
 import (
+	"fmt"
+	"net/http"
 	"net/url"
 	"strings"
 )
 
+// ReasoningReplay selects how thinking blocks are sent back to Chat
+// Completions providers on later turns.
+type ReasoningReplay string
+
+const (
+	// ReasoningReplayNone drops thinking from replayed assistant messages.
+	ReasoningReplayNone ReasoningReplay = ""
+	// ReasoningReplayContent sends thinking in the reasoning_content field
+	// (DeepSeek, Qwen).
+	ReasoningReplayContent ReasoningReplay = "reasoning_content"
+	// ReasoningReplayField sends thinking in the reasoning field (Groq,
+	// OpenRouter, vLLM).
+	ReasoningReplayField ReasoningReplay = "reasoning"
+	// ReasoningReplayThinkTags wraps thinking in <think></think> at the start
+	// of the assistant content, for models that embed reasoning inline.
+	ReasoningReplayThinkTags ReasoningReplay = "think_tags"
+)
+
 type ProviderConfig struct {
 	Name string
 	// BaseURL is a base URL (e.g. "https://api.openai.com/v1") that will be
@@ -15,15 +35,47 @@ type ProviderConfig struct {
 	Endpoint string
 
 	APIKey string
+	// AuthHeader names the header that carries APIKey. Empty sends the
+	// standard "Authorization: Bearer <key>"; any other header receives the
+	// raw key (Azure OpenAI uses "api-key").
+	AuthHeader string
+
+	// Region and Credentials are used by providers that sign requests with
+	// AWS SigV4 (Bedrock). When unset they are read from the environment.
+	Region      string
+	Credentials *AWSCredentials
 
 	WebSearchToolName string
 	WebFetchToolName  string
+	// CodeExecutionToolName is the hosted code execution tool type used when
+	// Thread.CodeExecution is set, e.g. "code_execution_20250825" or
+	// "code_interpreter".
+	CodeExecutionToolName string
 
 	BetaFeatures         []string
 	APIVersion           string
 	UseThinkingSummaries bool
 	MaxTokens            int64
 
+	// Deployments maps model names to deployment names for providers that
+	// route by deployment (Azure OpenAI). Unmapped models are used as-is.
+	Deployments map[string]string
+
+	// Transport selects how responses are received. Empty uses the adapter's
+	// streaming transport; TransportJSON sends non-streaming requests and
+	// parses the full response body into the same blocks.
+	Transport GatewayTransport
+
+	// Stateless disables server-side conversation state for the Responses
+	// API. Requests are sent with store:false, reasoning is returned as
+	// encrypted content, and the full input is replayed on every turn instead
+	// of chaining with previous_response_id. Required for zero data retention.
+	Stateless bool
+
+	// ReasoningReplay controls how Chat Completions adapters echo thinking
+	// back to the model in multi-turn conversations.
+	ReasoningReplay ReasoningReplay
+
 	MakeSessionFunction func(*ProviderConfig) *Session
 }
 
@@ -31,6 +83,37 @@ func (c *ProviderConfig) Session() *Session {
 	return c.MakeSessionFunction(c)
 }
 
+// authorize attaches APIKey to the request according to AuthHeader.
+func (c *ProviderConfig) authorize(req *http.Request) {
+	if c.AuthHeader == "" || strings.EqualFold(c.AuthHeader, "Authorization") {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", c.APIKey))
+		return
+	}
+	req.Header.Add(c.AuthHeader, c.APIKey)
+}
+
+// streaming reports whether requests should ask for a streamed response.
+func (c *ProviderConfig) streaming() bool {
+	return c.Transport != TransportJSON
+}
+
+// transport returns TransportJSON when non-streaming mode is configured and
+// the adapter's native streaming transport otherwise.
+func (c *ProviderConfig) transport(streaming GatewayTransport) GatewayTransport {
+	if !c.streaming() {
+		return TransportJSON
+	}
+	return streaming
+}
+
+// deployment returns the deployment name configured for model.
+func (c *ProviderConfig) deployment(model string) string {
+	if deployment, ok := c.Deployments[model]; ok {
+		return deployment
+	}
+	return model
+}
+
 func (c ProviderConfig) resolveEndpoint(defaultPath string) string {
 	raw := strings.TrimSpace(c.Endpoint)
 	if raw == "" {
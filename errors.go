@@ -15,6 +15,7 @@ const (
 	AIErrorCategoryDecodingError   AIErrorCategory = "decoding"
 	AIErrorCategoryToolResultError AIErrorCategory = "tool_result_encode"
 	AIErrorCategoryHTTPStatus      AIErrorCategory = "http_status"
+	AIErrorCategoryContentFilter   AIErrorCategory = "content_filter"
 	AIErrorCategoryUnknown         AIErrorCategory = "unknown"
 )
 
@@ -71,3 +72,11 @@ func ConfigurationError(provider, message string) *AIError {
 		Message:  cleanupMessage(message),
 	}
 }
+
+func ContentFilterError(provider, message string) *AIError {
+	return &AIError{
+		Category: AIErrorCategoryContentFilter,
+		Provider: provider,
+		Message:  cleanupMessage(message),
+	}
+}
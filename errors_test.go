@@ -15,6 +15,7 @@ func TestUnit_Error_ConstructorCategories(t *testing.T) {
 		{"RateLimitError", RateLimitError, AIErrorCategoryRateLimit},
 		{"UnknownError", UnknownError, AIErrorCategoryUnknown},
 		{"ConfigurationError", ConfigurationError, AIErrorCategoryConfiguration},
+		{"ContentFilterError", ContentFilterError, AIErrorCategoryContentFilter},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,263 @@
+package aikit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// Clone returns a deep copy of the block that shares no memory with it.
+func (b *ThreadBlock) Clone() *ThreadBlock {
+	if b == nil {
+		return nil
+	}
+	c := *b
+	if b.ToolCall != nil {
+		call := *b.ToolCall
+		c.ToolCall = &call
+	}
+	if b.ToolResult != nil {
+		result := *b.ToolResult
+		c.ToolResult = &result
+	}
+	if b.WebSearch != nil {
+		search := *b.WebSearch
+		search.Results = slices.Clone(b.WebSearch.Results)
+		c.WebSearch = &search
+	}
+	if b.WebFetch != nil {
+		fetch := *b.WebFetch
+		if fetch.Page != nil {
+			page := *fetch.Page
+			fetch.Page = &page
+		}
+		c.WebFetch = &fetch
+	}
+	if b.ServerTool != nil {
+		tool := *b.ServerTool
+		tool.Files = slices.Clone(b.ServerTool.Files)
+		c.ServerTool = &tool
+	}
+	if b.Image != nil {
+		image := *b.Image
+		c.Image = &image
+	}
+	if b.Document != nil {
+		doc := *b.Document
+		c.Document = &doc
+	}
+	if b.Audio != nil {
+		audio := *b.Audio
+		c.Audio = &audio
+	}
+	c.Citations = slices.Clone(b.Citations)
+	c.Metadata = maps.Clone(b.Metadata)
+	return &c
+}
+
+func cloneBlocks(blocks []*ThreadBlock) []*ThreadBlock {
+	cloned := make([]*ThreadBlock, len(blocks))
+	for i, b := range blocks {
+		cloned[i] = b.Clone()
+	}
+	return cloned
+}
+
+// Fork returns an independent copy of the thread: the same configuration,
+// usage and a deep copy of the blocks. Continuing either thread leaves the
+// other unchanged. Callbacks and the tool searchers are shared.
+func (s *Thread) Fork() *Thread {
+	fork := *s
+	fork.Blocks = cloneBlocks(s.Blocks)
+	if len(s.Archive) > 0 {
+		fork.Archive = cloneBlocks(s.Archive)
+	}
+	fork.Turns = slices.Clone(s.Turns)
+	fork.Tools = maps.Clone(s.Tools)
+	if s.Cache != nil {
+		cache := *s.Cache
+		fork.Cache = &cache
+	}
+	if s.AudioOutput != nil {
+		audio := *s.AudioOutput
+		fork.AudioOutput = &audio
+	}
+	if s.StructuredOutputStrict != nil {
+		strict := *s.StructuredOutputStrict
+		fork.StructuredOutputStrict = &strict
+	}
+	fork.updated = false
+	fork.clientTools = nil
+	fork.clientSearches = 0
+	return &fork
+}
+
+// blockIndex returns the index of the last block with the given ID, or -1.
+func (s *Thread) blockIndex(blockID string) int {
+	for i := len(s.Blocks) - 1; i >= 0; i-- {
+		if s.Blocks[i].ID == blockID {
+			return i
+		}
+	}
+	return -1
+}
+
+// TruncateAt rewinds the thread so the block with the given ID is the last
+// one. Usage already spent is kept.
+func (s *Thread) TruncateAt(blockID string) error {
+	idx := s.blockIndex(blockID)
+	if idx < 0 {
+		return fmt.Errorf("no block with id %q", blockID)
+	}
+	s.Blocks = s.Blocks[:idx+1]
+	s.updated = true
+	return nil
+}
+
+// ReplaceInput changes the text of an earlier input block and drops every
+// block after it, so the next Stream regenerates the answer. Fork first to
+// keep the original conversation.
+func (s *Thread) ReplaceInput(blockID string, text string) error {
+	idx := s.blockIndex(blockID)
+	if idx < 0 || s.Blocks[idx].Type != InferenceBlockInput {
+		return fmt.Errorf("no input block with id %q", blockID)
+	}
+	// The block may be shared with a snapshot taken before.
+	edited := s.Blocks[idx].Clone()
+	edited.Text = text
+	s.Blocks = append(s.Blocks[:idx], edited)
+	s.updated = true
+	return nil
+}
+
+// ThreadTree records how threads were forked from each other, so several
+// follow-ups or edits of one conversation can be kept side by side.
+type ThreadTree struct {
+	Branches []*ThreadBranch
+	next     int
+}
+
+// ThreadBranch is one thread in a ThreadTree.
+type ThreadBranch struct {
+	ID       string
+	ParentID string
+	// ForkedAt is the ID of the parent's last block when the branch was
+	// forked, empty if the parent had no blocks.
+	ForkedAt string
+	Label    string
+	Thread   *Thread
+}
+
+// NewThreadTree starts a tree with root as its "root" branch.
+func NewThreadTree(root *Thread) *ThreadTree {
+	return &ThreadTree{
+		Branches: []*ThreadBranch{{ID: "root", Thread: root}},
+	}
+}
+
+// Root returns the branch the tree was created with.
+func (t *ThreadTree) Root() *ThreadBranch {
+	if len(t.Branches) == 0 {
+		return nil
+	}
+	return t.Branches[0]
+}
+
+// Branch returns the branch with the given ID, or nil.
+func (t *ThreadTree) Branch(id string) *ThreadBranch {
+	for _, b := range t.Branches {
+		if b.ID == id {
+			return b
+		}
+	}
+	return nil
+}
+
+// Children returns the branches forked directly from the given branch.
+func (t *ThreadTree) Children(id string) []*ThreadBranch {
+	children := []*ThreadBranch{}
+	for _, b := range t.Branches {
+		if b.ParentID == id {
+			children = append(children, b)
+		}
+	}
+	return children
+}
+
+// Fork adds a branch with a deep copy of the parent's current thread.
+func (t *ThreadTree) Fork(parentID string, label string) (*ThreadBranch, error) {
+	parent := t.Branch(parentID)
+	if parent == nil {
+		return nil, fmt.Errorf("no branch with id %q", parentID)
+	}
+	branch := &ThreadBranch{
+		ID:       t.nextID(),
+		ParentID: parentID,
+		Label:    label,
+		Thread:   parent.Thread.Fork(),
+	}
+	if n := len(parent.Thread.Blocks); n > 0 {
+		branch.ForkedAt = parent.Thread.Blocks[n-1].ID
+	}
+	t.Branches = append(t.Branches, branch)
+	return branch, nil
+}
+
+func (t *ThreadTree) nextID() string {
+	for {
+		t.next++
+		id := fmt.Sprintf("branch-%d", t.next)
+		if t.Branch(id) == nil {
+			return id
+		}
+	}
+}
+
+// ThreadTreeSnapshot is the serializable form of a ThreadTree, in JSON or
+// XML. Like Snapshot it keeps only the blocks of each branch; configure the
+// restored threads before streaming.
+type ThreadTreeSnapshot struct {
+	XMLName  xml.Name               `json:"-" xml:"thread_tree"`
+	Branches []ThreadBranchSnapshot `json:"branches" xml:"branch"`
+}
+
+type ThreadBranchSnapshot struct {
+	ID       string         `json:"id" xml:"id,attr"`
+	ParentID string         `json:"parent_id,omitempty" xml:"parent_id,attr,omitempty"`
+	ForkedAt string         `json:"forked_at,omitempty" xml:"forked_at,attr,omitempty"`
+	Label    string         `json:"label,omitempty" xml:"label,attr,omitempty"`
+	Blocks   []*ThreadBlock `json:"blocks" xml:"blocks>block"`
+}
+
+// Snapshot copies every branch's blocks into a serializable snapshot.
+func (t *ThreadTree) Snapshot() *ThreadTreeSnapshot {
+	snapshot := &ThreadTreeSnapshot{Branches: []ThreadBranchSnapshot{}}
+	for _, b := range t.Branches {
+		snapshot.Branches = append(snapshot.Branches, ThreadBranchSnapshot{
+			ID:       b.ID,
+			ParentID: b.ParentID,
+			ForkedAt: b.ForkedAt,
+			Label:    b.Label,
+			Blocks:   cloneBlocks(b.Thread.Blocks),
+		})
+	}
+	return snapshot
+}
+
+// Restore rebuilds the tree with a new thread for each branch.
+func (snapshot *ThreadTreeSnapshot) Restore() *ThreadTree {
+	tree := &ThreadTree{}
+	for _, b := range snapshot.Branches {
+		thread := NewProviderState()
+		thread.Restore(&Snapshot{Blocks: b.Blocks})
+		tree.Branches = append(tree.Branches, &ThreadBranch{
+			ID:       b.ID,
+			ParentID: b.ParentID,
+			ForkedAt: b.ForkedAt,
+			Label:    b.Label,
+			Thread:   thread,
+		})
+	}
+	return tree
+}
@@ -0,0 +1,191 @@
+package aikit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+)
+
+func forkTestThread() *Thread {
+	thread := &Thread{Model: "test-model"}
+	thread.System("Be brief.")
+	thread.Input("Weather in Paris?")
+	thread.ToolCall("call_1", "weather", `{"city":"Paris"}`)
+	thread.ToolResult(&ThreadToolCall{ID: "call_1"}, "sunny")
+	thread.Text("msg_1", "It is sunny.")
+	thread.Cite("msg_1", ThreadCitation{Type: CitationURL, URL: "https://example.com"})
+	thread.Complete("msg_1")
+	return thread
+}
+
+func TestUnit_Fork_IsIndependent(t *testing.T) {
+	thread := forkTestThread()
+	fork := thread.Fork()
+	fork.Blocks[2].ToolResult.Output = "rainy"
+	fork.Blocks[3].Citations[0].URL = "https://changed.example"
+	fork.Input("And tomorrow?")
+
+	if thread.Blocks[2].ToolResult.Output != "sunny" || thread.Blocks[3].Citations[0].URL != "https://example.com" {
+		t.Errorf("editing the fork changed the original")
+	}
+	if len(thread.Blocks) != 4 || len(fork.Blocks) != 5 {
+		t.Errorf("unexpected block counts %d and %d", len(thread.Blocks), len(fork.Blocks))
+	}
+
+	// Snapshots don't follow later edits either.
+	snapshot := thread.Snapshot()
+	thread.Blocks[3].Text = "edited"
+	if snapshot.Blocks[3].Text != "It is sunny." {
+		t.Errorf("snapshot shares blocks with the thread")
+	}
+}
+
+func TestUnit_Fork_ArchiveIsIndependent(t *testing.T) {
+	thread := forkTestThread()
+	thread.Archive = make([]*ThreadBlock, 1, 4)
+	thread.Archive[0] = &ThreadBlock{ID: "old-1", Type: InferenceBlockInput, Text: "Earlier"}
+	fork := thread.Fork()
+	thread.Archive = append(thread.Archive, &ThreadBlock{ID: "parent", Type: InferenceBlockText})
+	fork.Archive = append(fork.Archive, &ThreadBlock{ID: "fork", Type: InferenceBlockText})
+	fork.Archive[0].Text = "changed"
+
+	if len(thread.Archive) != 2 || thread.Archive[1].ID != "parent" || thread.Archive[0].Text != "Earlier" {
+		t.Errorf("the fork changed the original's archive: %+v", thread.Archive)
+	}
+	if len(fork.Archive) != 2 || fork.Archive[1].ID != "fork" {
+		t.Errorf("the original changed the fork's archive: %+v", fork.Archive)
+	}
+}
+
+func TestUnit_Fork_TruncateAndReplaceInput(t *testing.T) {
+	thread := forkTestThread()
+	input := thread.Blocks[1].ID
+	if input == "" {
+		t.Fatal("input blocks need an id")
+	}
+
+	rewound := thread.Fork()
+	if err := rewound.TruncateAt("call_1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rewound.Blocks) != 3 || rewound.Blocks[2].ID != "call_1" {
+		t.Errorf("expected to rewind to the tool call, got %d blocks", len(rewound.Blocks))
+	}
+
+	snapshot := thread.Snapshot()
+	if err := thread.ReplaceInput(input, "Weather in Rome?"); err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Blocks) != 2 || thread.Blocks[1].Text != "Weather in Rome?" {
+		t.Errorf("expected the edited input to be the last block, got %+v", thread.Blocks)
+	}
+	if snapshot.Blocks[1].Text != "Weather in Paris?" {
+		t.Errorf("editing changed an earlier snapshot")
+	}
+
+	if err := thread.TruncateAt("missing"); err == nil {
+		t.Errorf("expected an error for an unknown block")
+	}
+	if err := thread.ReplaceInput(thread.Blocks[0].ID, "x"); err == nil {
+		t.Errorf("expected an error for a non-input block")
+	}
+}
+
+func TestSnapshot_ThreadTreeSerialization(t *testing.T) {
+	tree := NewThreadTree(forkTestThread())
+	paris, err := tree.Fork("root", "follow-up")
+	if err != nil {
+		t.Fatal(err)
+	}
+	paris.Thread.Input("And tomorrow?")
+	rome, _ := tree.Fork("root", "edit")
+	rome.Thread.ReplaceInput(rome.Thread.Blocks[1].ID, "Weather in Rome?")
+	nested, _ := tree.Fork(paris.ID, "")
+	if _, err := tree.Fork("missing", ""); err == nil {
+		t.Errorf("expected an error for an unknown parent")
+	}
+
+	if children := tree.Children("root"); len(children) != 2 || children[0] != paris || children[1] != rome {
+		t.Errorf("unexpected children %+v", children)
+	}
+	if nested.ParentID != paris.ID || paris.ForkedAt != "msg_1" {
+		t.Errorf("unexpected parent links %+v %+v", nested, paris)
+	}
+
+	check := func(t *testing.T, restored *ThreadTree) {
+		t.Helper()
+		if len(restored.Branches) != 4 || restored.Root().ID != "root" {
+			t.Fatalf("unexpected branches %+v", restored.Branches)
+		}
+		b := restored.Branch(nested.ID)
+		if b == nil || b.ParentID != paris.ID || len(b.Thread.Blocks) != 5 || b.Thread.Blocks[4].Text != "And tomorrow?" {
+			t.Errorf("unexpected nested branch %+v", b)
+		}
+		r := restored.Branch(rome.ID)
+		if r.Label != "edit" || r.ForkedAt != "msg_1" || len(r.Thread.Blocks) != 2 || r.Thread.Blocks[1].Text != "Weather in Rome?" {
+			t.Errorf("unexpected edited branch %+v", r)
+		}
+		if call := restored.Root().Thread.Blocks[2]; call.ToolResult == nil || call.ToolResult.Output != "sunny" {
+			t.Errorf("tool result not preserved")
+		}
+		// New forks don't reuse restored ids.
+		if next, _ := restored.Fork("root", ""); restored.Branch(next.ID) != next || next.ID == paris.ID {
+			t.Errorf("fork id %q collides", next.ID)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, err := json.Marshal(tree.Snapshot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var snapshot ThreadTreeSnapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		check(t, snapshot.Restore())
+	})
+	t.Run("xml", func(t *testing.T) {
+		data, err := xml.Marshal(tree.Snapshot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var snapshot ThreadTreeSnapshot
+		if err := xml.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		check(t, snapshot.Restore())
+	})
+}
+
+func TestUnit_Fork_IdsStayUniqueAfterTruncate(t *testing.T) {
+	thread := &Thread{}
+	for _, text := range []string{"one", "two", "three"} {
+		thread.Input(text)
+	}
+	if err := thread.TruncateAt(thread.Blocks[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	thread.Input("four")
+	thread.Input("five")
+	if ids := blockIDs(thread.Blocks); ids[1] == ids[0] || ids[2] == ids[1] || ids[1] != "input-4" {
+		t.Errorf("expected fresh ids after truncating, got %v", ids)
+	}
+
+	// The numbering survives a snapshot.
+	restored := &Thread{}
+	restored.Restore(thread.Snapshot())
+	restored.TruncateAt(restored.Blocks[0].ID)
+	restored.Input("six")
+	if id := restored.Blocks[1].ID; id != "input-6" {
+		t.Errorf("expected the restored thread to continue at input-6, got %q", id)
+	}
+}
+
+func blockIDs(blocks []*ThreadBlock) []string {
+	ids := make([]string, len(blocks))
+	for i, b := range blocks {
+		ids[i] = b.ID
+	}
+	return ids
+}
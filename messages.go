@@ -2,9 +2,11 @@ package aikit
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
@@ -22,8 +24,23 @@ type MessagesAPIRequest struct {
 
 	request      MessagesRequest
 	lastToolCall messagesLastToolCall
+	// toolResults holds results for the current assistant turn until a
+	// later block ends the turn. toolResponse is the response their calls
+	// came from.
+	toolResults  []MessagesContent
+	toolResponse int
+	system       string
+	// codeExecution is set when the code execution tool was requested,
+	// which needs a beta header.
+	codeExecution bool
+	// usage is what the current message has added to the thread's usage.
+	usage ThreadUsage
 }
 
+// messagesMaxCacheBreakpoints is the number of cache_control markers the
+// Messages API accepts in one request.
+const messagesMaxCacheBreakpoints = 4
+
 func (p *MessagesAPIRequest) blockId(thread *Thread, index int) string {
 	return fmt.Sprintf("%s.%d", thread.ThreadId, index)
 }
@@ -33,20 +50,33 @@ func (p *MessagesAPIRequest) Name() string {
 }
 
 func (p *MessagesAPIRequest) Transport() GatewayTransport {
-	return TransportSSE
+	return p.Config.transport(TransportSSE)
 }
 
 func (p *MessagesAPIRequest) PrepareForUpdates() {}
 
+func (p *MessagesAPIRequest) hostsWebSearch() bool {
+	return p.Config.WebSearchToolName != ""
+}
+
+func (p *MessagesAPIRequest) hostsWebFetch() bool {
+	return p.Config.WebFetchToolName != ""
+}
+
 func (p *MessagesAPIRequest) InitSession(thread *Thread) {
 	tools := make([]map[string]any, 0)
-	for name := range thread.Tools {
+	toolDefs := thread.toolDefinitions()
+	for name := range toolDefs {
 		toolSpec := map[string]any{}
-		toolSpec["description"] = thread.Tools[name].Description
-		toolSpec["input_schema"] = thread.Tools[name].Parameters
+		toolSpec["description"] = toolDefs[name].Description
+		toolSpec["input_schema"] = toolDefs[name].Parameters
 		toolSpec["name"] = name
 		tools = append(tools, toolSpec)
 	}
+	// Keep tool order stable so the cached prefix matches across requests.
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i]["name"].(string) < tools[j]["name"].(string)
+	})
 
 	if thread.MaxWebSearches > 0 && p.Config.WebSearchToolName != "" {
 		tools = append(tools, map[string]any{
@@ -62,12 +92,23 @@ func (p *MessagesAPIRequest) InitSession(thread *Thread) {
 		})
 	}
 
+	p.codeExecution = thread.CodeExecution && p.Config.CodeExecutionToolName != ""
+	if p.codeExecution {
+		tools = append(tools, map[string]any{
+			"type": p.Config.CodeExecutionToolName,
+			"name": "code_execution",
+		})
+	}
+
+	p.toolResults = nil
+	p.toolResponse = 0
+	p.system = ""
 	p.request = MessagesRequest{
 		Messages:  []MessagesMessage{},
 		Model:     thread.Model,
 		Tools:     tools,
 		MaxTokens: p.Config.MaxTokens,
-		Stream:    true,
+		Stream:    p.Config.streaming(),
 	}
 	if schema := thread.StructuredOutputSchemaValue(); schema != nil {
 		p.request.OutputFormat = &MessagesOutputFormat{
@@ -87,111 +128,278 @@ func (p *MessagesAPIRequest) InitSession(thread *Thread) {
 func (p *MessagesAPIRequest) Update(block *ThreadBlock) {
 	switch block.Type {
 	case InferenceBlockSystem:
-		p.request.System = block.Text
+		p.system = block.Text
 	case InferenceBlockInput:
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role: "user",
-			Content: []MessagesContent{
-				{
-					Type: "text",
-					Text: block.Text,
-					CacheControl: &MessagesCacheControl{
-						Type: "ephemeral",
-					},
-				},
-			},
+		p.appendUser(MessagesContent{
+			Type: "text",
+			Text: block.Text,
 		})
 	case InferenceBlockInputImage:
 		if block.Image == nil {
 			return
 		}
-		imgContent := MessagesContent{
+		p.appendUser(MessagesContent{
 			Type: "image",
 			Source: &MessagesImageSource{
 				Type:      "base64",
 				MediaType: block.Image.MediaType,
 				Data:      block.Image.GetBase64(),
 			},
-		}
-		// Append to last user message if exists, else create new
-		if len(p.request.Messages) > 0 {
-			lastIdx := len(p.request.Messages) - 1
-			if p.request.Messages[lastIdx].Role == "user" {
-				p.request.Messages[lastIdx].Content = append(
-					p.request.Messages[lastIdx].Content,
-					imgContent,
-				)
-				return
-			}
-		}
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role:    "user",
-			Content: []MessagesContent{imgContent},
 		})
+	case InferenceBlockInputDocument:
+		if block.Document == nil {
+			return
+		}
+		p.appendUser(messagesDocumentContent(block.Document))
 	case InferenceBlockText:
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role: "assistant",
-			Content: []MessagesContent{
-				{
-					Type: "text",
-					Text: block.Text,
-				},
-			},
+		p.flushToolResults()
+		p.appendAssistant(MessagesContent{
+			Type: "text",
+			Text: block.Text,
 		})
 	case InferenceBlockThinking:
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role: "assistant",
-			Content: []MessagesContent{
-				{
-					Type:      "thinking",
-					Thinking:  block.Text,
-					Signature: block.Signature,
-				},
-			},
+		p.flushToolResults()
+		p.appendAssistant(MessagesContent{
+			Type:      "thinking",
+			Thinking:  block.Text,
+			Signature: block.Signature,
 		})
 	case InferenceBlockEncryptedThinking:
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role: "assistant",
-			Content: []MessagesContent{
-				{
-					Type: "redacted_thinking",
-					Data: block.Text,
-				},
-			},
+		p.flushToolResults()
+		p.appendAssistant(MessagesContent{
+			Type: "redacted_thinking",
+			Data: block.Text,
 		})
+	case InferenceBlockWebSearch:
+		if block.ProviderID != p.Name() || block.WebSearch == nil {
+			return
+		}
+		// Server tool calls and their results are both part of the
+		// assistant turn.
+		p.flushToolResults()
+		input, _ := json.Marshal(MessagesWebSearchQuery{Query: block.WebSearch.Query})
+		p.appendAssistant(MessagesContent{Type: "server_tool_use", Id: block.ID, Name: "web_search", Input: input})
+		var content any = MessagesServerToolError{Type: "web_search_tool_result_error", ErrorCode: block.WebSearch.ErrorCode}
+		if block.WebSearch.ErrorCode == "" {
+			results := []MessagesWebSearchResult{}
+			for _, result := range block.WebSearch.Results {
+				results = append(results, MessagesWebSearchResult{
+					Type:             "web_search_result",
+					URL:              result.URL,
+					Title:            result.Title,
+					EncryptedContent: result.EncryptedContent,
+					PageAge:          result.PageAge,
+				})
+			}
+			content = results
+		}
+		p.appendAssistant(MessagesContent{Type: "web_search_tool_result", ToolUseId: block.ID, Content: content})
+	case InferenceBlockViewWebpage:
+		if block.ProviderID != p.Name() || block.WebFetch == nil {
+			return
+		}
+		p.flushToolResults()
+		input, _ := json.Marshal(MessagesWebFetchQuery{URL: block.Text})
+		p.appendAssistant(MessagesContent{Type: "server_tool_use", Id: block.ID, Name: "web_fetch", Input: input})
+		fetch := block.WebFetch
+		result := MessagesWebFetchResult{Type: "web_fetch_tool_error", ErrorCode: fetch.ErrorCode}
+		if fetch.ErrorCode == "" {
+			result = MessagesWebFetchResult{Type: "web_fetch_result", URL: fetch.URL, RetrievedAt: fetch.RetrievedAt}
+			if fetch.Page != nil {
+				page := messagesDocumentContent(fetch.Page)
+				result.Content = &page
+			}
+		}
+		p.appendAssistant(MessagesContent{Type: "web_fetch_tool_result", ToolUseId: block.ID, Content: result})
+	case InferenceBlockServerTool:
+		tool := block.ServerTool
+		if block.ProviderID != p.Name() || tool == nil || tool.ResultType == "" {
+			return
+		}
+		p.flushToolResults()
+		input := json.RawMessage(tool.Input)
+		if tool.Input == "" {
+			input = json.RawMessage("{}")
+		}
+		p.appendAssistant(MessagesContent{Type: "server_tool_use", Id: block.ID, Name: tool.Name, Input: input})
+		p.appendAssistant(MessagesContent{Type: tool.ResultType, ToolUseId: block.ID, Content: json.RawMessage(tool.Result)})
 	case InferenceBlockToolCall:
-		p.request.Messages = append(p.request.Messages, MessagesMessage{
-			Role: "assistant",
-			Content: []MessagesContent{
-				{
-					Type:  "tool_use",
-					Name:  block.ToolCall.Name,
-					Id:    block.ToolCall.ID,
-					Input: []byte(block.ToolCall.Arguments),
-				},
-			},
+		// Parallel calls share the assistant message of the first call, and
+		// their results are sent together once the turn ends. A call from a
+		// later response starts a new turn after those results.
+		if block.Response != p.toolResponse {
+			p.flushToolResults()
+			p.toolResponse = block.Response
+		}
+		p.appendAssistant(MessagesContent{
+			Type:  "tool_use",
+			Name:  block.ToolCall.Name,
+			Id:    block.ToolCall.ID,
+			Input: []byte(block.ToolCall.Arguments),
 		})
 		if block.ToolResult != nil {
-			p.request.Messages = append(p.request.Messages, MessagesMessage{
-				Role: "user",
-				Content: []MessagesContent{
-					{
-						Type:      "tool_result",
-						Content:   []byte(block.ToolResult.Output),
-						ToolUseId: block.ToolCall.ID,
-					},
-				},
+			p.toolResults = append(p.toolResults, MessagesContent{
+				Type:      "tool_result",
+				Content:   block.ToolResult.Output,
+				ToolUseId: block.ToolCall.ID,
 			})
 		}
 	}
 }
 
+func messagesDocumentContent(doc *ThreadDocument) MessagesContent {
+	content := MessagesContent{
+		Type:  "document",
+		Title: doc.Title,
+		Source: &MessagesImageSource{
+			Type:      "base64",
+			MediaType: doc.MediaType,
+			Data:      doc.GetBase64(),
+		},
+	}
+	if doc.IsText() {
+		// Text sources only accept text/plain.
+		content.Source = &MessagesImageSource{
+			Type:      "text",
+			MediaType: "text/plain",
+			Data:      doc.Text(),
+		}
+	}
+	if doc.Citations {
+		content.Citations = &MessagesCitationsConfig{Enabled: true}
+	}
+	return content
+}
+
+// appendAssistant adds content to the trailing assistant message, starting a
+// new one if the last message is from the user.
+func (p *MessagesAPIRequest) appendAssistant(content MessagesContent) {
+	if last := len(p.request.Messages) - 1; last >= 0 && p.request.Messages[last].Role == "assistant" {
+		p.request.Messages[last].Content = append(p.request.Messages[last].Content, content)
+		return
+	}
+	p.request.Messages = append(p.request.Messages, MessagesMessage{
+		Role:    "assistant",
+		Content: []MessagesContent{content},
+	})
+}
+
+// appendUser adds content to the trailing user message (such as the one
+// carrying tool results), starting a new one after an assistant message.
+func (p *MessagesAPIRequest) appendUser(content MessagesContent) {
+	p.flushToolResults()
+	if last := len(p.request.Messages) - 1; last >= 0 && p.request.Messages[last].Role == "user" {
+		p.request.Messages[last].Content = append(p.request.Messages[last].Content, content)
+		return
+	}
+	p.request.Messages = append(p.request.Messages, MessagesMessage{
+		Role:    "user",
+		Content: []MessagesContent{content},
+	})
+}
+
+// flushToolResults sends the pending tool results as one user message.
+func (p *MessagesAPIRequest) flushToolResults() {
+	if len(p.toolResults) == 0 {
+		return
+	}
+	p.request.Messages = append(p.request.Messages, MessagesMessage{
+		Role:    "user",
+		Content: p.toolResults,
+	})
+	p.toolResults = nil
+}
+
+// prepareRequest finishes the pending turn and places cache breakpoints
+// before the request body is serialized.
+func (p *MessagesAPIRequest) prepareRequest(thread *Thread) {
+	p.flushToolResults()
+	p.placeCacheBreakpoints(thread.CacheConfigValue())
+}
+
+// placeCacheBreakpoints marks the tools, system prompt and latest user turns
+// with cache_control according to config, within the API's breakpoint limit.
+// Markers from previous requests are cleared first so they don't accumulate.
+func (p *MessagesAPIRequest) placeCacheBreakpoints(config CacheConfig) {
+	control := &MessagesCacheControl{Type: "ephemeral", TTL: string(config.TTL)}
+	remaining := messagesMaxCacheBreakpoints
+
+	for _, tool := range p.request.Tools {
+		delete(tool, "cache_control")
+	}
+	if config.Tools && len(p.request.Tools) > 0 {
+		p.request.Tools[len(p.request.Tools)-1]["cache_control"] = control
+		remaining--
+	}
+
+	p.request.System = p.system
+	if config.System && p.system != "" {
+		p.request.System = []MessagesContent{{
+			Type:         "text",
+			Text:         p.system,
+			CacheControl: control,
+		}}
+		remaining--
+	}
+
+	for i := range p.request.Messages {
+		for j := range p.request.Messages[i].Content {
+			p.request.Messages[i].Content[j].CacheControl = nil
+		}
+	}
+	turns := min(config.LastUserTurns, remaining)
+	for i := len(p.request.Messages) - 1; i >= 0 && turns > 0; i-- {
+		msg := &p.request.Messages[i]
+		if msg.Role != "user" || len(msg.Content) == 0 {
+			continue
+		}
+		msg.Content[len(msg.Content)-1].CacheControl = control
+		turns--
+	}
+}
+
 func (p *MessagesAPIRequest) MakeRequest(thread *Thread) *http.Request {
-	endpoint := p.Config.resolveEndpoint("/v1/messages")
+	p.prepareRequest(thread)
 	body, _ := json.Marshal(p.request)
-	providerReq, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	return p.post("/v1/messages", body)
+}
+
+// makeCountTokensRequest asks count_tokens for the size of the prompt
+// MakeRequest would send.
+func (p *MessagesAPIRequest) makeCountTokensRequest(thread *Thread) (*http.Request, error) {
+	if p.Config.Endpoint != "" {
+		return nil, ConfigurationError(p.Name(), "token counting needs BaseURL, not a custom Endpoint")
+	}
+	p.prepareRequest(thread)
+	body, _ := json.Marshal(MessagesCountTokensRequest{
+		Model:        p.request.Model,
+		Messages:     p.request.Messages,
+		Tools:        p.request.Tools,
+		System:       p.request.System,
+		Thinking:     p.request.Thinking,
+		OutputFormat: p.request.OutputFormat,
+	})
+	return p.post("/v1/messages/count_tokens", body), nil
+}
+
+func (p *MessagesAPIRequest) parseCountTokens(body []byte) (int64, error) {
+	var count MessagesCountTokensResponse
+	if err := json.Unmarshal(body, &count); err != nil {
+		return 0, DecodingError(p.Name(), err.Error())
+	}
+	return count.InputTokens, nil
+}
+
+// post builds a request to the Messages API with the version, key and beta
+// headers.
+func (p *MessagesAPIRequest) post(path string, body []byte) *http.Request {
+	providerReq, _ := http.NewRequest("POST", p.Config.resolveEndpoint(path), bytes.NewReader(body))
 	providerReq.Header.Add("Content-Type", "application/json")
-	providerReq.Header.Add("Accept", "text/event-stream")
+	if p.request.Stream && path == "/v1/messages" {
+		providerReq.Header.Add("Accept", "text/event-stream")
+	} else {
+		providerReq.Header.Add("Accept", "application/json")
+	}
 	if p.Config.APIVersion == "" {
 		providerReq.Header.Add("anthropic-version", "2023-06-01")
 	} else {
@@ -199,12 +407,15 @@ func (p *MessagesAPIRequest) MakeRequest(thread *Thread) *http.Request {
 	}
 
 	providerReq.Header.Add("x-api-key", p.Config.APIKey)
-	if p.request.OutputFormat != nil || len(p.Config.BetaFeatures) > 0 {
-		features := make([]string, 0, len(p.Config.BetaFeatures)+1)
+	if p.request.OutputFormat != nil || p.codeExecution || len(p.Config.BetaFeatures) > 0 {
+		features := make([]string, 0, len(p.Config.BetaFeatures)+2)
 		features = append(features, p.Config.BetaFeatures...)
 		if p.request.OutputFormat != nil {
 			features = append(features, "structured-outputs-2025-11-13")
 		}
+		if p.codeExecution {
+			features = append(features, "code-execution-2025-08-25")
+		}
 		betaHeader := "x-beta-features"
 		if p.Config.Name == "anthropic" {
 			betaHeader = "anthropic-beta"
@@ -214,6 +425,31 @@ func (p *MessagesAPIRequest) MakeRequest(thread *Thread) *http.Request {
 	return providerReq
 }
 
+// applyUsage adds the usage of the current message to the thread. The
+// counts in message_delta are totals for the whole message that repeat
+// those of message_start, so only the change is added. input_tokens
+// excludes the cached tokens.
+func (p *MessagesAPIRequest) applyUsage(usage MessagesUsage, thread *Thread) {
+	next := p.usage
+	if usage.InputTokens > 0 {
+		next.InputTokens = usage.InputTokens
+	}
+	if usage.CacheReadInputTokens > 0 {
+		next.CacheReadTokens = usage.CacheReadInputTokens
+	}
+	if usage.CacheCreationInputTokens > 0 {
+		next.CacheWriteTokens = usage.CacheCreationInputTokens
+	}
+	if usage.CacheCreation != nil && usage.CacheCreation.Ephemeral1hInputTokens > 0 {
+		next.CacheWrite1hTokens = usage.CacheCreation.Ephemeral1hInputTokens
+	}
+	if usage.OutputTokens > 0 {
+		next.OutputTokens = usage.OutputTokens
+	}
+	thread.Result = thread.Result.plus(next.since(p.usage))
+	p.usage = next
+}
+
 func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 	var env MessagesStreamEnvelope
 	if err := json.Unmarshal(data, &env); err != nil {
@@ -241,20 +477,16 @@ func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 			if ms.Message.ID != "" {
 				thread.ThreadId = ms.Message.ID
 			}
-			usage := ms.Message.Usage
-			thread.Result.InputTokens += usage.InputTokens - usage.CacheReadInputTokens - usage.CacheCreationInputTokens
-			thread.Result.CacheReadTokens += usage.CacheReadInputTokens
-			thread.Result.CacheWriteTokens += usage.CacheCreationInputTokens
-			thread.Result.OutputTokens += usage.OutputTokens
+			p.usage = ThreadUsage{}
+			p.applyUsage(ms.Message.Usage, thread)
 		}
 	case "message_delta":
 		var md MessagesStreamMessageDelta
 		if err := json.Unmarshal(data, &md); err == nil {
-			usage := md.Usage
-			thread.Result.InputTokens += usage.InputTokens - usage.CacheReadInputTokens - usage.CacheCreationInputTokens
-			thread.Result.OutputTokens += usage.OutputTokens
-			thread.Result.CacheReadTokens += usage.CacheReadInputTokens
-			thread.Result.CacheWriteTokens += usage.CacheCreationInputTokens
+			p.applyUsage(md.Usage, thread)
+			if md.Delta.StopReason != "" {
+				thread.stopReason = md.Delta.StopReason
+			}
 		}
 	case "content_block_start":
 		var cbs MessagesStreamContentBlockStart
@@ -293,17 +525,67 @@ func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 					IsServer: true,
 					ToolName: "web_fetch",
 				}
+			default:
+				// Code execution and its bash and text editor tools.
+				thread.ServerTool(cbs.ContentBlock.ID, cbs.ContentBlock.Name)
+				if input := cbs.ContentBlock.Input; len(input) > 0 && string(input) != "{}" {
+					thread.ServerToolInput(cbs.ContentBlock.ID, string(input))
+				}
+				p.lastToolCall = messagesLastToolCall{
+					ID:       cbs.ContentBlock.ID,
+					IsServer: true,
+					ToolName: cbs.ContentBlock.Name,
+				}
 			}
 		case "web_search_tool_result":
-			for _, search := range cbs.ContentBlock.Content {
-				thread.WebSearchResult(cbs.ContentBlock.ToolUseId, ThreadWebSearchResult{
-					Title: search.Title,
-					URL:   search.URL,
+			id := cbs.ContentBlock.ToolUseId
+			var results []MessagesWebSearchResult
+			if err := json.Unmarshal(cbs.ContentBlock.Content, &results); err != nil {
+				var failure MessagesServerToolError
+				if err := json.Unmarshal(cbs.ContentBlock.Content, &failure); err != nil {
+					return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+				}
+				thread.WebSearchError(id, failure.ErrorCode)
+				break
+			}
+			for _, search := range results {
+				thread.WebSearchResult(id, ThreadWebSearchResult{
+					Title:            search.Title,
+					URL:              search.URL,
+					PageAge:          search.PageAge,
+					EncryptedContent: search.EncryptedContent,
 				})
 			}
-			thread.CompleteWebSearch(cbs.ContentBlock.ToolUseId)
+			thread.CompleteWebSearch(id)
+		case "web_fetch_tool_result":
+			var result MessagesWebFetchResult
+			if err := json.Unmarshal(cbs.ContentBlock.Content, &result); err != nil {
+				return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+			}
+			fetch := ThreadWebFetch{
+				URL:         result.URL,
+				RetrievedAt: result.RetrievedAt,
+				ErrorCode:   result.ErrorCode,
+			}
+			if doc := result.Content; doc != nil && doc.Source != nil {
+				fetch.Page = &ThreadDocument{
+					MediaType: doc.Source.MediaType,
+					Title:     doc.Title,
+					Base64:    doc.Source.Data,
+				}
+				if doc.Source.Type == "text" {
+					fetch.Page.Base64 = base64.StdEncoding.EncodeToString([]byte(doc.Source.Data))
+				}
+			}
+			thread.WebFetchResult(cbs.ContentBlock.ToolUseId, fetch)
 		case "text":
 			thread.Text(blockId, cbs.ContentBlock.Text)
+		default:
+			if strings.HasSuffix(cbs.ContentBlock.Type, "_tool_result") {
+				if err := p.serverToolResult(cbs.ContentBlock, thread); err != nil {
+					return ErrorChunkResult(err)
+				}
+			}
 		}
 	case "content_block_delta":
 		var cbd MessagesStreamContentDelta
@@ -316,8 +598,19 @@ func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 		case "text_delta":
 			thread.Text(blockId, cbd.Delta.Text)
 		case "citations_delta":
-			if cbd.Delta.Citation != nil {
-				thread.Cite(blockId, cbd.Delta.Citation.Url)
+			if c := cbd.Delta.Citation; c != nil {
+				citation := ThreadCitation{
+					Type:      CitationURL,
+					URL:       c.Url,
+					Title:     c.Title,
+					CitedText: c.CitedText,
+				}
+				if c.Type != "web_search_result_location" {
+					citation.Type = CitationDocument
+					citation.DocumentIndex = c.DocumentIndex
+					citation.Title = c.DocumentTitle
+				}
+				thread.Cite(blockId, citation)
 			}
 		case "thinking_delta":
 			thread.Thinking(blockId, cbd.Delta.Thinking)
@@ -337,6 +630,8 @@ func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 					if err := json.Unmarshal([]byte(p.lastToolCall.Buffer), &output); err == nil {
 						thread.ViewWebpageUrl(p.lastToolCall.ID, output.URL)
 					}
+				default:
+					thread.ServerToolInput(p.lastToolCall.ID, cbd.Delta.PartialJSON)
 				}
 			} else {
 				thread.ToolCall(p.lastToolCall.ID, "", cbd.Delta.PartialJSON)
@@ -347,13 +642,122 @@ func (p *MessagesAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
 		if err := json.Unmarshal(data, &cbst); err != nil {
 			return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
 		}
-		thread.Complete(p.blockId(thread, cbst.Index))
+		blockId := p.blockId(thread, cbst.Index)
+		thread.Complete(blockId)
+		// Citations arrive before the text they cover, which is the whole
+		// block.
+		if b := thread.getType(blockId, InferenceBlockText); b != nil {
+			for i := range b.Citations {
+				if b.Citations[i].EndIndex == 0 {
+					b.Citations[i].EndIndex = len(b.Text)
+				}
+			}
+		}
 	case "message_stop":
 		return DoneChunkResult()
 	}
 	return AcceptedResult()
 }
 
+// serverToolResult records the result of a code execution, bash or text
+// editor call, along with the files it produced.
+func (p *MessagesAPIRequest) serverToolResult(block MessagesStreamContentBlock, thread *Thread) *AIError {
+	var result MessagesServerToolResult
+	if err := json.Unmarshal(block.Content, &result); err != nil {
+		return DecodingError(p.Name(), err.Error())
+	}
+	errorCode := ""
+	if strings.HasSuffix(result.Type, "_error") {
+		errorCode = result.ErrorCode
+	}
+	thread.ServerToolResult(block.ToolUseId, block.Type, string(block.Content), errorCode)
+	var outputs []MessagesServerToolOutput
+	if json.Unmarshal(result.Content, &outputs) == nil {
+		for _, output := range outputs {
+			if output.FileId != "" {
+				thread.ServerToolFile(block.ToolUseId, ThreadServerToolFile{ID: output.FileId})
+			}
+		}
+	}
+	return nil
+}
+
+// OnResponse replays a non-streaming message as the stream events that would
+// have produced it, so both transports build identical threads.
+func (p *MessagesAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	events, err := messagesResponseEvents(data)
+	if err != nil {
+		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+	}
+	for _, event := range events {
+		if result := p.OnChunk(event, thread); result.Error != nil || result.Done {
+			return result
+		}
+	}
+	return DoneChunkResult()
+}
+
+func messagesResponseEvents(data []byte) ([][]byte, error) {
+	var resp MessagesResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Type == "error" {
+		return [][]byte{data}, nil
+	}
+
+	events := [][]byte{}
+	add := func(event any) {
+		encoded, _ := json.Marshal(event)
+		events = append(events, encoded)
+	}
+	delta := func(index int, delta map[string]any) {
+		add(map[string]any{"type": "content_block_delta", "index": index, "delta": delta})
+	}
+
+	startUsage := resp.Usage
+	startUsage.OutputTokens = 0
+	add(MessagesStreamMessageStart{
+		Type:    "message_start",
+		Message: MessagesStreamMessage{ID: resp.ID, Usage: startUsage},
+	})
+	for index, raw := range resp.Content {
+		var block MessagesStreamContentBlock
+		if err := json.Unmarshal(raw, &block); err != nil {
+			return nil, err
+		}
+		switch block.Type {
+		case "text":
+			var cited struct {
+				Citations []json.RawMessage `json:"citations"`
+			}
+			json.Unmarshal(raw, &cited)
+			add(map[string]any{"type": "content_block_start", "index": index, "content_block": map[string]any{"type": "text", "text": ""}})
+			for _, citation := range cited.Citations {
+				delta(index, map[string]any{"type": "citations_delta", "citation": citation})
+			}
+			delta(index, map[string]any{"type": "text_delta", "text": block.Text})
+		case "thinking":
+			add(map[string]any{"type": "content_block_start", "index": index, "content_block": map[string]any{"type": "thinking", "thinking": ""}})
+			delta(index, map[string]any{"type": "thinking_delta", "thinking": block.Thinking})
+			delta(index, map[string]any{"type": "signature_delta", "signature": block.Signature})
+		case "tool_use", "server_tool_use":
+			add(map[string]any{"type": "content_block_start", "index": index, "content_block": map[string]any{"type": block.Type, "id": block.ID, "name": block.Name, "input": map[string]any{}}})
+			delta(index, map[string]any{"type": "input_json_delta", "partial_json": string(block.Input)})
+		default:
+			add(map[string]any{"type": "content_block_start", "index": index, "content_block": raw})
+		}
+		add(MessagesStreamContentBlockStop{Type: "content_block_stop", Index: index})
+	}
+	add(MessagesStreamMessageDelta{
+		Type:  "message_delta",
+		Delta: MessagesStreamMessageDeltaData{StopReason: resp.StopReason, StopSequence: resp.StopSequence},
+		Usage: MessagesUsage{OutputTokens: resp.Usage.OutputTokens},
+	})
+	add(MessagesStreamEnvelope{Type: "message_stop"})
+	return events, nil
+}
+
 func (p *MessagesAPIRequest) ParseHttpError(code int, body []byte) *AIError {
 	var message MessagesErrorResponse
 	if err := json.Unmarshal(body, &message); err == nil {
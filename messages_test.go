@@ -0,0 +1,502 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnit_Messages_GroupsParallelToolCalls(t *testing.T) {
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := parallelToolThread()
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[
+			{"type":"text","text":"Weather in Paris and Rome?"}]},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"Two cities.","signature":"sig"},
+			{"type":"text","text":"Checking both."},
+			{"type":"tool_use","name":"weather","id":"call_a","input":{"city":"Paris"}},
+			{"type":"tool_use","name":"weather","id":"call_b","input":{"city":"Rome"}}]},
+		{"role":"user","content":[
+			{"type":"tool_result","tool_use_id":"call_a","content":"sunny"},
+			{"type":"tool_result","tool_use_id":"call_b","content":"rainy"}]},
+		{"role":"assistant","content":[
+			{"type":"text","text":"Paris is sunny, Rome is rainy."}]},
+		{"role":"user","content":[
+			{"type":"text","text":"Thanks","cache_control":{"type":"ephemeral"}}]}
+	]`)
+}
+
+func TestUnit_Messages_SequentialToolCalls(t *testing.T) {
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := sequentialToolThread()
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Plan a day in Paris."}]},
+		{"role":"assistant","content":[{"type":"tool_use","name":"weather","id":"call_a","input":{"city":"Paris"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_a","content":"sunny"}]},
+		{"role":"assistant","content":[{"type":"tool_use","name":"museums","id":"call_b","input":{"city":"Paris"}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_b","content":"Louvre","cache_control":{"type":"ephemeral"}}]},
+		{"role":"assistant","content":[{"type":"text","text":"Sunny, so walk to the Louvre."}]}
+	]`)
+}
+
+func TestUnit_Messages_PendingToolResultsFlushBeforeRequest(t *testing.T) {
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Input("Run it")
+	thread.ToolCall("call_a", "run", `{}`)
+	thread.ToolResult(thread.Blocks[1].ToolCall, "ok")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Run it"}]},
+		{"role":"assistant","content":[{"type":"tool_use","name":"run","id":"call_a","input":{}}]},
+		{"role":"user","content":[{"type":"tool_result","tool_use_id":"call_a","content":"ok","cache_control":{"type":"ephemeral"}}]}
+	]`)
+}
+
+func TestUnit_Messages_CacheBreakpointsWithinLimit(t *testing.T) {
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := parallelToolThread()
+	thread.System("Be brief.")
+	thread.Tools = map[string]ToolDefinition{
+		"weather": {Description: "Weather", Parameters: &JsonSchema{Type: "object"}},
+		"alerts":  {Description: "Alerts", Parameters: &JsonSchema{Type: "object"}},
+	}
+	thread.Cache = &CacheConfig{System: true, Tools: true, LastUserTurns: 5, TTL: CacheTTL1h}
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	var req struct {
+		System []map[string]any `json:"system"`
+		Tools  []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if len(req.System) != 1 || req.System[0]["text"] != "Be brief." || req.System[0]["cache_control"] == nil {
+		t.Errorf("expected cached system block, got %v", req.System)
+	}
+	if req.Tools[0]["name"] != "alerts" || req.Tools[0]["cache_control"] != nil || req.Tools[1]["cache_control"] == nil {
+		t.Errorf("expected sorted tools with breakpoint on the last, got %v", req.Tools)
+	}
+	if count := strings.Count(string(body), `"cache_control":{"type":"ephemeral","ttl":"1h"}`); count != 4 {
+		t.Errorf("expected 4 breakpoints, got %d in %s", count, body)
+	}
+
+	// The two breakpoints left over go on the latest user turns: the new
+	// input and the tool results before it.
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Weather in Paris and Rome?"}]},
+		{"role":"assistant","content":[
+			{"type":"thinking","thinking":"Two cities.","signature":"sig"},
+			{"type":"text","text":"Checking both."},
+			{"type":"tool_use","name":"weather","id":"call_a","input":{"city":"Paris"}},
+			{"type":"tool_use","name":"weather","id":"call_b","input":{"city":"Rome"}}]},
+		{"role":"user","content":[
+			{"type":"tool_result","tool_use_id":"call_a","content":"sunny"},
+			{"type":"tool_result","tool_use_id":"call_b","content":"rainy","cache_control":{"type":"ephemeral","ttl":"1h"}}]},
+		{"role":"assistant","content":[
+			{"type":"text","text":"Paris is sunny, Rome is rainy."}]},
+		{"role":"user","content":[
+			{"type":"text","text":"Thanks","cache_control":{"type":"ephemeral","ttl":"1h"}}]}
+	]`)
+}
+
+func TestUnit_Messages_UsageRecordedPerTurn(t *testing.T) {
+	turns := [][]string{
+		{
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":5,"cache_creation_input_tokens":1000,"cache_creation":{"ephemeral_5m_input_tokens":600,"ephemeral_1h_input_tokens":400},"output_tokens":0}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"call_1","name":"run","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":10}}`,
+			`{"type":"message_stop"}`,
+		},
+		{
+			`{"type":"message_start","message":{"id":"msg_2","usage":{"input_tokens":7,"cache_read_input_tokens":1000,"output_tokens":0}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Done"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		},
+	}
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(turns[requests]...))
+		requests++
+	}))
+	defer server.Close()
+
+	config := AnthropicProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "claude"
+	session.Thread.Tools = map[string]ToolDefinition{"run": {Description: "Run", Parameters: &JsonSchema{Type: "object"}}}
+	session.Thread.HandleToolFunction = func(string, string) string { return "ok" }
+	session.Thread.Input("Go")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	if len(result.Turns) != 2 {
+		t.Fatalf("expected 2 turns, got %d", len(result.Turns))
+	}
+	if result.Turns[0].CacheWriteTokens != 1000 || result.Turns[0].CacheWrite1hTokens != 400 || result.Turns[0].CacheReadTokens != 0 {
+		t.Errorf("unexpected first turn usage %+v", result.Turns[0])
+	}
+	if result.Turns[1].CacheWriteTokens != 0 || result.Turns[1].CacheReadTokens != 1000 {
+		t.Errorf("unexpected second turn usage %+v", result.Turns[1])
+	}
+	if result.Result.CacheWriteTokens != 1000 || result.Result.CacheReadTokens != 1000 {
+		t.Errorf("unexpected total usage %+v", result.Result)
+	}
+}
+
+func TestUnit_Messages_DocumentBlocks(t *testing.T) {
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.InputDocumentBase64("JVBERi0xLjQ=", "application/pdf", "Report", true)
+	thread.InputDocument([]byte("meeting notes"), "text/markdown", "", false)
+	thread.Input("Summarize")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[
+			{"type":"document","title":"Report","citations":{"enabled":true},
+			 "source":{"type":"base64","media_type":"application/pdf","data":"JVBERi0xLjQ="}},
+			{"type":"document","source":{"type":"text","media_type":"text/plain","data":"meeting notes"}},
+			{"type":"text","text":"Summarize","cache_control":{"type":"ephemeral"}}]}
+	]`)
+}
+
+func TestUnit_Messages_Citations(t *testing.T) {
+	stream := sseBody(
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"char_location","cited_text":"Revenue grew 12%.","document_index":1,"document_title":"Q3 report","start_char_index":0,"end_char_index":17}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"citations_delta","citation":{"type":"web_search_result_location","cited_text":"Analysts expected 10%.","url":"https://news.example/q3","title":"Q3 preview","encrypted_index":"abc"}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Revenue grew 12%, "}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"above estimates."}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+		`{"type":"message_stop"}`,
+	)
+	result := runTransportFixture(t, AnthropicProvider("key"), "text/event-stream", stream)
+
+	block := result.Blocks[1]
+	expected := []ThreadCitation{
+		{Type: CitationDocument, DocumentIndex: 1, Title: "Q3 report", CitedText: "Revenue grew 12%.", StartIndex: 0, EndIndex: 34},
+		{Type: CitationURL, URL: "https://news.example/q3", Title: "Q3 preview", CitedText: "Analysts expected 10%.", StartIndex: 0, EndIndex: 34},
+	}
+	if len(block.Citations) != len(expected) {
+		t.Fatalf("expected %d citations, got %+v", len(expected), block.Citations)
+	}
+	for i := range expected {
+		if block.Citations[i] != expected[i] {
+			t.Errorf("citation %d:\ngot  %+v\nwant %+v", i, block.Citations[i], expected[i])
+		}
+	}
+}
+
+// messagesServerToolStream searches twice (the second hitting the use
+// limit), fetches a page and answers.
+var messagesServerToolStream = sseBody(
+	`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}`,
+	`{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{}}}`,
+	`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go 1.24\"}"}}`,
+	`{"type":"content_block_stop","index":0}`,
+	`{"type":"content_block_start","index":1,"content_block":{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[{"type":"web_search_result","url":"https://go.dev/doc/go1.24","title":"Go 1.24 Release Notes","encrypted_content":"EqgfCioIARgB","page_age":"February 11, 2025"}]}}`,
+	`{"type":"content_block_stop","index":1}`,
+	`{"type":"content_block_start","index":2,"content_block":{"type":"server_tool_use","id":"srvtoolu_2","name":"web_search","input":{}}}`,
+	`{"type":"content_block_delta","index":2,"delta":{"type":"input_json_delta","partial_json":"{\"query\":\"go 1.25\"}"}}`,
+	`{"type":"content_block_stop","index":2}`,
+	`{"type":"content_block_start","index":3,"content_block":{"type":"web_search_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"web_search_tool_result_error","error_code":"max_uses_exceeded"}}}`,
+	`{"type":"content_block_stop","index":3}`,
+	`{"type":"content_block_start","index":4,"content_block":{"type":"server_tool_use","id":"srvtoolu_3","name":"web_fetch","input":{}}}`,
+	`{"type":"content_block_delta","index":4,"delta":{"type":"input_json_delta","partial_json":"{\"url\":\"https://go.dev/doc/go1.24\"}"}}`,
+	`{"type":"content_block_stop","index":4}`,
+	`{"type":"content_block_start","index":5,"content_block":{"type":"web_fetch_tool_result","tool_use_id":"srvtoolu_3","content":{"type":"web_fetch_result","url":"https://go.dev/doc/go1.24","retrieved_at":"2025-08-25T10:30:00Z","content":{"type":"document","source":{"type":"text","media_type":"text/plain","data":"Go 1.24 adds generic type aliases."},"title":"Go 1.24 Release Notes"}}}}`,
+	`{"type":"content_block_stop","index":5}`,
+	`{"type":"content_block_start","index":6,"content_block":{"type":"text","text":""}}`,
+	`{"type":"content_block_delta","index":6,"delta":{"type":"text_delta","text":"Generic type aliases."}}`,
+	`{"type":"content_block_stop","index":6}`,
+	`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+	`{"type":"message_stop"}`,
+)
+
+func TestUnit_Messages_ServerToolResultDetails(t *testing.T) {
+	result := runTransportFixture(t, AnthropicProvider("key"), "text/event-stream", messagesServerToolStream)
+
+	if result.Result.WebSearches != 2 || result.Result.PageViews != 1 {
+		t.Errorf("expected 2 searches and 1 page view, got %+v", result.Result)
+	}
+	search := result.Blocks[1].WebSearch
+	if search == nil || search.Query != "go 1.24" || len(search.Results) != 1 {
+		t.Fatalf("unexpected search %+v", search)
+	}
+	want := ThreadWebSearchResult{Title: "Go 1.24 Release Notes", URL: "https://go.dev/doc/go1.24", PageAge: "February 11, 2025", EncryptedContent: "EqgfCioIARgB"}
+	if search.Results[0] != want {
+		t.Errorf("unexpected result %+v", search.Results[0])
+	}
+	if failed := result.Blocks[2].WebSearch; failed.ErrorCode != "max_uses_exceeded" || !result.Blocks[2].Complete {
+		t.Errorf("expected the failed search to be recorded, got %+v", failed)
+	}
+	view := result.Blocks[3]
+	if view.Type != InferenceBlockViewWebpage || view.WebFetch == nil {
+		t.Fatalf("expected a fetch result, got %+v", view)
+	}
+	if view.WebFetch.Title() != "Go 1.24 Release Notes" || view.WebFetch.Content() != "Go 1.24 adds generic type aliases." || view.WebFetch.RetrievedAt != "2025-08-25T10:30:00Z" {
+		t.Errorf("unexpected fetch %+v", view.WebFetch)
+	}
+}
+
+func TestUnit_Messages_MalformedServerToolResult(t *testing.T) {
+	for _, block := range []string{
+		`{"type":"web_fetch_tool_result","tool_use_id":"srvtoolu_1","content":"not an object"}`,
+		`{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":"not a list"}`,
+		`{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":[1]}`,
+	} {
+		config := AnthropicProvider("key")
+		p := &MessagesAPIRequest{Config: &config}
+		thread := &Thread{Model: "m"}
+		p.InitSession(thread)
+
+		result := p.OnChunk([]byte(`{"type":"content_block_start","index":0,"content_block":`+block+`}`), thread)
+		if err, ok := result.Error.(*AIError); !ok || err.Category != AIErrorCategoryDecodingError {
+			t.Errorf("expected a decoding error for %s, got %+v", block, result)
+		}
+	}
+}
+
+func TestUnit_Messages_RestoredThreadReplaysServerTools(t *testing.T) {
+	result := runTransportFixture(t, AnthropicProvider("key"), "text/event-stream", messagesServerToolStream)
+
+	config := AnthropicProvider("key")
+	p := &MessagesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Restore(result.Snapshot())
+	thread.Input("Thanks")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Hello"}]},
+		{"role":"assistant","content":[
+			{"type":"server_tool_use","name":"web_search","id":"srvtoolu_1","input":{"query":"go 1.24"}},
+			{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[
+				{"type":"web_search_result","url":"https://go.dev/doc/go1.24","title":"Go 1.24 Release Notes","encrypted_content":"EqgfCioIARgB","page_age":"February 11, 2025"}]},
+			{"type":"server_tool_use","name":"web_search","id":"srvtoolu_2","input":{"query":"go 1.25"}},
+			{"type":"web_search_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"web_search_tool_result_error","error_code":"max_uses_exceeded"}},
+			{"type":"server_tool_use","name":"web_fetch","id":"srvtoolu_3","input":{"url":"https://go.dev/doc/go1.24"}},
+			{"type":"web_fetch_tool_result","tool_use_id":"srvtoolu_3","content":{"type":"web_fetch_result","url":"https://go.dev/doc/go1.24","retrieved_at":"2025-08-25T10:30:00Z",
+				"content":{"type":"document","source":{"type":"text","media_type":"text/plain","data":"Go 1.24 adds generic type aliases."},"title":"Go 1.24 Release Notes"}}},
+			{"type":"text","text":"Generic type aliases."}]},
+		{"role":"user","content":[{"type":"text","text":"Thanks","cache_control":{"type":"ephemeral"}}]}
+	]`)
+}
+
+func TestUnit_Messages_CodeExecution(t *testing.T) {
+	var req map[string]any
+	var beta string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		beta = r.Header.Get("anthropic-beta")
+		json.NewDecoder(r.Body).Decode(&req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"server_tool_use","id":"srvtoolu_1","name":"bash_code_execution","input":{}}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"{\"command\":"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"input_json_delta","partial_json":"\"python plot.py\"}"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"bash_code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"bash_code_execution_result","stdout":"saved","stderr":"","return_code":0,"content":[{"type":"bash_code_execution_output","file_id":"file_1"}]}}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"content_block_start","index":2,"content_block":{"type":"server_tool_use","id":"srvtoolu_2","name":"text_editor_code_execution","input":{"command":"view","path":"missing.py"}}}`,
+			`{"type":"content_block_stop","index":2}`,
+			`{"type":"content_block_start","index":3,"content_block":{"type":"text_editor_code_execution_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"text_editor_code_execution_tool_result_error","error_code":"file_not_found"}}}`,
+			`{"type":"content_block_stop","index":3}`,
+			`{"type":"content_block_start","index":4,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":4,"delta":{"type":"text_delta","text":"Plotted."}}`,
+			`{"type":"content_block_stop","index":4}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		))
+	}))
+	defer server.Close()
+
+	config := AnthropicProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "test-model"
+	session.Thread.CodeExecution = true
+	session.Thread.Input("Plot it")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("request failed: %s", result.Error)
+	}
+
+	if !strings.Contains(beta, "code-execution-2025-08-25") {
+		t.Errorf("expected the code execution beta, got %q", beta)
+	}
+	tools, _ := req["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["type"] != "code_execution_20250825" {
+		t.Errorf("expected the code execution tool, got %v", tools)
+	}
+
+	bash, editor := result.Blocks[1], result.Blocks[2]
+	if bash.Type != InferenceBlockServerTool || !bash.Complete || bash.ServerTool.Name != "bash_code_execution" ||
+		bash.ServerTool.Input != `{"command":"python plot.py"}` || bash.ServerTool.ResultType != "bash_code_execution_tool_result" {
+		t.Errorf("unexpected bash block %+v", bash.ServerTool)
+	}
+	if len(bash.ServerTool.Files) != 1 || bash.ServerTool.Files[0].ID != "file_1" {
+		t.Errorf("expected the produced file, got %+v", bash.ServerTool.Files)
+	}
+	if editor.ServerTool.ErrorCode != "file_not_found" || editor.ServerTool.Input != `{"command":"view","path":"missing.py"}` {
+		t.Errorf("unexpected editor block %+v", editor.ServerTool)
+	}
+
+	p := &MessagesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Restore(result.Snapshot())
+	thread.Input("Thanks")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+	assertGoldenMessages(t, body, `[
+		{"role":"user","content":[{"type":"text","text":"Plot it"}]},
+		{"role":"assistant","content":[
+			{"type":"server_tool_use","name":"bash_code_execution","id":"srvtoolu_1","input":{"command":"python plot.py"}},
+			{"type":"bash_code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"bash_code_execution_result","stdout":"saved","stderr":"","return_code":0,"content":[{"type":"bash_code_execution_output","file_id":"file_1"}]}},
+			{"type":"server_tool_use","name":"text_editor_code_execution","id":"srvtoolu_2","input":{"command":"view","path":"missing.py"}},
+			{"type":"text_editor_code_execution_tool_result","tool_use_id":"srvtoolu_2","content":{"type":"text_editor_code_execution_tool_result_error","error_code":"file_not_found"}},
+			{"type":"text","text":"Plotted."}]},
+		{"role":"user","content":[{"type":"text","text":"Thanks","cache_control":{"type":"ephemeral"}}]}
+	]`)
+}
+
+func TestUnit_Messages_CountTokens(t *testing.T) {
+	var path, version string
+	var req map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, version = r.URL.Path, r.Header.Get("anthropic-version")
+		json.NewDecoder(r.Body).Decode(&req)
+		io.WriteString(w, `{"input_tokens":42}`)
+	}))
+	defer server.Close()
+
+	config := AnthropicProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "test-model"
+	session.Thread.System("Be brief.")
+	session.Thread.Input("Hello")
+	count, err := session.CountTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 42 || path != "/v1/messages/count_tokens" || version == "" {
+		t.Errorf("unexpected count %d from %s", count, path)
+	}
+	if _, ok := req["max_tokens"]; ok || req["system"] == nil || len(req["messages"].([]any)) != 1 {
+		t.Errorf("unexpected count request %v", req)
+	}
+	if len(session.Thread.Blocks) != 2 || session.Thread.Result.InputTokens != 0 {
+		t.Errorf("counting should not change the thread")
+	}
+
+	// A custom Endpoint is the messages URL itself; counting must not post
+	// the prompt there.
+	path = ""
+	config.Endpoint = server.URL + "/proxy/messages"
+	custom := config.Session()
+	custom.Thread.Model = "test-model"
+	custom.Thread.Input("Hello")
+	if _, err := custom.CountTokens(); err == nil || path != "" {
+		t.Errorf("expected an error without a request for a custom endpoint, got %v from %q", err, path)
+	}
+
+	openai := OpenAIProvider("key")
+	if _, err := openai.Session().CountTokens(); err == nil {
+		t.Errorf("expected an error for providers without token counting")
+	}
+}
+
+func TestUnit_Messages_TurnRecords(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"cache_read_input_tokens":500,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			// message_delta repeats the message totals.
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"input_tokens":10,"cache_read_input_tokens":500,"output_tokens":25}}`,
+			`{"type":"message_stop"}`,
+		))
+	}))
+	defer server.Close()
+
+	config := AnthropicProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "claude-test"
+	session.Thread.Input("Hello")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	want := ThreadUsage{InputTokens: 10, CacheReadTokens: 500, OutputTokens: 25}
+	if result.Result != want {
+		t.Errorf("expected usage counted once, got %+v", result.Result)
+	}
+	if len(result.Turns) != 1 {
+		t.Fatalf("expected one turn, got %d", len(result.Turns))
+	}
+	turn := result.Turns[0]
+	if turn.ThreadUsage != want || turn.Model != "claude-test" || turn.Provider != "messages.anthropic" || turn.StopReason != "end_turn" {
+		t.Errorf("unexpected turn %+v", turn)
+	}
+	if turn.StartedAt.IsZero() || turn.Latency <= 0 || turn.TimeToFirstToken <= 0 || turn.TimeToFirstToken > turn.Latency {
+		t.Errorf("unexpected timings %+v", turn)
+	}
+}
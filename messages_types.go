@@ -14,13 +14,28 @@ type MessagesRequest struct {
 	Model        string                `json:"model"`
 	Messages     []MessagesMessage     `json:"messages"`
 	Tools        []map[string]any      `json:"tools"`
-	System       string                `json:"system"`
+	System       any                   `json:"system"`
 	MaxTokens    int64                 `json:"max_tokens"`
 	Thinking     *MessagesThinking     `json:"thinking,omitempty"`
 	OutputFormat *MessagesOutputFormat `json:"output_format,omitempty"`
 	Stream       bool                  `json:"stream"`
 }
 
+// MessagesCountTokensRequest is the body of count_tokens: a message request
+// without the generation settings.
+type MessagesCountTokensRequest struct {
+	Model        string                `json:"model"`
+	Messages     []MessagesMessage     `json:"messages"`
+	Tools        []map[string]any      `json:"tools,omitempty"`
+	System       any                   `json:"system,omitempty"`
+	Thinking     *MessagesThinking     `json:"thinking,omitempty"`
+	OutputFormat *MessagesOutputFormat `json:"output_format,omitempty"`
+}
+
+type MessagesCountTokensResponse struct {
+	InputTokens int64 `json:"input_tokens"`
+}
+
 type MessagesOutputFormat struct {
 	Type   string      `json:"type"`
 	Schema *JsonSchema `json:"schema"`
@@ -31,9 +46,12 @@ type MessagesMessage struct {
 }
 type MessagesCacheControl struct {
 	Type string `json:"type"`
+	TTL  string `json:"ttl,omitempty"`
 }
 
-// MessagesImageSource represents an image source for the Anthropic Messages API.
+// MessagesImageSource represents an image or document source for the
+// Anthropic Messages API. Documents use "base64" for PDFs and "text" with
+// the raw text in Data for plain text.
 type MessagesImageSource struct {
 	Type      string `json:"type"`       // "base64"
 	MediaType string `json:"media_type"` // e.g., "image/jpeg"
@@ -50,20 +68,37 @@ type MessagesResult struct {
 	Usage      MessagesUsage     `json:"usage"`
 	StopReason string            `json:"stop_reason"`
 }
+
+// MessagesResponse is a complete non-streaming message. Content blocks are
+// kept raw so they can be replayed as stream events.
+type MessagesResponse struct {
+	ID           string            `json:"id"`
+	Type         string            `json:"type"`
+	Content      []json.RawMessage `json:"content"`
+	StopReason   string            `json:"stop_reason"`
+	StopSequence string            `json:"stop_sequence"`
+	Usage        MessagesUsage     `json:"usage"`
+}
 type MessagesContent struct {
-	Type         string                    `json:"type"`
-	Thinking     string                    `json:"thinking,omitempty"`
-	Signature    string                    `json:"signature,omitempty"`
-	Data         string                    `json:"data,omitempty"`
-	Text         string                    `json:"text,omitempty"`
-	Name         string                    `json:"name,omitempty"`
-	Id           string                    `json:"id,omitempty"`
-	Input        json.RawMessage           `json:"input,omitempty"`
-	ToolUseId    string                    `json:"tool_use_id,omitempty"`
-	Content      any                       `json:"content,omitempty"`
-	Source       *MessagesImageSource      `json:"source,omitempty"`
-	CacheControl *MessagesCacheControl     `json:"cache_control,omitempty"`
-	Citations    []MessagesContentCitation `json:"citations,omitempty"`
+	Type         string                   `json:"type"`
+	Thinking     string                   `json:"thinking,omitempty"`
+	Signature    string                   `json:"signature,omitempty"`
+	Data         string                   `json:"data,omitempty"`
+	Text         string                   `json:"text,omitempty"`
+	Name         string                   `json:"name,omitempty"`
+	Id           string                   `json:"id,omitempty"`
+	Input        json.RawMessage          `json:"input,omitempty"`
+	ToolUseId    string                   `json:"tool_use_id,omitempty"`
+	Content      any                      `json:"content,omitempty"`
+	Source       *MessagesImageSource     `json:"source,omitempty"`
+	CacheControl *MessagesCacheControl    `json:"cache_control,omitempty"`
+	Title        string                   `json:"title,omitempty"`
+	Citations    *MessagesCitationsConfig `json:"citations,omitempty"`
+}
+
+// MessagesCitationsConfig enables citations on a document content block.
+type MessagesCitationsConfig struct {
+	Enabled bool `json:"enabled"`
 }
 type MessagesContentCitation struct {
 	Url            string `json:"url"`
@@ -74,8 +109,15 @@ type MessagesUsage struct {
 	CacheReadInputTokens     int64                    `json:"cache_read_input_tokens"`
 	CacheCreationInputTokens int64                    `json:"cache_creation_input_tokens"`
 	OutputTokens             int64                    `json:"output_tokens"`
+	CacheCreation            *MessagesCacheCreation   `json:"cache_creation,omitempty"`
 	ServerToolUse            *MessagesServerToolUsage `json:"server_tool_use,omitempty"`
 }
+
+// MessagesCacheCreation splits cache_creation_input_tokens by TTL.
+type MessagesCacheCreation struct {
+	Ephemeral5mInputTokens int64 `json:"ephemeral_5m_input_tokens"`
+	Ephemeral1hInputTokens int64 `json:"ephemeral_1h_input_tokens"`
+}
 type MessagesServerToolUsage struct {
 	WebSearchRequests int64 `json:"web_search_requests,omitempty"`
 }
@@ -115,16 +157,17 @@ type MessagesStreamMessageDelta struct {
 }
 
 type MessagesStreamContentBlock struct {
-	Type      string                           `json:"type"`
-	Text      string                           `json:"text,omitempty"`
-	Thinking  string                           `json:"thinking,omitempty"`
-	Signature string                           `json:"signature,omitempty"`
-	Data      string                           `json:"data,omitempty"`
-	Name      string                           `json:"name,omitempty"`
-	ID        string                           `json:"id,omitempty"`
-	ToolUseId string                           `json:"tool_use_id,omitempty"`
-	Input     json.RawMessage                  `json:"input,omitempty"`
-	Content   []MessagesStreamContentDeltaData `json:"content,omitempty"`
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	Thinking  string          `json:"thinking,omitempty"`
+	Signature string          `json:"signature,omitempty"`
+	Data      string          `json:"data,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	ToolUseId string          `json:"tool_use_id,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	// Content is a result list or an error object on server tool results.
+	Content json.RawMessage `json:"content,omitempty"`
 }
 
 type MessagesStreamContentBlockStart struct {
@@ -151,8 +194,18 @@ type MessagesStreamContentDeltaData struct {
 	URL         string                              `json:"url,omitempty"`
 	Citation    *MessagesStreamContentDeltaCitation `json:"citation,omitempty"`
 }
+
+// MessagesStreamContentDeltaCitation is one citation of a text block. The
+// fields present depend on Type: web_search_result_location has the URL and
+// title, while char_location, page_location and content_block_location
+// point into a document.
 type MessagesStreamContentDeltaCitation struct {
-	Url string `json:"url"`
+	Type          string `json:"type"`
+	Url           string `json:"url,omitempty"`
+	Title         string `json:"title,omitempty"`
+	CitedText     string `json:"cited_text,omitempty"`
+	DocumentIndex int    `json:"document_index,omitempty"`
+	DocumentTitle string `json:"document_title,omitempty"`
 }
 
 type MessagesStreamContentBlockDelta struct {
@@ -178,6 +231,44 @@ type MessagesBuilderBlock struct {
 	ToolInput string `json:"input,omitempty"`
 }
 
+// MessagesWebSearchResult is one entry of a web_search_tool_result.
+type MessagesWebSearchResult struct {
+	Type             string `json:"type"`
+	URL              string `json:"url"`
+	Title            string `json:"title"`
+	EncryptedContent string `json:"encrypted_content,omitempty"`
+	PageAge          string `json:"page_age,omitempty"`
+}
+
+// MessagesWebFetchResult is the content of a web_fetch_tool_result. Failed
+// fetches have type web_fetch_tool_error and only ErrorCode set.
+type MessagesWebFetchResult struct {
+	Type        string           `json:"type"`
+	URL         string           `json:"url,omitempty"`
+	Content     *MessagesContent `json:"content,omitempty"`
+	RetrievedAt string           `json:"retrieved_at,omitempty"`
+	ErrorCode   string           `json:"error_code,omitempty"`
+}
+
+// MessagesServerToolError is the content of a failed server tool result.
+type MessagesServerToolError struct {
+	Type      string `json:"type"`
+	ErrorCode string `json:"error_code"`
+}
+
+// MessagesServerToolResult is the content of a code execution, bash or text
+// editor result. Content lists the produced files on execution results.
+type MessagesServerToolResult struct {
+	Type      string          `json:"type"`
+	ErrorCode string          `json:"error_code,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+}
+
+type MessagesServerToolOutput struct {
+	Type   string `json:"type"`
+	FileId string `json:"file_id"`
+}
+
 type MessagesWebSearchQuery struct {
 	Query string `json:"query"`
 }
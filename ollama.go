@@ -0,0 +1,255 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// OllamaAPIRequest implements Ollama's native /api/chat endpoint, which
+// streams newline-delimited JSON rather than SSE.
+type OllamaAPIRequest struct {
+	Config *ProviderConfig
+
+	request    OllamaRequest
+	textId     string
+	thinkingId string
+}
+
+func (p *OllamaAPIRequest) Name() string {
+	return fmt.Sprintf("ollama.%s", p.Config.Name)
+}
+
+func (p *OllamaAPIRequest) Transport() GatewayTransport {
+	return p.Config.transport(TransportNDJSON)
+}
+
+func (p *OllamaAPIRequest) PrepareForUpdates() {}
+
+func (p *OllamaAPIRequest) InitSession(thread *Thread) {
+	tools := make([]map[string]any, 0)
+	toolDefs := thread.toolDefinitions()
+	for name := range toolDefs {
+		toolSpec := map[string]any{}
+		toolSpec["description"] = toolDefs[name].Description
+		toolSpec["parameters"] = toolDefs[name].Parameters
+		toolSpec["name"] = name
+		tools = append(tools, map[string]any{
+			"type":     "function",
+			"function": toolSpec,
+		})
+	}
+
+	p.request = OllamaRequest{
+		Messages: []OllamaMessage{},
+		Model:    thread.Model,
+		Tools:    tools,
+		Stream:   p.Config.streaming(),
+		Format:   thread.StructuredOutputSchemaValueWithoutAdditionalProperties(),
+	}
+	if thread.Reasoning.Effort != "" {
+		p.request.Think = thread.Reasoning.Effort
+	} else if thread.Reasoning.Budget > 0 {
+		p.request.Think = true
+	}
+	if p.Config.MaxTokens > 0 {
+		p.request.Options = &OllamaOptions{NumPredict: p.Config.MaxTokens}
+	}
+}
+
+// lastAssistant returns the trailing assistant message if new assistant
+// content can still be merged into it.
+func (p *OllamaAPIRequest) lastAssistant() *OllamaMessage {
+	if len(p.request.Messages) == 0 {
+		return nil
+	}
+	last := &p.request.Messages[len(p.request.Messages)-1]
+	if last.Role != "assistant" || len(last.ToolCalls) > 0 {
+		return nil
+	}
+	return last
+}
+
+func (p *OllamaAPIRequest) Update(block *ThreadBlock) {
+	switch block.Type {
+	case InferenceBlockSystem:
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:    "system",
+			Content: block.Text,
+		})
+	case InferenceBlockInput:
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:    "user",
+			Content: block.Text,
+		})
+	case InferenceBlockInputImage:
+		if block.Image == nil {
+			return
+		}
+		// Append to last user message if exists, else create new
+		if len(p.request.Messages) > 0 {
+			lastIdx := len(p.request.Messages) - 1
+			if p.request.Messages[lastIdx].Role == "user" {
+				p.request.Messages[lastIdx].Images = append(p.request.Messages[lastIdx].Images, block.Image.GetBase64())
+				return
+			}
+		}
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:   "user",
+			Images: []string{block.Image.GetBase64()},
+		})
+	case InferenceBlockInputDocument:
+		if block.Document == nil {
+			return
+		}
+		text := block.Document.textFallback()
+		if len(p.request.Messages) > 0 {
+			lastIdx := len(p.request.Messages) - 1
+			if p.request.Messages[lastIdx].Role == "user" {
+				if p.request.Messages[lastIdx].Content != "" {
+					text = p.request.Messages[lastIdx].Content + "\n\n" + text
+				}
+				p.request.Messages[lastIdx].Content = text
+				return
+			}
+		}
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:    "user",
+			Content: text,
+		})
+	case InferenceBlockThinking:
+		if last := p.lastAssistant(); last != nil && last.Content == "" {
+			last.Thinking += block.Text
+			return
+		}
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:     "assistant",
+			Thinking: block.Text,
+		})
+	case InferenceBlockText:
+		if last := p.lastAssistant(); last != nil {
+			last.Content += block.Text
+			return
+		}
+		p.request.Messages = append(p.request.Messages, OllamaMessage{
+			Role:    "assistant",
+			Content: block.Text,
+		})
+	case InferenceBlockToolCall:
+		args := json.RawMessage(block.ToolCall.Arguments)
+		if !json.Valid(args) {
+			args = json.RawMessage("{}")
+		}
+		call := OllamaToolCall{
+			Function: OllamaToolCallFunction{
+				Name:      block.ToolCall.Name,
+				Arguments: args,
+			},
+		}
+		if last := p.lastAssistant(); last != nil {
+			last.ToolCalls = append(last.ToolCalls, call)
+		} else {
+			p.request.Messages = append(p.request.Messages, OllamaMessage{
+				Role:      "assistant",
+				ToolCalls: []OllamaToolCall{call},
+			})
+		}
+		if block.ToolResult != nil {
+			p.request.Messages = append(p.request.Messages, OllamaMessage{
+				Role:     "tool",
+				Content:  block.ToolResult.Output,
+				ToolName: block.ToolCall.Name,
+			})
+		}
+	}
+}
+
+func (p *OllamaAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	p.textId = ""
+	p.thinkingId = ""
+
+	endpoint := p.Config.resolveEndpoint("/api/chat")
+	body, _ := json.Marshal(p.request)
+	providerReq, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+	providerReq.Header.Add("Content-Type", "application/json")
+	if p.request.Stream {
+		providerReq.Header.Add("Accept", "application/x-ndjson")
+	} else {
+		providerReq.Header.Add("Accept", "application/json")
+	}
+	if p.Config.APIKey != "" {
+		p.Config.authorize(providerReq)
+	}
+	return providerReq
+}
+
+func (p *OllamaAPIRequest) OnChunk(data []byte, thread *Thread) ChunkResult {
+	var chunk OllamaChatResponse
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return ErrorChunkResult(DecodingError(p.Name(), err.Error()))
+	}
+	if chunk.Error != "" {
+		return ErrorChunkResult(UnknownError(p.Name(), chunk.Error))
+	}
+
+	if chunk.Message.Thinking != "" {
+		if p.thinkingId == "" {
+			p.thinkingId = thread.NewBlockId(InferenceBlockThinking)
+		}
+		thread.Thinking(p.thinkingId, chunk.Message.Thinking)
+	}
+	if chunk.Message.Content != "" {
+		if p.thinkingId != "" {
+			thread.Complete(p.thinkingId)
+		}
+		if p.textId == "" {
+			p.textId = thread.NewBlockId(InferenceBlockText)
+		}
+		thread.Text(p.textId, chunk.Message.Content)
+	}
+	for _, tc := range chunk.Message.ToolCalls {
+		args := string(tc.Function.Arguments)
+		if args == "" || args == "null" {
+			args = "{}"
+		}
+		thread.ToolCall(thread.NewBlockId(InferenceBlockToolCall), tc.Function.Name, args)
+	}
+
+	if chunk.Done {
+		thread.Result.InputTokens += chunk.PromptEvalCount
+		thread.Result.OutputTokens += chunk.EvalCount
+		thread.stopReason = chunk.DoneReason
+		if p.thinkingId != "" {
+			thread.Complete(p.thinkingId)
+		}
+		if p.textId != "" {
+			thread.Complete(p.textId)
+		}
+		return DoneChunkResult()
+	}
+	return AcceptedResult()
+}
+
+// OnResponse handles a non-streaming reply, which is a single final chunk
+// carrying the whole message.
+func (p *OllamaAPIRequest) OnResponse(data []byte, thread *Thread) ChunkResult {
+	return p.OnChunk(data, thread)
+}
+
+func (p *OllamaAPIRequest) ParseHttpError(code int, body []byte) *AIError {
+	var errResp OllamaErrorResponse
+	message := string(body)
+	if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+		message = errResp.Error
+	}
+	switch code {
+	case 401, 403:
+		return AuthenticationError(p.Name(), message)
+	case 400, 404:
+		return ConfigurationError(p.Name(), message)
+	case 429:
+		return RateLimitError(p.Name(), message)
+	}
+	return UnknownError(p.Name(), message)
+}
@@ -0,0 +1,129 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnit_NDJSON_ReadsLines(t *testing.T) {
+	lines := []string{}
+	err := readNDJSON("test", strings.NewReader("{\"a\":1}\n\n{\"b\":2}\r\n{\"c\":3}"), func(line []byte) (bool, error) {
+		lines = append(lines, string(line))
+		return true, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(lines, "|") != `{"a":1}|{"b":2}|{"c":3}` {
+		t.Errorf("unexpected lines %v", lines)
+	}
+}
+
+func TestUnit_Ollama_RequestShape(t *testing.T) {
+	config := OllamaProvider("")
+	p := &OllamaAPIRequest{Config: &config}
+	thread := &Thread{
+		Model:                  "qwen3",
+		Reasoning:              ReasoningConfig{Effort: "low"},
+		StructuredOutputSchema: exampleStructuredSchema(),
+		Tools: map[string]ToolDefinition{
+			"lookup": {Description: "Lookup", Parameters: &JsonSchema{Type: "object"}},
+		},
+	}
+	thread.Input("Describe this")
+	thread.InputImageBase64("aGVsbG8=", "image/png")
+
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	req := p.MakeRequest(thread)
+	if req.URL.String() != "http://localhost:11434/api/chat" {
+		t.Errorf("unexpected URL %s", req.URL.String())
+	}
+
+	var body map[string]any
+	data, _ := io.ReadAll(req.Body)
+	json.Unmarshal(data, &body)
+	if body["think"] != "low" {
+		t.Errorf("expected think=low, got %v", body["think"])
+	}
+	if body["format"] == nil {
+		t.Error("expected format schema")
+	}
+	messages := body["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("expected image merged into user message, got %d messages", len(messages))
+	}
+	images := messages[0].(map[string]any)["images"].([]any)
+	if len(images) != 1 || images[0] != "aGVsbG8=" {
+		t.Errorf("unexpected images %v", images)
+	}
+}
+
+func TestUnit_Ollama_StreamWithToolCall(t *testing.T) {
+	turns := [][]string{
+		{
+			`{"model":"qwen3","message":{"role":"assistant","content":"","thinking":"Need the weather."},"done":false}`,
+			`{"model":"qwen3","message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"weather","arguments":{"city":"Paris"}}}]},"done":false}`,
+			`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":20,"eval_count":8}`,
+		},
+		{
+			`{"model":"qwen3","message":{"role":"assistant","content":"It is "},"done":false}`,
+			`{"model":"qwen3","message":{"role":"assistant","content":"sunny."},"done":false}`,
+			`{"model":"qwen3","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":40,"eval_count":4}`,
+		},
+	}
+	requests := []OllamaRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req OllamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for _, line := range turns[len(requests)-1] {
+			io.WriteString(w, line+"\n")
+		}
+	}))
+	defer server.Close()
+
+	config := OllamaProvider(server.URL)
+	session := config.Session()
+	session.Thread.Model = "qwen3"
+	session.Thread.Tools = map[string]ToolDefinition{
+		"weather": {Description: "Weather", Parameters: &JsonSchema{Type: "object"}},
+	}
+	session.Thread.HandleToolFunction = func(name string, args string) string {
+		if name != "weather" || args != `{"city":"Paris"}` {
+			t.Errorf("unexpected tool call %s(%s)", name, args)
+		}
+		return "sunny"
+	}
+	session.Thread.Input("Weather in Paris?")
+
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+	second := requests[1].Messages
+	if len(second) != 3 || second[1].Thinking != "Need the weather." || len(second[1].ToolCalls) != 1 {
+		t.Fatalf("unexpected replayed messages %+v", second)
+	}
+	if second[2].Role != "tool" || second[2].ToolName != "weather" || second[2].Content != "sunny" {
+		t.Errorf("unexpected tool message %+v", second[2])
+	}
+
+	last := result.Blocks[len(result.Blocks)-1]
+	if last.Type != InferenceBlockText || last.Text != "It is sunny." || !last.Complete {
+		t.Errorf("unexpected final block %+v", last)
+	}
+	if result.Result.InputTokens != 60 || result.Result.OutputTokens != 12 {
+		t.Errorf("unexpected usage %+v", result.Result)
+	}
+}
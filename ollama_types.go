@@ -0,0 +1,45 @@
+package aikit
+
+import "encoding/json"
+
+type OllamaRequest struct {
+	Model    string           `json:"model"`
+	Messages []OllamaMessage  `json:"messages"`
+	Tools    []map[string]any `json:"tools,omitempty"`
+	Think    any              `json:"think,omitempty"`
+	Format   *JsonSchema      `json:"format,omitempty"`
+	Options  *OllamaOptions   `json:"options,omitempty"`
+	Stream   bool             `json:"stream"`
+}
+type OllamaOptions struct {
+	NumPredict int64 `json:"num_predict,omitempty"`
+}
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+type OllamaToolCallFunction struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type OllamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason,omitempty"`
+	PromptEvalCount int64         `json:"prompt_eval_count,omitempty"`
+	EvalCount       int64         `json:"eval_count,omitempty"`
+	Error           string        `json:"error,omitempty"`
+}
+
+type OllamaErrorResponse struct {
+	Error string `json:"error"`
+}
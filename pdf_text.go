@@ -0,0 +1,247 @@
+package aikit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Limits on decompressing content streams, so a small crafted PDF can't
+// inflate to gigabytes. Text past the limits is left out.
+const (
+	pdfMaxStreamBytes  = 4 << 20
+	pdfMaxDecodedBytes = 32 << 20
+	pdfMaxTextBytes    = 8 << 20
+)
+
+// extractPDFText recovers the text drawn by a PDF's content streams. It is a
+// best-effort fallback for providers without native PDF input: it reads the
+// string operands of the text operators (Tj, TJ, ' and ") and inserts line
+// breaks on text positioning, which is enough for most generated documents.
+func extractPDFText(data []byte) string {
+	var out strings.Builder
+	decoded := 0
+	for pos := 0; decoded < pdfMaxDecodedBytes && out.Len() < pdfMaxTextBytes; {
+		idx := bytes.Index(data[pos:], []byte("stream"))
+		if idx < 0 {
+			break
+		}
+		keyword := pos + idx
+		pos = keyword + len("stream")
+		if keyword >= 3 && string(data[keyword-3:keyword]) == "end" {
+			continue
+		}
+		start := pos
+		if bytes.HasPrefix(data[start:], []byte("\r\n")) {
+			start += 2
+		} else if bytes.HasPrefix(data[start:], []byte("\n")) {
+			start++
+		} else {
+			continue
+		}
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		pos = start + end
+
+		// The stream dictionary sits between the object header and the
+		// stream keyword.
+		dict := data[:keyword]
+		if header := bytes.LastIndex(dict, []byte("obj")); header >= 0 {
+			dict = dict[header:]
+		}
+		if bytes.Contains(dict, []byte("/Subtype")) || bytes.Contains(dict, []byte("/Type")) {
+			// Images, fonts and object streams, not page content.
+			continue
+		}
+		stream := data[start : start+end]
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			r, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			// Truncated streams still yield their decoded prefix.
+			limit := min(pdfMaxStreamBytes, pdfMaxDecodedBytes-decoded)
+			stream, _ = io.ReadAll(io.LimitReader(r, int64(limit)))
+			decoded += len(stream)
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			continue
+		}
+		pdfContentText(stream, &out)
+	}
+	text := out.String()
+	if len(text) > pdfMaxTextBytes {
+		text = strings.ToValidUTF8(text[:pdfMaxTextBytes], "")
+	}
+	return strings.TrimSpace(text)
+}
+
+// pdfContentText appends the text shown by one content stream to out.
+func pdfContentText(stream []byte, out *strings.Builder) {
+	var operands []string
+	var numbers []float64
+	newline := func() {
+		if out.Len() > 0 && !strings.HasSuffix(out.String(), "\n") {
+			out.WriteByte('\n')
+		}
+	}
+
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '(':
+			text, next := pdfLiteralString(stream, i)
+			operands = append(operands, text)
+			i = next
+		case c == '<' && i+1 < len(stream) && stream[i+1] != '<':
+			end := bytes.IndexByte(stream[i:], '>')
+			if end < 0 {
+				return
+			}
+			operands = append(operands, pdfHexString(stream[i+1:i+end]))
+			i += end + 1
+		case c == '[':
+			// TJ arrays interleave strings with kerning. Large negative
+			// offsets separate words.
+			i++
+			for i < len(stream) && stream[i] != ']' {
+				switch {
+				case stream[i] == '(':
+					var text string
+					text, i = pdfLiteralString(stream, i)
+					operands = append(operands, text)
+				case stream[i] == '<':
+					end := bytes.IndexByte(stream[i:], '>')
+					if end < 0 {
+						return
+					}
+					operands = append(operands, pdfHexString(stream[i+1:i+end]))
+					i += end + 1
+				case stream[i] == '-' || stream[i] == '.' || (stream[i] >= '0' && stream[i] <= '9'):
+					start := i
+					for i < len(stream) && (stream[i] == '-' || stream[i] == '.' || (stream[i] >= '0' && stream[i] <= '9')) {
+						i++
+					}
+					if n, err := strconv.ParseFloat(string(stream[start:i]), 64); err == nil && n < -200 {
+						operands = append(operands, " ")
+					}
+				default:
+					i++
+				}
+			}
+			i++
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			start := i
+			for i < len(stream) && (stream[i] == '-' || stream[i] == '.' || (stream[i] >= '0' && stream[i] <= '9')) {
+				i++
+			}
+			n, _ := strconv.ParseFloat(string(stream[start:i]), 64)
+			numbers = append(numbers, n)
+		case (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '\'' || c == '"' || c == '*':
+			start := i
+			for i < len(stream) && ((stream[i] >= 'A' && stream[i] <= 'Z') || (stream[i] >= 'a' && stream[i] <= 'z') || stream[i] == '\'' || stream[i] == '"' || stream[i] == '*') {
+				i++
+			}
+			switch string(stream[start:i]) {
+			case "Tj", "TJ":
+				out.WriteString(strings.Join(operands, ""))
+			case "'", "\"":
+				newline()
+				out.WriteString(strings.Join(operands, ""))
+			case "T*", "ET":
+				newline()
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					newline()
+				} else if len(numbers) >= 2 && numbers[len(numbers)-2] > 0 {
+					out.WriteByte(' ')
+				}
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		default:
+			i++
+		}
+	}
+}
+
+// pdfLiteralString decodes the parenthesized string starting at stream[i]
+// and returns it with the index just past its closing parenthesis.
+func pdfLiteralString(stream []byte, i int) (string, int) {
+	var b strings.Builder
+	depth := 0
+	for i < len(stream) {
+		c := stream[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				b.WriteByte(c)
+			}
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return b.String(), i + 1
+			}
+			b.WriteByte(c)
+		case c == '\\' && i+1 < len(stream):
+			i++
+			switch e := stream[i]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// Line continuation.
+			default:
+				if e >= '0' && e <= '7' {
+					end := i
+					for end < len(stream) && end < i+3 && stream[end] >= '0' && stream[end] <= '7' {
+						end++
+					}
+					n, _ := strconv.ParseUint(string(stream[i:end]), 8, 8)
+					b.WriteByte(byte(n))
+					i = end - 1
+				} else {
+					b.WriteByte(e)
+				}
+			}
+		default:
+			b.WriteByte(c)
+		}
+		i++
+	}
+	return b.String(), i
+}
+
+// pdfHexString decodes a <...> string. Two-byte strings from CID fonts can't
+// be mapped without the font's CMap, so only printable single bytes are kept.
+func pdfHexString(hex []byte) string {
+	digits := bytes.Map(func(r rune) rune {
+		if strings.ContainsRune("0123456789abcdefABCDEF", r) {
+			return r
+		}
+		return -1
+	}, hex)
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	var b strings.Builder
+	for i := 0; i+1 < len(digits); i += 2 {
+		n, _ := strconv.ParseUint(string(digits[i:i+2]), 16, 8)
+		if n >= 0x20 && n < 0x7f {
+			b.WriteByte(byte(n))
+		}
+	}
+	return b.String()
+}
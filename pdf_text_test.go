@@ -0,0 +1,81 @@
+package aikit
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"testing"
+)
+
+// buildTestPDF assembles a minimal PDF with one page per content stream.
+// Cross-reference offsets are omitted since the extractor doesn't use them.
+func buildTestPDF(streams ...[]byte) []byte {
+	var pdf bytes.Buffer
+	pdf.WriteString("%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	pdf.WriteString("2 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>\nendobj\n")
+	for i, stream := range streams {
+		var compressed bytes.Buffer
+		w := zlib.NewWriter(&compressed)
+		w.Write(stream)
+		w.Close()
+		fmt.Fprintf(&pdf, "%d 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", i+3, compressed.Len())
+		pdf.Write(compressed.Bytes())
+		pdf.WriteString("\nendstream\nendobj\n")
+	}
+	pdf.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return pdf.Bytes()
+}
+
+func TestUnit_PDFText_ExtractsTextOperators(t *testing.T) {
+	page := []byte(`BT /F1 12 Tf 72 712 Td (Quarterly \(Q3\) report) Tj 0 -14 Td [(Rev)-20(enue)-300(grew)] TJ T* (by 12%\041) Tj ET`)
+	pdf := buildTestPDF(page, []byte(`BT 72 700 Td <48656C6C6F> Tj ET`))
+
+	text := extractPDFText(pdf)
+	expected := "Quarterly (Q3) report\nRevenue grew\nby 12%!\nHello"
+	if text != expected {
+		t.Errorf("got %q, want %q", text, expected)
+	}
+}
+
+func TestUnit_PDFText_UncompressedStream(t *testing.T) {
+	pdf := []byte("%PDF-1.4\n4 0 obj\n<< /Length 30 >>\nstream\r\nBT (mainstream text) Tj ET\r\nendstream\nendobj\n")
+	if text := extractPDFText(pdf); text != "mainstream text" {
+		t.Errorf("got %q", text)
+	}
+}
+
+func TestUnit_PDFText_DecompressionLimits(t *testing.T) {
+	// Each stream inflates to twice the per-stream limit.
+	page := append([]byte("BT "), bytes.Repeat([]byte("(ab) Tj "), pdfMaxStreamBytes/4)...)
+	streams := make([][]byte, 12)
+	for i := range streams {
+		streams[i] = page
+	}
+	pdf := buildTestPDF(streams...)
+	if len(pdf) > 1<<20 {
+		t.Fatalf("expected a small PDF, got %d bytes", len(pdf))
+	}
+
+	text := extractPDFText(pdf)
+	if text == "" || len(text) > pdfMaxDecodedBytes/4 {
+		t.Errorf("expected the decoded text to be capped, got %d bytes", len(text))
+	}
+}
+
+func TestUnit_Document_TextFallback(t *testing.T) {
+	thread := NewProviderState()
+	thread.InputDocument(buildTestPDF([]byte(`BT (Hello PDF) Tj ET`)), "application/pdf", "", false)
+	thread.InputDocument([]byte("plain notes"), "text/plain", "notes.txt", false)
+	thread.InputDocument([]byte{0x89, 0x50}, "application/octet-stream", "blob.bin", false)
+
+	expected := []string{
+		"Document: document.pdf\n\nHello PDF",
+		"Document: notes.txt\n\nplain notes",
+		"[Document blob.bin (application/octet-stream) could not be converted to text]",
+	}
+	for i, block := range thread.Blocks {
+		if got := block.Document.textFallback(); got != expected[i] {
+			t.Errorf("block %d: got %q, want %q", i, got, expected[i])
+		}
+	}
+}
@@ -0,0 +1,423 @@
+package aikit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ModelPricing holds the list prices of a model. Token rates are in USD per
+// million tokens, tool rates in USD per call.
+type ModelPricing struct {
+	Input        float64 `json:"input"`
+	Output       float64 `json:"output"`
+	CacheRead    float64 `json:"cache_read,omitempty"`
+	CacheWrite5m float64 `json:"cache_write_5m,omitempty"`
+	CacheWrite1h float64 `json:"cache_write_1h,omitempty"`
+	// Reasoning is the rate of hidden reasoning tokens, which providers
+	// count as output. Zero bills them at the output rate.
+	Reasoning float64 `json:"reasoning,omitempty"`
+	WebSearch float64 `json:"web_search,omitempty"`
+	PageView  float64 `json:"page_view,omitempty"`
+}
+
+// PricingTable maps providers and models to prices. A model matches the
+// entry with the longest prefix of its name, so "claude-sonnet-4-5" also
+// prices "claude-sonnet-4-5-20250929".
+type PricingTable struct {
+	mu     sync.RWMutex
+	prices map[string]map[string]ModelPricing
+}
+
+// NewPricingTable returns an empty table.
+func NewPricingTable() *PricingTable {
+	return &PricingTable{prices: map[string]map[string]ModelPricing{}}
+}
+
+// DefaultPricing holds the built-in list prices and is used by threads
+// without their own Pricing. Entries can be overridden with Set or the
+// Load functions.
+var DefaultPricing = defaultPricingTable()
+
+// Set adds or replaces the price of models starting with model.
+func (t *PricingTable) Set(provider string, model string, pricing ModelPricing) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.prices == nil {
+		t.prices = map[string]map[string]ModelPricing{}
+	}
+	if t.prices[provider] == nil {
+		t.prices[provider] = map[string]ModelPricing{}
+	}
+	t.prices[provider][model] = pricing
+}
+
+// Lookup returns the price of a model. provider is a ProviderConfig name
+// or an adapter name such as "messages.anthropic". Models without an entry
+// for the provider fall back to the other providers' entries, so Azure and other
+// resellers of a model get its list price. Bedrock style ids such as
+// "us.anthropic.claude-sonnet-4-5-20250929-v1:0" are matched without their
+// prefix.
+func (t *PricingTable) Lookup(provider string, model string) (ModelPricing, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if _, name, ok := strings.Cut(provider, "."); ok {
+		provider = name
+	}
+	if pricing, ok := matchPricing(t.prices[provider], model); ok {
+		return pricing, true
+	}
+	providers := make([]string, 0, len(t.prices))
+	for name := range t.prices {
+		providers = append(providers, name)
+	}
+	// Sorted so the fallback doesn't depend on map order.
+	sort.Strings(providers)
+	names := []string{model}
+	if i := strings.LastIndex(model, "."); i >= 0 {
+		names = append(names, model[i+1:])
+	}
+	for _, name := range names {
+		for _, p := range providers {
+			if pricing, ok := matchPricing(t.prices[p], name); ok {
+				return pricing, true
+			}
+		}
+	}
+	return ModelPricing{}, false
+}
+
+func matchPricing(models map[string]ModelPricing, model string) (ModelPricing, bool) {
+	var found ModelPricing
+	longest := -1
+	for prefix, pricing := range models {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			found, longest = pricing, len(prefix)
+		}
+	}
+	return found, longest >= 0
+}
+
+// LoadJSON adds the prices in r, overriding existing entries. The document
+// maps providers to models to prices:
+//
+//	{"anthropic": {"claude-sonnet-4-5": {"input": 3, "output": 15}}}
+func (t *PricingTable) LoadJSON(r io.Reader) error {
+	var prices map[string]map[string]ModelPricing
+	if err := json.NewDecoder(r).Decode(&prices); err != nil {
+		return fmt.Errorf("pricing: %w", err)
+	}
+	for provider, models := range prices {
+		for model, pricing := range models {
+			t.Set(provider, model, pricing)
+		}
+	}
+	return nil
+}
+
+// LoadYAML adds prices from the YAML form of the LoadJSON document. Only
+// nested mappings of scalars are supported, which is all a pricing file
+// needs:
+//
+//	anthropic:
+//	  claude-sonnet-4-5:
+//	    input: 3
+//	    output: 15
+func (t *PricingTable) LoadYAML(r io.Reader) error {
+	doc, err := parseYAMLMapping(r)
+	if err != nil {
+		return fmt.Errorf("pricing: %w", err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("pricing: %w", err)
+	}
+	return t.LoadJSON(strings.NewReader(string(data)))
+}
+
+// LoadFile adds the prices in a .json, .yaml or .yml file.
+func (t *PricingTable) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return t.LoadYAML(f)
+	default:
+		return t.LoadJSON(f)
+	}
+}
+
+// parseYAMLMapping reads indentation-nested "key: value" lines into maps.
+// Numbers become float64, everything else a string.
+func parseYAMLMapping(r io.Reader) (map[string]any, error) {
+	type level struct {
+		indent int
+		values map[string]any
+	}
+	root := map[string]any{}
+	stack := []level{{indent: -1, values: root}}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := stripYAMLComment(scanner.Text())
+		if trimmed := strings.TrimSpace(text); trimmed == "" || strings.HasPrefix(trimmed, "#") || trimmed == "---" {
+			continue
+		}
+		if strings.Contains(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", line)
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		key, value, ok := cutYAMLKey(strings.TrimSpace(text))
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", line)
+		}
+
+		for len(stack) > 1 && indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].values
+		if value == "" {
+			child := map[string]any{}
+			parent[key] = child
+			stack = append(stack, level{indent: indent, values: child})
+			continue
+		}
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			parent[key] = n
+		} else {
+			parent[key] = unquoteYAML(value)
+		}
+	}
+	return root, scanner.Err()
+}
+
+// stripYAMLComment removes a "#" comment that starts the line or follows a
+// space, outside quotes.
+func stripYAMLComment(text string) string {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || text[i-1] == ' '):
+			quote = c
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// cutYAMLKey splits "key: value" at the colon that ends the key. Model ids
+// contain colons (llama3:8b, ...-v2:0), so a plain key ends at the first
+// colon followed by a space or the end of the line, and a quoted key at its
+// closing quote.
+func cutYAMLKey(text string) (key string, value string, ok bool) {
+	if text != "" && (text[0] == '"' || text[0] == '\'') {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, rest := text[1:end+1], strings.TrimLeft(text[end+2:], " ")
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), true
+		}
+	}
+	return "", "", false
+}
+
+func unquoteYAML(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// CostBreakdown itemizes the cost of some usage in USD.
+type CostBreakdown struct {
+	Usage      ThreadUsage
+	Input      float64
+	Output     float64
+	CacheRead  float64
+	CacheWrite float64
+	Reasoning  float64
+	WebSearch  float64
+	PageView   float64
+	Total      float64
+	// Unpriced lists the "provider/model" pairs without a price, whose
+	// usage is in Usage but not in the amounts.
+	Unpriced []string `json:",omitempty"`
+}
+
+// cost prices usage at the given rates.
+func (p ModelPricing) cost(usage ThreadUsage) CostBreakdown {
+	const perToken = 1.0 / 1_000_000
+	reasoningRate := p.Reasoning
+	if reasoningRate == 0 {
+		reasoningRate = p.Output
+	}
+	cacheWrite1h := min(usage.CacheWrite1hTokens, usage.CacheWriteTokens)
+	c := CostBreakdown{
+		Usage:     usage,
+		Input:     float64(usage.InputTokens) * p.Input * perToken,
+		Output:    float64(usage.OutputTokens-usage.ReasoningTokens) * p.Output * perToken,
+		CacheRead: float64(usage.CacheReadTokens) * p.CacheRead * perToken,
+		CacheWrite: float64(usage.CacheWriteTokens-cacheWrite1h)*p.CacheWrite5m*perToken +
+			float64(cacheWrite1h)*p.CacheWrite1h*perToken,
+		Reasoning: float64(usage.ReasoningTokens) * reasoningRate * perToken,
+		WebSearch: float64(usage.WebSearches) * p.WebSearch,
+		PageView:  float64(usage.PageViews) * p.PageView,
+	}
+	c.Total = c.Input + c.Output + c.CacheRead + c.CacheWrite + c.Reasoning + c.WebSearch + c.PageView
+	return c
+}
+
+// plus returns the sum of two breakdowns.
+func (c CostBreakdown) plus(other CostBreakdown) CostBreakdown {
+	sum := CostBreakdown{
+		Usage:      c.Usage.plus(other.Usage),
+		Input:      c.Input + other.Input,
+		Output:     c.Output + other.Output,
+		CacheRead:  c.CacheRead + other.CacheRead,
+		CacheWrite: c.CacheWrite + other.CacheWrite,
+		Reasoning:  c.Reasoning + other.Reasoning,
+		WebSearch:  c.WebSearch + other.WebSearch,
+		PageView:   c.PageView + other.PageView,
+		Total:      c.Total + other.Total,
+	}
+	sum.Unpriced = append(append([]string{}, c.Unpriced...), other.Unpriced...)
+	slices.Sort(sum.Unpriced)
+	sum.Unpriced = slices.Compact(sum.Unpriced)
+	if len(sum.Unpriced) == 0 {
+		sum.Unpriced = nil
+	}
+	return sum
+}
+
+// priceUsage prices usage of one model, noting it as unpriced when the
+// table has no entry.
+func priceUsage(table *PricingTable, provider string, model string, usage ThreadUsage) CostBreakdown {
+	pricing, ok := table.Lookup(provider, model)
+	if !ok {
+		return CostBreakdown{Usage: usage, Unpriced: []string{provider + "/" + model}}
+	}
+	return pricing.cost(usage)
+}
+
+// Cost prices the thread's usage with its Pricing table, or DefaultPricing.
+// Each turn is priced at the model it used.
+func (s *Thread) Cost() CostBreakdown {
+	total := CostBreakdown{}
+	for _, cost := range s.costsByModel() {
+		total = total.plus(cost)
+	}
+	return total
+}
+
+// costsByModel prices the thread's usage per "provider/model". Usage not
+// recorded in Turns is priced at the thread's current model.
+func (s *Thread) costsByModel() map[string]CostBreakdown {
+	table := s.Pricing
+	if table == nil {
+		table = DefaultPricing
+	}
+	costs := map[string]CostBreakdown{}
+	add := func(provider, model string, usage ThreadUsage) {
+		if usage == (ThreadUsage{}) {
+			return
+		}
+		key := provider + "/" + model
+		costs[key] = costs[key].plus(priceUsage(table, provider, model, usage))
+	}
+	for _, turn := range s.Turns {
+		add(turn.Provider, turn.Model, turn.ThreadUsage)
+	}
+	add(s.CurrentProvider, s.Model, s.Result.since(totalUsage(s.Turns)))
+	return costs
+}
+
+// CostReport adds up the costs of many threads, e.g. for billing.
+type CostReport struct {
+	Total   CostBreakdown
+	Threads int
+	// ByModel holds the cost of each "provider/model".
+	ByModel map[string]CostBreakdown
+}
+
+// Add prices a thread and adds it to the report.
+func (r *CostReport) Add(thread *Thread) {
+	if r.ByModel == nil {
+		r.ByModel = map[string]CostBreakdown{}
+	}
+	for key, cost := range thread.costsByModel() {
+		r.ByModel[key] = r.ByModel[key].plus(cost)
+		r.Total = r.Total.plus(cost)
+	}
+	r.Threads++
+}
+
+func defaultPricingTable() *PricingTable {
+	t := NewPricingTable()
+	anthropic := func(input, output float64) ModelPricing {
+		return ModelPricing{
+			Input:        input,
+			Output:       output,
+			CacheRead:    input * 0.1,
+			CacheWrite5m: input * 1.25,
+			CacheWrite1h: input * 2,
+			WebSearch:    0.01,
+		}
+	}
+	t.Set("anthropic", "claude-opus-4-5", anthropic(5, 25))
+	t.Set("anthropic", "claude-opus-4-1", anthropic(15, 75))
+	t.Set("anthropic", "claude-opus-4", anthropic(15, 75))
+	t.Set("anthropic", "claude-sonnet-4-5", anthropic(3, 15))
+	t.Set("anthropic", "claude-sonnet-4", anthropic(3, 15))
+	t.Set("anthropic", "claude-3-7-sonnet", anthropic(3, 15))
+	t.Set("anthropic", "claude-haiku-4-5", anthropic(1, 5))
+	t.Set("anthropic", "claude-3-5-haiku", anthropic(0.8, 4))
+
+	openai := func(input, cached, output float64) ModelPricing {
+		return ModelPricing{Input: input, Output: output, CacheRead: cached, WebSearch: 0.01}
+	}
+	t.Set("openai", "gpt-5", openai(1.25, 0.125, 10))
+	t.Set("openai", "gpt-5-mini", openai(0.25, 0.025, 2))
+	t.Set("openai", "gpt-5-nano", openai(0.05, 0.005, 0.4))
+	t.Set("openai", "gpt-4.1", openai(2, 0.5, 8))
+	t.Set("openai", "gpt-4.1-mini", openai(0.4, 0.1, 1.6))
+	t.Set("openai", "gpt-4.1-nano", openai(0.1, 0.025, 0.4))
+	t.Set("openai", "gpt-4o", openai(2.5, 1.25, 10))
+	t.Set("openai", "gpt-4o-mini", openai(0.15, 0.075, 0.6))
+	t.Set("openai", "o3", openai(2, 0.5, 8))
+	t.Set("openai", "o3-mini", openai(1.1, 0.55, 4.4))
+	t.Set("openai", "o4-mini", openai(1.1, 0.275, 4.4))
+
+	google := func(input, cached, output float64) ModelPricing {
+		return ModelPricing{Input: input, Output: output, CacheRead: cached, WebSearch: 0.035}
+	}
+	t.Set("google", "gemini-2.5-pro", google(1.25, 0.31, 10))
+	t.Set("google", "gemini-2.5-flash", google(0.3, 0.075, 2.5))
+	t.Set("google", "gemini-2.5-flash-lite", google(0.1, 0.025, 0.4))
+	t.Set("google", "gemini-2.0-flash", google(0.1, 0.025, 0.4))
+	return t
+}
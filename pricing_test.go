@@ -0,0 +1,145 @@
+package aikit
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func closeTo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestUnit_Pricing_LookupAndOverrides(t *testing.T) {
+	table := NewPricingTable()
+	table.Set("anthropic", "claude-sonnet-4-5", ModelPricing{Input: 3, Output: 15})
+	table.Set("anthropic", "claude-sonnet-4-5-fast", ModelPricing{Input: 6, Output: 30})
+
+	if p, ok := table.Lookup("anthropic", "claude-sonnet-4-5-20250929"); !ok || p.Input != 3 {
+		t.Errorf("expected the dated model to match its prefix, got %+v", p)
+	}
+	if p, _ := table.Lookup("anthropic", "claude-sonnet-4-5-fast-1"); p.Input != 6 {
+		t.Errorf("expected the longest prefix to win, got %+v", p)
+	}
+	if p, ok := table.Lookup("messages.anthropic", "claude-sonnet-4-5"); !ok || p.Input != 3 {
+		t.Errorf("expected adapter names to match their provider, got %+v", p)
+	}
+	if p, ok := table.Lookup("bedrock", "us.anthropic.claude-sonnet-4-5-20250929-v1:0"); !ok || p.Input != 3 {
+		t.Errorf("expected Bedrock ids to fall back to the Anthropic price, got %+v", p)
+	}
+	if _, ok := table.Lookup("openai", "gpt-unknown"); ok {
+		t.Errorf("expected no price for an unknown model")
+	}
+
+	yaml := `# overrides
+anthropic:
+  claude-sonnet-4-5:
+    input: 2.5
+    output: 12   # discounted
+    cache_write_1h: 5
+google:
+  "gemini-2.5-flash":
+    input: 0.3
+    output: 2.5
+bedrock:
+  "us.anthropic.claude-3-5-sonnet-20241022-v2:0": # cross-region
+    input: 3.3
+    output: 16.5
+ollama:
+  llama3:8b:
+    input: 0.1
+    output: 0.2
+  "tuned #2":
+    input: 0.4
+    output: 0.8
+`
+	if err := table.LoadYAML(strings.NewReader(yaml)); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := table.Lookup("anthropic", "claude-sonnet-4-5"); p.Input != 2.5 || p.Output != 12 || p.CacheWrite1h != 5 {
+		t.Errorf("unexpected YAML override %+v", p)
+	}
+	if p, ok := table.Lookup("google", "gemini-2.5-flash"); !ok || p.Output != 2.5 {
+		t.Errorf("unexpected YAML entry %+v", p)
+	}
+	if p, ok := table.Lookup("bedrock", "us.anthropic.claude-3-5-sonnet-20241022-v2:0"); !ok || p.Input != 3.3 || p.Output != 16.5 {
+		t.Errorf("unexpected YAML entry for a Bedrock id %+v", p)
+	}
+	if p, ok := table.Lookup("ollama", "llama3:8b"); !ok || p.Output != 0.2 {
+		t.Errorf("unexpected YAML entry for an Ollama tag %+v", p)
+	}
+	if p, ok := table.Lookup("ollama", "tuned #2"); !ok || p.Output != 0.8 {
+		t.Errorf("expected a quoted # to be kept %+v", p)
+	}
+
+	path := filepath.Join(t.TempDir(), "prices.json")
+	os.WriteFile(path, []byte(`{"openai": {"gpt-test": {"input": 1, "output": 2, "reasoning": 4}}}`), 0o644)
+	if err := table.LoadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := table.Lookup("openai", "gpt-test-mini"); p.Reasoning != 4 {
+		t.Errorf("unexpected JSON entry %+v", p)
+	}
+	if err := table.LoadYAML(strings.NewReader("anthropic\n")); err == nil {
+		t.Errorf("expected an error for malformed YAML")
+	}
+}
+
+func TestUnit_Pricing_ThreadCost(t *testing.T) {
+	table := NewPricingTable()
+	table.Set("anthropic", "claude-test", ModelPricing{
+		Input: 3, Output: 15, CacheRead: 0.3, CacheWrite5m: 3.75, CacheWrite1h: 6, Reasoning: 20, WebSearch: 0.01,
+	})
+	thread := &Thread{Model: "claude-test", CurrentProvider: "anthropic", Pricing: table}
+	thread.Result = ThreadUsage{
+		InputTokens:        1_000_000,
+		OutputTokens:       200_000,
+		ReasoningTokens:    100_000,
+		CacheReadTokens:    1_000_000,
+		CacheWriteTokens:   300_000,
+		CacheWrite1hTokens: 100_000,
+		WebSearches:        3,
+	}
+	cost := thread.Cost()
+	want := CostBreakdown{Input: 3, Output: 1.5, Reasoning: 2, CacheRead: 0.3, CacheWrite: 0.75 + 0.6, WebSearch: 0.03}
+	if !closeTo(cost.Input, want.Input) || !closeTo(cost.Output, want.Output) || !closeTo(cost.Reasoning, want.Reasoning) ||
+		!closeTo(cost.CacheRead, want.CacheRead) || !closeTo(cost.CacheWrite, want.CacheWrite) || !closeTo(cost.WebSearch, want.WebSearch) {
+		t.Errorf("unexpected breakdown %+v", cost)
+	}
+	if !closeTo(cost.Total, 3+1.5+2+0.3+1.35+0.03) {
+		t.Errorf("unexpected total %f", cost.Total)
+	}
+
+	other := &Thread{Model: "mystery", CurrentProvider: "groq", Pricing: table, Result: ThreadUsage{InputTokens: 10}}
+	report := &CostReport{}
+	report.Add(thread)
+	report.Add(thread)
+	report.Add(other)
+	if report.Threads != 3 || !closeTo(report.Total.Total, 2*cost.Total) || report.Total.Usage.InputTokens != 2_000_010 {
+		t.Errorf("unexpected report total %+v", report.Total)
+	}
+	if len(report.Total.Unpriced) != 1 || report.Total.Unpriced[0] != "groq/mystery" {
+		t.Errorf("expected the unpriced model to be listed, got %v", report.Total.Unpriced)
+	}
+	if by := report.ByModel["anthropic/claude-test"]; !closeTo(by.Total, 2*cost.Total) {
+		t.Errorf("unexpected per-model cost %+v", by)
+	}
+
+	// Turns are priced at their own model.
+	mixed := &Thread{Model: "claude-test", CurrentProvider: "anthropic", Pricing: table}
+	mixed.Turns = []TurnUsage{
+		{ThreadUsage: ThreadUsage{InputTokens: 1_000_000}, Model: "claude-test", Provider: "anthropic"},
+		{ThreadUsage: ThreadUsage{InputTokens: 1_000_000}, Model: "cheap", Provider: "anthropic"},
+	}
+	mixed.Result = totalUsage(mixed.Turns)
+	table.Set("anthropic", "cheap", ModelPricing{Input: 1})
+	if cost := mixed.Cost(); !closeTo(cost.Total, 4) || len(cost.Unpriced) != 0 {
+		t.Errorf("expected per-turn pricing, got %+v", cost)
+	}
+
+	if p, ok := DefaultPricing.Lookup("anthropic", "claude-sonnet-4-5-20250929"); !ok || p.CacheWrite1h != 6 {
+		t.Errorf("unexpected built-in price %+v", p)
+	}
+}
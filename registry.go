@@ -1,5 +1,7 @@
 package aikit
 
+import "fmt"
+
 func GoogleProvider(key string) ProviderConfig {
 	return ProviderConfig{
 		Name:                "google",
@@ -11,22 +13,35 @@ func GoogleProvider(key string) ProviderConfig {
 
 func OpenAIProvider(key string) ProviderConfig {
 	return ProviderConfig{
-		Name:                "openai",
-		BaseURL:             "https://api.openai.com",
-		APIKey:              key,
-		WebSearchToolName:   "web_search",
-		MakeSessionFunction: CreateResponsesSession,
+		Name:                  "openai",
+		BaseURL:               "https://api.openai.com",
+		APIKey:                key,
+		WebSearchToolName:     "web_search",
+		CodeExecutionToolName: "code_interpreter",
+		MakeSessionFunction:   CreateResponsesSession,
 	}
 }
 
 func OpenAIVerifiedProvider(key string) ProviderConfig {
 	return ProviderConfig{
-		Name:                 "openai",
-		BaseURL:              "https://api.openai.com",
-		APIKey:               key,
-		WebSearchToolName:    "web_search",
-		UseThinkingSummaries: true,
-		MakeSessionFunction:  CreateResponsesSession,
+		Name:                  "openai",
+		BaseURL:               "https://api.openai.com",
+		APIKey:                key,
+		WebSearchToolName:     "web_search",
+		CodeExecutionToolName: "code_interpreter",
+		UseThinkingSummaries:  true,
+		MakeSessionFunction:   CreateResponsesSession,
+	}
+}
+
+// OpenAICompletionsProvider uses OpenAI's Chat Completions API, which is
+// where the audio models (gpt-4o-audio-preview) are served.
+func OpenAICompletionsProvider(key string) ProviderConfig {
+	return ProviderConfig{
+		Name:                "openai",
+		BaseURL:             "https://api.openai.com",
+		APIKey:              key,
+		MakeSessionFunction: CreateCompletionsSession,
 	}
 }
 
@@ -48,11 +63,12 @@ func GroqProvider(key string) ProviderConfig {
 }
 func AnthropicProvider(key string) ProviderConfig {
 	return ProviderConfig{
-		Name:              "anthropic",
-		BaseURL:           "https://api.anthropic.com",
-		APIKey:            key,
-		WebSearchToolName: "web_search_20250305",
-		WebFetchToolName:  "web_fetch_20250910",
+		Name:                  "anthropic",
+		BaseURL:               "https://api.anthropic.com",
+		APIKey:                key,
+		WebSearchToolName:     "web_search_20250305",
+		WebFetchToolName:      "web_fetch_20250910",
+		CodeExecutionToolName: "code_execution_20250825",
 		BetaFeatures: []string{
 			"interleaved-thinking-2025-05-14",
 		},
@@ -68,3 +84,60 @@ func XAIProvider(key string) ProviderConfig {
 		MakeSessionFunction: CreateCompletionsSession,
 	}
 }
+
+// BedrockProvider targets Anthropic models on AWS Bedrock. A nil credentials
+// value signs requests with the AWS environment variables, and an empty
+// region falls back to AWS_REGION.
+func BedrockProvider(region string, credentials *AWSCredentials) ProviderConfig {
+	if region == "" {
+		region = AWSRegionFromEnv()
+	}
+	return ProviderConfig{
+		Name:                "bedrock",
+		BaseURL:             fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", region),
+		Region:              region,
+		Credentials:         credentials,
+		MaxTokens:           64_000,
+		MakeSessionFunction: CreateBedrockSession,
+	}
+}
+
+// AzureOpenAIProvider targets Chat Completions on an Azure OpenAI resource
+// (https://{resource}.openai.azure.com). Map model names to deployment names
+// with Deployments; set AuthHeader to "" to send an Entra ID bearer token as
+// the key instead of an api-key.
+func AzureOpenAIProvider(resource string, key string) ProviderConfig {
+	return ProviderConfig{
+		Name:                "azure",
+		BaseURL:             fmt.Sprintf("https://%s.openai.azure.com", resource),
+		APIKey:              key,
+		AuthHeader:          "api-key",
+		APIVersion:          "2024-10-21",
+		MakeSessionFunction: CreateAzureCompletionsSession,
+	}
+}
+
+// AzureOpenAIResponsesProvider targets the Responses API on an Azure OpenAI
+// resource through the v1 endpoint.
+func AzureOpenAIResponsesProvider(resource string, key string) ProviderConfig {
+	return ProviderConfig{
+		Name:                "azure",
+		BaseURL:             fmt.Sprintf("https://%s.openai.azure.com", resource),
+		APIKey:              key,
+		AuthHeader:          "api-key",
+		MakeSessionFunction: CreateAzureResponsesSession,
+	}
+}
+
+// OllamaProvider targets a native Ollama server. An empty baseURL uses the
+// default local address.
+func OllamaProvider(baseURL string) ProviderConfig {
+	if baseURL == "" {
+		baseURL = "http://localhost:11434"
+	}
+	return ProviderConfig{
+		Name:                "ollama",
+		BaseURL:             baseURL,
+		MakeSessionFunction: CreateOllamaSession,
+	}
+}
@@ -0,0 +1,229 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jacksonzamorano/aikit"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiDim     = "\x1b[2m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiYellow  = "\x1b[33m"
+	ansiBlue    = "\x1b[34m"
+	ansiMagenta = "\x1b[35m"
+	ansiCyan    = "\x1b[36m"
+)
+
+// ANSI renders a transcript for a terminal. Headings, thinking and tool
+// panes are colored, and images and audio are described rather than shown.
+// Escape sequences and control characters in the transcript are removed, so
+// model and tool output can't restyle the terminal. Terminals can't collapse
+// text, so set RedactThinking to leave long reasoning out.
+type ANSI struct {
+	Options
+	// NoColor writes the same layout without escape codes, for logs.
+	NoColor bool
+}
+
+// color wraps text in an escape code unless NoColor is set.
+func (r ANSI) color(code string, text string) string {
+	if r.NoColor || text == "" {
+		return text
+	}
+	return code + text + ansiReset
+}
+
+// pane indents text under a colored bar.
+func (r ANSI) pane(code string, text string) string {
+	bar := r.color(code, "│") + " "
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	return bar + strings.Join(lines, "\n"+bar) + "\n"
+}
+
+func (r ANSI) Render(w io.Writer, blocks []*aikit.ThreadBlock) error {
+	var sb strings.Builder
+	notes := &footnotes{}
+	for i, m := range messages(blocks, r.Options) {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		code := ansiGreen
+		switch m.Role {
+		case roleUser:
+			code = ansiBlue
+		case roleSystem:
+			code = ansiYellow
+		}
+		sb.WriteString(r.color(ansiBold+code, terminalText(m.title())))
+		if at := timestamp(m.At); at != "" && r.Timestamps {
+			sb.WriteString(" " + r.color(ansiDim, at))
+		}
+		sb.WriteString("\n")
+		for _, b := range m.Blocks {
+			r.block(&sb, b, notes)
+		}
+	}
+	if len(notes.notes) > 0 {
+		sb.WriteString("\n")
+		for i, c := range notes.notes {
+			source := terminalText(citationTitle(c))
+			if c.URL != "" && c.URL != citationTitle(c) {
+				source += " " + r.color(ansiDim, terminalText(c.URL))
+			}
+			fmt.Fprintf(&sb, "%s %s\n", r.color(ansiCyan, fmt.Sprintf("[%d]", i+1)), source)
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (r ANSI) block(sb *strings.Builder, b *aikit.ThreadBlock, notes *footnotes) {
+	t := terminalText
+	switch b.Type {
+	case aikit.InferenceBlockSystem, aikit.InferenceBlockInput:
+		sb.WriteString(t(b.Text) + "\n")
+	case aikit.InferenceBlockText:
+		sb.WriteString(notes.annotate(b.Text, b.Citations, t, func(n int) string {
+			return r.color(ansiCyan, fmt.Sprintf("[%d]", n))
+		}) + "\n")
+	case aikit.InferenceBlockThinking, aikit.InferenceBlockEncryptedThinking:
+		sb.WriteString(r.color(ansiDim, "Thinking") + "\n")
+		sb.WriteString(r.pane(ansiDim, r.color(ansiDim, t(r.thinking(b)))))
+	case aikit.InferenceBlockInputImage, aikit.InferenceBlockOutputImage:
+		if b.Image == nil {
+			sb.WriteString(r.color(ansiMagenta, "[image]") + "\n")
+			break
+		}
+		fmt.Fprintf(sb, "%s\n", r.color(ansiMagenta, fmt.Sprintf("[image: %s, ~%d bytes]", t(b.Image.MediaType), approxBytes(b.Image.Base64))))
+	case aikit.InferenceBlockInputDocument:
+		if b.Document == nil {
+			sb.WriteString(r.color(ansiMagenta, "[document]") + "\n")
+			break
+		}
+		fmt.Fprintf(sb, "%s\n", r.color(ansiMagenta, fmt.Sprintf("[document: %s, %s, ~%d bytes]", t(b.Document.Filename()), t(b.Document.MediaType), approxBytes(b.Document.Base64))))
+	case aikit.InferenceBlockInputAudio, aikit.InferenceBlockAudio:
+		sb.WriteString(r.color(ansiMagenta, t(audioDescription(b))) + "\n")
+	case aikit.InferenceBlockToolCall:
+		if b.ToolCall == nil {
+			break
+		}
+		sb.WriteString(r.color(ansiYellow, "→ "+t(b.ToolCall.Name)) + "\n")
+		if b.ToolCall.Arguments != "" {
+			sb.WriteString(r.pane(ansiYellow, t(b.ToolCall.Arguments)))
+		}
+		if output, ok := r.toolOutput(b); ok {
+			sb.WriteString(r.color(ansiYellow, "← result") + "\n")
+			sb.WriteString(r.pane(ansiYellow, t(output)))
+		} else {
+			sb.WriteString(r.color(ansiDim, "← no result") + "\n")
+		}
+	case aikit.InferenceBlockWebSearch:
+		if b.WebSearch == nil {
+			break
+		}
+		sb.WriteString(r.color(ansiCyan, fmt.Sprintf("Web search %q", t(b.WebSearch.Query))) + "\n")
+		if b.WebSearch.ErrorCode != "" {
+			sb.WriteString(r.color(ansiRed, "  failed: "+t(b.WebSearch.ErrorCode)) + "\n")
+		}
+		for _, result := range b.WebSearch.Results {
+			title := result.Title
+			if title == "" {
+				title = result.URL
+			}
+			fmt.Fprintf(sb, "  • %s %s\n", t(title), r.color(ansiDim, t(result.URL)))
+		}
+	case aikit.InferenceBlockViewWebpage:
+		line := "Viewed " + t(b.Text)
+		if b.WebFetch != nil && b.WebFetch.Title() != "" {
+			line += " (" + t(b.WebFetch.Title()) + ")"
+		}
+		sb.WriteString(r.color(ansiCyan, line) + "\n")
+		if b.WebFetch != nil && b.WebFetch.ErrorCode != "" {
+			sb.WriteString(r.color(ansiRed, "  failed: "+t(b.WebFetch.ErrorCode)) + "\n")
+		}
+	case aikit.InferenceBlockServerTool:
+		tool := b.ServerTool
+		if tool == nil {
+			break
+		}
+		sb.WriteString(r.color(ansiYellow, "→ "+t(tool.Name)) + "\n")
+		if tool.Input != "" {
+			sb.WriteString(r.pane(ansiYellow, t(tool.Input)))
+		}
+		if output := r.serverToolOutput(tool); output != "" {
+			sb.WriteString(r.color(ansiYellow, "← result") + "\n")
+			sb.WriteString(r.pane(ansiYellow, t(output)))
+		}
+		if tool.ErrorCode != "" {
+			sb.WriteString(r.color(ansiRed, "  failed: "+t(tool.ErrorCode)) + "\n")
+		}
+		for _, file := range tool.Files {
+			fmt.Fprintf(sb, "  file: %s\n", t(serverFileName(file)))
+		}
+	}
+}
+
+// terminalText removes escape sequences and control characters other than
+// newlines and tabs, so text can't move the cursor, change colors or retitle
+// the terminal. Invalid UTF-8 becomes U+FFFD.
+func terminalText(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		c, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case c == 0x1b:
+			size = escapeLen(s[i:])
+		case c == '\n' || c == '\t':
+			sb.WriteRune(c)
+		case c < 0x20 || (c >= 0x7f && c <= 0x9f):
+			// C0 and C1 controls, including a lone CR that would let text
+			// overwrite its own line.
+		default:
+			sb.WriteRune(c)
+		}
+		i += size
+	}
+	return sb.String()
+}
+
+// escapeLen returns the length of the escape sequence at the start of s,
+// which begins with ESC.
+func escapeLen(s string) int {
+	if len(s) < 2 {
+		return 1
+	}
+	switch s[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte.
+		i := 2
+		for i < len(s) && s[i] >= 0x20 && s[i] <= 0x3f {
+			i++
+		}
+		if i < len(s) && s[i] >= 0x40 && s[i] <= 0x7e {
+			i++
+		}
+		return i
+	case ']', 'P', 'X', '^', '_':
+		// OSC and other strings, ended by BEL or ESC \.
+		for i := 2; i < len(s); i++ {
+			switch {
+			case s[i] == 0x07:
+				return i + 1
+			case s[i] == 0x1b && i+1 < len(s) && s[i+1] == '\\':
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	if s[1] < 0x80 {
+		return 2
+	}
+	return 1
+}
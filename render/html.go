@@ -0,0 +1,189 @@
+package render
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/jacksonzamorano/aikit"
+)
+
+// HTML renders a transcript as a self-contained HTML page: styles are
+// inline and images, audio and documents are embedded as data URLs, so the
+// file can be attached to a ticket as is. Thinking and tool calls are
+// collapsible <details> panes.
+type HTML struct {
+	Options
+	// Title is the page title. Empty uses "Transcript".
+	Title string
+}
+
+const htmlStyle = `body{font-family:system-ui,sans-serif;max-width:48rem;margin:2rem auto;padding:0 1rem;color:#1f2328;line-height:1.5}
+.message{border:1px solid #d0d7de;border-radius:8px;padding:.75rem 1rem;margin:1rem 0}
+.message.user{background:#f6f8fa}.message.system{background:#fff8c5}
+.message h2{font-size:.9rem;margin:0 0 .5rem;color:#57606a}.message h2 time{font-weight:normal;margin-left:.5rem}
+.text{white-space:pre-wrap}
+details{border-left:3px solid #d0d7de;padding-left:.75rem;margin:.5rem 0}summary{cursor:pointer;color:#57606a}
+pre{background:#f6f8fa;padding:.5rem;border-radius:6px;overflow-x:auto;white-space:pre-wrap}
+.tool .label{font-weight:600;margin-top:.5rem}.error{color:#cf222e}.muted{color:#57606a;font-style:italic}
+img{max-width:100%;border-radius:6px}.footnotes{font-size:.9rem;border-top:1px solid #d0d7de;margin-top:2rem}
+`
+
+func (r HTML) Render(w io.Writer, blocks []*aikit.ThreadBlock) error {
+	title := r.Title
+	if title == "" {
+		title = "Transcript"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", html.EscapeString(title), htmlStyle)
+	notes := &footnotes{}
+	for _, m := range messages(blocks, r.Options) {
+		fmt.Fprintf(&sb, "<section class=\"message %s\">\n<h2>%s", m.Role, html.EscapeString(m.title()))
+		if at := timestamp(m.At); at != "" && r.Timestamps {
+			fmt.Fprintf(&sb, "<time datetime=\"%s\">%s</time>", m.At.UTC().Format("2006-01-02T15:04:05Z"), at)
+		}
+		sb.WriteString("</h2>\n")
+		for _, b := range m.Blocks {
+			r.block(&sb, b, notes)
+		}
+		sb.WriteString("</section>\n")
+	}
+	if len(notes.notes) > 0 {
+		sb.WriteString("<ol class=\"footnotes\">\n")
+		for i, c := range notes.notes {
+			fmt.Fprintf(&sb, "<li id=\"fn-%d\">%s</li>\n", i+1, htmlSource(c))
+		}
+		sb.WriteString("</ol>\n")
+	}
+	sb.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (r HTML) block(sb *strings.Builder, b *aikit.ThreadBlock, notes *footnotes) {
+	esc := html.EscapeString
+	switch b.Type {
+	case aikit.InferenceBlockSystem, aikit.InferenceBlockInput:
+		fmt.Fprintf(sb, "<div class=\"text\">%s</div>\n", esc(b.Text))
+	case aikit.InferenceBlockText:
+		text := notes.annotate(b.Text, b.Citations, esc, func(n int) string {
+			return fmt.Sprintf("<sup><a href=\"#fn-%d\">[%d]</a></sup>", n, n)
+		})
+		fmt.Fprintf(sb, "<div class=\"text\">%s</div>\n", text)
+	case aikit.InferenceBlockThinking, aikit.InferenceBlockEncryptedThinking:
+		fmt.Fprintf(sb, "<details class=\"thinking\">\n<summary>Thinking</summary>\n<div class=\"text\">%s</div>\n</details>\n", esc(r.thinking(b)))
+	case aikit.InferenceBlockInputImage, aikit.InferenceBlockOutputImage:
+		switch {
+		case b.Image == nil:
+			sb.WriteString("<p class=\"muted\">[image]</p>\n")
+		case r.OmitImages:
+			fmt.Fprintf(sb, "<p class=\"muted\">[image: %s, ~%d bytes]</p>\n", esc(b.Image.MediaType), approxBytes(b.Image.Base64))
+		default:
+			alt := "Image"
+			if b.Type == aikit.InferenceBlockOutputImage {
+				alt = "Generated image"
+				if b.Image.RevisedPrompt != "" {
+					alt = b.Image.RevisedPrompt
+				}
+			}
+			fmt.Fprintf(sb, "<img src=\"%s\" alt=\"%s\">\n", esc(b.Image.GetDataURL()), esc(alt))
+		}
+	case aikit.InferenceBlockInputDocument:
+		if b.Document == nil {
+			sb.WriteString("<p class=\"muted\">[document]</p>\n")
+			break
+		}
+		name := b.Document.Filename()
+		fmt.Fprintf(sb, "<p>Document: <a download=\"%s\" href=\"%s\">%s</a> <span class=\"muted\">%s, ~%d bytes</span></p>\n",
+			esc(name), esc(b.Document.GetDataURL()), esc(name), esc(b.Document.MediaType), approxBytes(b.Document.Base64))
+	case aikit.InferenceBlockInputAudio, aikit.InferenceBlockAudio:
+		// Browsers can't play raw PCM, so it is described instead.
+		if b.Audio != nil && b.Audio.Base64 != "" && !b.Audio.IsPCM() {
+			fmt.Fprintf(sb, "<audio controls src=\"data:%s;base64,%s\"></audio>\n", esc(b.Audio.MediaType), esc(b.Audio.Base64))
+		}
+		fmt.Fprintf(sb, "<p class=\"muted\">%s</p>\n", esc(audioDescription(b)))
+	case aikit.InferenceBlockToolCall:
+		if b.ToolCall == nil {
+			break
+		}
+		fmt.Fprintf(sb, "<details class=\"tool\" open>\n<summary>Tool call <code>%s</code></summary>\n<div class=\"label\">Arguments</div>\n<pre>%s</pre>\n", esc(b.ToolCall.Name), esc(b.ToolCall.Arguments))
+		if output, ok := r.toolOutput(b); ok {
+			fmt.Fprintf(sb, "<div class=\"label\">Result</div>\n<pre>%s</pre>\n", esc(output))
+		} else {
+			sb.WriteString("<p class=\"muted\">No result</p>\n")
+		}
+		sb.WriteString("</details>\n")
+	case aikit.InferenceBlockWebSearch:
+		if b.WebSearch == nil {
+			break
+		}
+		fmt.Fprintf(sb, "<details class=\"search\">\n<summary>Web search <q>%s</q></summary>\n", esc(b.WebSearch.Query))
+		if b.WebSearch.ErrorCode != "" {
+			fmt.Fprintf(sb, "<p class=\"error\">Failed: %s</p>\n", esc(b.WebSearch.ErrorCode))
+		}
+		if len(b.WebSearch.Results) > 0 {
+			sb.WriteString("<ul>\n")
+			for _, result := range b.WebSearch.Results {
+				title := result.Title
+				if title == "" {
+					title = result.URL
+				}
+				fmt.Fprintf(sb, "<li><a href=\"%s\">%s</a>", safeURL(result.URL), esc(title))
+				if result.PageAge != "" {
+					fmt.Fprintf(sb, " <span class=\"muted\">%s</span>", esc(result.PageAge))
+				}
+				sb.WriteString("</li>\n")
+			}
+			sb.WriteString("</ul>\n")
+		}
+		sb.WriteString("</details>\n")
+	case aikit.InferenceBlockViewWebpage:
+		title := b.Text
+		if b.WebFetch != nil && b.WebFetch.Title() != "" {
+			title = b.WebFetch.Title()
+		}
+		fmt.Fprintf(sb, "<p>Viewed <a href=\"%s\">%s</a></p>\n", safeURL(b.Text), esc(title))
+		if b.WebFetch != nil && b.WebFetch.ErrorCode != "" {
+			fmt.Fprintf(sb, "<p class=\"error\">Failed: %s</p>\n", esc(b.WebFetch.ErrorCode))
+		}
+	case aikit.InferenceBlockServerTool:
+		tool := b.ServerTool
+		if tool == nil {
+			break
+		}
+		fmt.Fprintf(sb, "<details class=\"tool\" open>\n<summary><code>%s</code></summary>\n", esc(tool.Name))
+		if tool.Input != "" {
+			fmt.Fprintf(sb, "<div class=\"label\">Input</div>\n<pre>%s</pre>\n", esc(tool.Input))
+		}
+		if output := r.serverToolOutput(tool); output != "" {
+			fmt.Fprintf(sb, "<div class=\"label\">Result</div>\n<pre>%s</pre>\n", esc(output))
+		}
+		if tool.ErrorCode != "" {
+			fmt.Fprintf(sb, "<p class=\"error\">Failed: %s</p>\n", esc(tool.ErrorCode))
+		}
+		for _, file := range tool.Files {
+			fmt.Fprintf(sb, "<p>File: %s</p>\n", esc(serverFileName(file)))
+		}
+		sb.WriteString("</details>\n")
+	}
+}
+
+func htmlSource(c aikit.ThreadCitation) string {
+	title := html.EscapeString(citationTitle(c))
+	if c.URL != "" {
+		return fmt.Sprintf("<a href=\"%s\">%s</a>", safeURL(c.URL), title)
+	}
+	return title
+}
+
+// safeURL escapes a link from the model or a search provider, dropping
+// schemes other than http and https so a transcript can't run script.
+func safeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "#"
+	}
+	return html.EscapeString(raw)
+}
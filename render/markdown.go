@@ -0,0 +1,199 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jacksonzamorano/aikit"
+)
+
+// Markdown renders a transcript as GitHub-flavored Markdown. Thinking is
+// collapsed in <details> elements, tool calls and results are fenced code
+// blocks, and citations become footnotes.
+type Markdown struct {
+	Options
+}
+
+func (r Markdown) Render(w io.Writer, blocks []*aikit.ThreadBlock) error {
+	var sb strings.Builder
+	notes := &footnotes{}
+	for i, m := range messages(blocks, r.Options) {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("### " + m.title())
+		if at := timestamp(m.At); at != "" && r.Timestamps {
+			sb.WriteString(" · " + at)
+		}
+		sb.WriteString("\n")
+		for _, b := range m.Blocks {
+			sb.WriteString("\n")
+			r.block(&sb, b, notes)
+		}
+	}
+	if len(notes.notes) > 0 {
+		sb.WriteString("\n")
+		for i, c := range notes.notes {
+			fmt.Fprintf(&sb, "[^%d]: %s\n", i+1, markdownSource(c))
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func (r Markdown) block(sb *strings.Builder, b *aikit.ThreadBlock, notes *footnotes) {
+	switch b.Type {
+	case aikit.InferenceBlockSystem, aikit.InferenceBlockInput:
+		sb.WriteString(b.Text + "\n")
+	case aikit.InferenceBlockText:
+		sb.WriteString(notes.annotate(b.Text, b.Citations, plain, func(n int) string {
+			return fmt.Sprintf("[^%d]", n)
+		}) + "\n")
+	case aikit.InferenceBlockThinking, aikit.InferenceBlockEncryptedThinking:
+		fmt.Fprintf(sb, "<details>\n<summary>Thinking</summary>\n\n%s\n\n</details>\n", r.thinking(b))
+	case aikit.InferenceBlockInputImage, aikit.InferenceBlockOutputImage:
+		switch {
+		case b.Image == nil:
+			sb.WriteString("*[image]*\n")
+		case r.OmitImages:
+			fmt.Fprintf(sb, "*[image: %s, ~%d bytes]*\n", b.Image.MediaType, approxBytes(b.Image.Base64))
+		default:
+			fmt.Fprintf(sb, "![%s](%s)\n", imageAlt(b), b.Image.GetDataURL())
+		}
+	case aikit.InferenceBlockInputDocument:
+		if b.Document == nil {
+			sb.WriteString("*[document]*\n")
+			break
+		}
+		fmt.Fprintf(sb, "*[document: %s, %s, ~%d bytes]*\n", b.Document.Filename(), b.Document.MediaType, approxBytes(b.Document.Base64))
+	case aikit.InferenceBlockInputAudio, aikit.InferenceBlockAudio:
+		sb.WriteString(audioDescription(b) + "\n")
+	case aikit.InferenceBlockToolCall:
+		if b.ToolCall == nil {
+			break
+		}
+		fmt.Fprintf(sb, "**Tool call** `%s`\n\n%s", b.ToolCall.Name, fence(b.ToolCall.Arguments, "json"))
+		if output, ok := r.toolOutput(b); ok {
+			sb.WriteString("\n**Result**\n\n" + fence(output, ""))
+		} else {
+			sb.WriteString("\n*No result*\n")
+		}
+	case aikit.InferenceBlockWebSearch:
+		if b.WebSearch == nil {
+			break
+		}
+		fmt.Fprintf(sb, "**Web search** %q\n", b.WebSearch.Query)
+		if b.WebSearch.ErrorCode != "" {
+			fmt.Fprintf(sb, "\n*Failed: %s*\n", b.WebSearch.ErrorCode)
+		}
+		if len(b.WebSearch.Results) > 0 {
+			sb.WriteString("\n")
+		}
+		for _, result := range b.WebSearch.Results {
+			fmt.Fprintf(sb, "- [%s](%s)", markdownLinkText(result.Title, result.URL), markdownURL(result.URL))
+			if result.PageAge != "" {
+				fmt.Fprintf(sb, " (%s)", result.PageAge)
+			}
+			sb.WriteString("\n")
+		}
+	case aikit.InferenceBlockViewWebpage:
+		title := b.Text
+		if b.WebFetch != nil && b.WebFetch.Title() != "" {
+			title = b.WebFetch.Title()
+		}
+		fmt.Fprintf(sb, "**Viewed** [%s](%s)\n", markdownLinkText(title, b.Text), markdownURL(b.Text))
+		if b.WebFetch != nil && b.WebFetch.ErrorCode != "" {
+			fmt.Fprintf(sb, "\n*Failed: %s*\n", b.WebFetch.ErrorCode)
+		}
+	case aikit.InferenceBlockServerTool:
+		tool := b.ServerTool
+		if tool == nil {
+			break
+		}
+		fmt.Fprintf(sb, "**%s**\n", tool.Name)
+		if tool.Input != "" {
+			sb.WriteString("\n" + fence(tool.Input, ""))
+		}
+		if output := r.serverToolOutput(tool); output != "" {
+			sb.WriteString("\n**Result**\n\n" + fence(output, ""))
+		}
+		if tool.ErrorCode != "" {
+			fmt.Fprintf(sb, "\n*Failed: %s*\n", tool.ErrorCode)
+		}
+		for _, file := range tool.Files {
+			fmt.Fprintf(sb, "- File: %s\n", serverFileName(file))
+		}
+	}
+}
+
+// fence wraps text in a code fence longer than any backtick run inside it.
+func fence(text string, info string) string {
+	longest, run := 0, 0
+	for _, c := range text {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	marks := strings.Repeat("`", max(3, longest+1))
+	return marks + info + "\n" + strings.TrimSuffix(text, "\n") + "\n" + marks + "\n"
+}
+
+// markdownLinkText returns the text for a link, escaping brackets.
+func markdownLinkText(title string, url string) string {
+	if title == "" {
+		title = url
+	}
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(title)
+}
+
+// markdownURL makes a link from the model or a search provider safe to use
+// as a link destination: schemes other than http and https become "#", and
+// spaces and parentheses, which would end the destination, are escaped.
+func markdownURL(raw string) string {
+	if safeURL(raw) == "#" {
+		return "#"
+	}
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(raw)
+}
+
+func markdownSource(c aikit.ThreadCitation) string {
+	if c.URL != "" {
+		return fmt.Sprintf("[%s](%s)", markdownLinkText(citationTitle(c), c.URL), markdownURL(c.URL))
+	}
+	return citationTitle(c)
+}
+
+func imageAlt(b *aikit.ThreadBlock) string {
+	if b.Type == aikit.InferenceBlockOutputImage {
+		if b.Image.RevisedPrompt != "" {
+			return markdownLinkText(b.Image.RevisedPrompt, "")
+		}
+		return "Generated image"
+	}
+	return "Image"
+}
+
+// audioDescription describes an audio block by its transcript or size.
+func audioDescription(b *aikit.ThreadBlock) string {
+	switch {
+	case b.Audio == nil:
+		return "[audio]"
+	case b.Audio.Transcript != "":
+		return "(spoken) " + b.Audio.Transcript
+	}
+	return fmt.Sprintf("[audio: %s, ~%d bytes]", b.Audio.MediaType, approxBytes(b.Audio.Base64))
+}
+
+func serverFileName(file aikit.ThreadServerToolFile) string {
+	switch {
+	case file.Filename != "":
+		return file.Filename
+	case file.URL != "":
+		return file.URL
+	}
+	return file.ID
+}
@@ -0,0 +1,234 @@
+// Package render turns aikit threads into readable transcripts: Markdown,
+// a self-contained HTML page, or ANSI-colored text for terminals. They are
+// meant for support tickets, debug dumps and logs, not for replaying a
+// conversation to a model.
+package render
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jacksonzamorano/aikit"
+)
+
+// Options control what the renderers include.
+type Options struct {
+	// RedactThinking replaces reasoning text with Redacted.
+	RedactThinking bool
+	// RedactToolOutput replaces tool results and server tool output with
+	// Redacted. Tool names and arguments are still shown.
+	RedactToolOutput bool
+	// Redacted is the text shown in place of redacted content. Empty uses
+	// "[redacted]".
+	Redacted string
+	// HideSystem leaves out system prompts.
+	HideSystem bool
+	// Timestamps shows when each message was written.
+	Timestamps bool
+	// OmitImages shows images as a placeholder instead of inlining their
+	// data, which keeps Markdown transcripts small.
+	OmitImages bool
+}
+
+func (o Options) redacted() string {
+	if o.Redacted != "" {
+		return o.Redacted
+	}
+	return "[redacted]"
+}
+
+// thinking returns the reasoning text to show for a thinking block.
+func (o Options) thinking(b *aikit.ThreadBlock) string {
+	switch {
+	case o.RedactThinking:
+		return o.redacted()
+	case b.Type == aikit.InferenceBlockEncryptedThinking:
+		return "[encrypted]"
+	}
+	return b.Text
+}
+
+// toolOutput returns the output to show for a tool call, and false when the
+// call has no result yet.
+func (o Options) toolOutput(b *aikit.ThreadBlock) (string, bool) {
+	if b.ToolResult == nil {
+		return "", false
+	}
+	if o.RedactToolOutput {
+		return o.redacted(), true
+	}
+	return b.ToolResult.Output, true
+}
+
+// serverToolOutput returns the server tool result to show.
+func (o Options) serverToolOutput(tool *aikit.ThreadServerTool) string {
+	if tool.Result != "" && o.RedactToolOutput {
+		return o.redacted()
+	}
+	return tool.Result
+}
+
+// Renderer writes a transcript of a thread's blocks.
+type Renderer interface {
+	Render(w io.Writer, blocks []*aikit.ThreadBlock) error
+}
+
+// String renders blocks to a string.
+func String(r Renderer, blocks []*aikit.ThreadBlock) string {
+	var sb strings.Builder
+	r.Render(&sb, blocks)
+	return sb.String()
+}
+
+// role is who a message in the transcript is from.
+type role string
+
+const (
+	roleSystem    role = "system"
+	roleUser      role = "user"
+	roleAssistant role = "assistant"
+)
+
+// message is a run of consecutive blocks from the same role and author.
+type message struct {
+	Role   role
+	Author string
+	At     time.Time
+	Blocks []*aikit.ThreadBlock
+}
+
+// title returns the heading for the message.
+func (m message) title() string {
+	if m.Author != "" {
+		return m.Author
+	}
+	switch m.Role {
+	case roleSystem:
+		return "System"
+	case roleUser:
+		return "User"
+	}
+	return "Assistant"
+}
+
+func blockRole(b *aikit.ThreadBlock) role {
+	switch b.Type {
+	case aikit.InferenceBlockSystem:
+		return roleSystem
+	case aikit.InferenceBlockInput, aikit.InferenceBlockInputImage, aikit.InferenceBlockInputDocument, aikit.InferenceBlockInputAudio:
+		return roleUser
+	}
+	return roleAssistant
+}
+
+// messages groups blocks into messages, skipping nil blocks and, with
+// HideSystem, system prompts.
+func messages(blocks []*aikit.ThreadBlock, opts Options) []message {
+	var out []message
+	for _, b := range blocks {
+		if b == nil {
+			continue
+		}
+		r := blockRole(b)
+		if r == roleSystem && opts.HideSystem {
+			continue
+		}
+		if n := len(out); n > 0 && out[n-1].Role == r && out[n-1].Author == b.Author {
+			out[n-1].Blocks = append(out[n-1].Blocks, b)
+			continue
+		}
+		out = append(out, message{Role: r, Author: b.Author, At: b.CreatedAt, Blocks: []*aikit.ThreadBlock{b}})
+	}
+	return out
+}
+
+// timestamp formats a message time, or returns "" without one.
+func timestamp(at time.Time) string {
+	if at.IsZero() {
+		return ""
+	}
+	return at.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// footnotes numbers the sources cited in a transcript. A source cited more
+// than once keeps its first number.
+type footnotes struct {
+	notes []aikit.ThreadCitation
+	index map[string]int
+}
+
+func citationKey(c aikit.ThreadCitation) string {
+	if c.Type == aikit.CitationDocument {
+		return fmt.Sprintf("document:%d", c.DocumentIndex)
+	}
+	return "url:" + c.URL
+}
+
+func (f *footnotes) add(c aikit.ThreadCitation) int {
+	if f.index == nil {
+		f.index = map[string]int{}
+	}
+	key := citationKey(c)
+	if n, ok := f.index[key]; ok {
+		return n
+	}
+	f.notes = append(f.notes, c)
+	f.index[key] = len(f.notes)
+	return len(f.notes)
+}
+
+// annotate splits text at the end of each citation, escaping the text and
+// placing marker(n) after every cited span.
+func (f *footnotes) annotate(text string, citations []aikit.ThreadCitation, escape func(string) string, marker func(n int) string) string {
+	type point struct{ offset, n int }
+	points := make([]point, 0, len(citations))
+	for _, c := range citations {
+		offset := c.EndIndex
+		if offset <= 0 || offset > len(text) {
+			// Citations of the whole block, or offsets from another text.
+			offset = len(text)
+		}
+		for offset < len(text) && !utf8.RuneStart(text[offset]) {
+			offset++
+		}
+		points = append(points, point{offset, f.add(c)})
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].offset < points[j].offset })
+
+	var sb strings.Builder
+	last := 0
+	seen := map[[2]int]bool{}
+	for _, p := range points {
+		if seen[[2]int{p.offset, p.n}] {
+			continue
+		}
+		seen[[2]int{p.offset, p.n}] = true
+		sb.WriteString(escape(text[last:p.offset]))
+		sb.WriteString(marker(p.n))
+		last = p.offset
+	}
+	sb.WriteString(escape(text[last:]))
+	return sb.String()
+}
+
+// citationTitle describes a cited source.
+func citationTitle(c aikit.ThreadCitation) string {
+	switch {
+	case c.Title != "":
+		return c.Title
+	case c.URL != "":
+		return c.URL
+	}
+	return fmt.Sprintf("Document %d", c.DocumentIndex+1)
+}
+
+// approxBytes is the decoded size of base64 data.
+func approxBytes(base64 string) int {
+	return len(base64) * 3 / 4
+}
+
+func plain(s string) string { return s }
@@ -0,0 +1,164 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jacksonzamorano/aikit"
+)
+
+func testThread() *aikit.Thread {
+	thread := aikit.NewProviderState()
+	thread.Clock = func() time.Time { return time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC) }
+	thread.System("Be brief.")
+	thread.InputFrom("alice", "Weather in Paris?")
+	thread.InputImageBase64("aGVsbG8=", "image/png")
+	thread.Blocks[2].Author = "alice"
+	thread.Thinking("t1", "The user wants the weather.")
+	thread.Complete("t1")
+	thread.ToolCall("call_1", "weather", `{"city":"Paris"}`)
+	thread.ToolResult(&aikit.ThreadToolCall{ID: "call_1"}, "Sunny, 21C")
+	thread.WebSearchQuery("ws_1", "paris forecast")
+	thread.WebSearchResult("ws_1", aikit.ThreadWebSearchResult{Title: "Forecast [Paris]", URL: "https://example.com/paris", PageAge: "today"})
+	thread.Text("msg_1", "It's sunny.")
+	thread.Complete("msg_1")
+	text := thread.Blocks[len(thread.Blocks)-1]
+	text.Citations = []aikit.ThreadCitation{
+		{Type: aikit.CitationURL, URL: "https://example.com/paris", Title: "Forecast", EndIndex: 5},
+		{Type: aikit.CitationURL, URL: "https://example.com/paris", Title: "Forecast", EndIndex: len(text.Text)},
+	}
+	thread.ToolCall("call_2", "notify", `{}`)
+	return thread
+}
+
+func TestUnit_Render_Markdown(t *testing.T) {
+	got := String(Markdown{}, testThread().Blocks)
+	want := "### System\n\nBe brief.\n\n" +
+		"### alice\n\nWeather in Paris?\n\n![Image](data:image/png;base64,aGVsbG8=)\n\n" +
+		"### Assistant\n\n<details>\n<summary>Thinking</summary>\n\nThe user wants the weather.\n\n</details>\n\n" +
+		"**Tool call** `weather`\n\n```json\n{\"city\":\"Paris\"}\n```\n\n**Result**\n\n```\nSunny, 21C\n```\n\n" +
+		"**Web search** \"paris forecast\"\n\n- [Forecast \\[Paris\\]](https://example.com/paris) (today)\n\n" +
+		"It's [^1]sunny.[^1]\n\n" +
+		"**Tool call** `notify`\n\n```json\n{}\n```\n\n*No result*\n\n" +
+		"[^1]: [Forecast](https://example.com/paris)\n"
+	if got != want {
+		t.Errorf("unexpected markdown:\n%s\nwant:\n%s", got, want)
+	}
+
+	if got := fence("a ``` b", ""); !strings.HasPrefix(got, "````\n") {
+		t.Errorf("expected a longer fence, got %q", got)
+	}
+}
+
+func TestUnit_Render_MarkdownLinks(t *testing.T) {
+	thread := aikit.NewProviderState()
+	thread.WebSearchQuery("ws_1", "links")
+	for _, url := range []string{"https://en.wikipedia.org/wiki/Go_(game)", "https://example.com/a b", "javascript:alert(1)", "data:text/html,<b>x</b>"} {
+		thread.WebSearchResult("ws_1", aikit.ThreadWebSearchResult{Title: "Link", URL: url})
+	}
+
+	got := String(Markdown{}, thread.Blocks)
+	for _, want := range []string{
+		"- [Link](https://en.wikipedia.org/wiki/Go_%28game%29)\n",
+		"- [Link](https://example.com/a%20b)\n",
+		"- [Link](#)\n- [Link](#)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "javascript:") || strings.Contains(got, "](data:") {
+		t.Errorf("expected unsafe links to be dropped:\n%s", got)
+	}
+}
+
+func TestUnit_Render_HTML(t *testing.T) {
+	thread := testThread()
+	thread.Input("<script>alert(1)</script>")
+	thread.WebSearchQuery("ws_2", "bad")
+	thread.WebSearchResult("ws_2", aikit.ThreadWebSearchResult{Title: "Bad", URL: "javascript:alert(1)"})
+
+	got := String(HTML{Title: "Ticket 1234", Options: Options{Timestamps: true}}, thread.Blocks)
+	for _, want := range []string{
+		"<title>Ticket 1234</title>",
+		`<section class="message user">` + "\n<h2>alice<time",
+		`<img src="data:image/png;base64,aGVsbG8=" alt="Image">`,
+		`<details class="thinking">`,
+		"<pre>{&#34;city&#34;:&#34;Paris&#34;}</pre>",
+		"<pre>Sunny, 21C</pre>",
+		`<li><a href="https://example.com/paris">Forecast [Paris]</a>`,
+		`It&#39;s <sup><a href="#fn-1">[1]</a></sup>sunny.`,
+		`<li id="fn-1"><a href="https://example.com/paris">Forecast</a></li>`,
+		`<p class="muted">No result</p>`,
+		"&lt;script&gt;alert(1)&lt;/script&gt;",
+		`<li><a href="#">Bad</a>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "<script>") {
+		t.Errorf("input was not escaped")
+	}
+}
+
+func TestUnit_Render_ANSI(t *testing.T) {
+	blocks := testThread().Blocks
+	got := String(ANSI{NoColor: true}, blocks)
+	for _, want := range []string{
+		"alice\nWeather in Paris?\n[image: image/png, ~6 bytes]\n",
+		"Thinking\n│ The user wants the weather.\n",
+		"→ weather\n│ {\"city\":\"Paris\"}\n← result\n│ Sunny, 21C\n",
+		"  • Forecast [Paris] https://example.com/paris\n",
+		"It's [1]sunny.[1]\n",
+		"← no result\n",
+		"[1] Forecast https://example.com/paris\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "\x1b[") {
+		t.Errorf("expected no escape codes with NoColor")
+	}
+	if colored := String(ANSI{}, blocks); !strings.Contains(colored, ansiBold+ansiBlue+"alice"+ansiReset) {
+		t.Errorf("expected a colored heading:\n%q", colored)
+	}
+}
+
+func TestUnit_Render_ANSIStripsEscapes(t *testing.T) {
+	thread := testThread()
+	thread.InputFrom("mallory\x1b]0;pwned\x07", "hi\x1b[2J\x1b[31mred\rX\u009b1m\x1bc")
+	thread.ToolCall("call_3", "shell\x1b[8m", `{"cmd":"ls"}`)
+	thread.ToolResult(&aikit.ThreadToolCall{ID: "call_3"}, "a\x1b[1;32mb\x1b[0m\tc\x1b]8;;http://x\x1b\\link\x1b]8;;\x1b\\")
+
+	got := String(ANSI{NoColor: true}, thread.Blocks)
+	for _, want := range []string{"mallory\nhiredX1m\n", "→ shell\n", "│ ab\tclink\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in:\n%q", want, got)
+		}
+	}
+	if strings.ContainsAny(got, "\x1b\r\x07\u009b") {
+		t.Errorf("expected control characters to be removed:\n%q", got)
+	}
+	if colored := String(ANSI{}, thread.Blocks); strings.Contains(colored, "\x1b[2J") || strings.Contains(colored, "\x1b]") {
+		t.Errorf("expected only the renderer's own escape codes:\n%q", colored)
+	}
+}
+
+func TestUnit_Render_Redaction(t *testing.T) {
+	blocks := testThread().Blocks
+	opts := Options{RedactThinking: true, RedactToolOutput: true, HideSystem: true, Redacted: "[hidden]"}
+	for name, r := range map[string]Renderer{"markdown": Markdown{Options: opts}, "html": HTML{Options: opts}, "ansi": ANSI{Options: opts}} {
+		got := String(r, blocks)
+		for _, secret := range []string{"The user wants the weather.", "Sunny, 21C", "Be brief."} {
+			if strings.Contains(got, secret) {
+				t.Errorf("%s: %q not redacted:\n%s", name, secret, got)
+			}
+		}
+		if !strings.Contains(got, "[hidden]") || !strings.Contains(got, "Paris") {
+			t.Errorf("%s: expected redaction markers and the arguments:\n%s", name, got)
+		}
+	}
+}
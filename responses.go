@@ -8,28 +8,72 @@ import (
 )
 
 // ResponsesAPIRequest implements the Responses API shape (OpenAI-style).
+//
+// By default turns are chained with previous_response_id, so only new input
+// is sent after the first response. With Config.Stateless the full input
+// item list is replayed on every request instead.
 type ResponsesAPIRequest struct {
 	Config  *ProviderConfig
 	Request ResponsesRequest
+
+	// summaries holds reasoning summary text by item id until the matching
+	// encrypted reasoning block is replayed.
+	summaries map[string]string
 }
 
 func (p *ResponsesAPIRequest) Name() string {
 	return fmt.Sprintf("responses.%s", p.Config.Name)
 }
 func (p *ResponsesAPIRequest) Transport() GatewayTransport {
-	return TransportSSE
+	return p.Config.transport(TransportSSE)
 }
 
 func (p *ResponsesAPIRequest) PrepareForUpdates() {
+	if p.Config.Stateless {
+		return
+	}
 	p.Request.Inputs = []ResponsesInput{}
 }
 
+// appendUserContent adds content to the last user input if there is one,
+// else creates a new user input.
+func (p *ResponsesAPIRequest) appendUserContent(content ResponsesContent) {
+	if len(p.Request.Inputs) > 0 {
+		lastIdx := len(p.Request.Inputs) - 1
+		if p.Request.Inputs[lastIdx].Role == "user" {
+			p.Request.Inputs[lastIdx].Content = append(p.Request.Inputs[lastIdx].Content, content)
+			return
+		}
+	}
+	p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+		Role:    "user",
+		Content: []ResponsesContent{content},
+	})
+}
+
+// replaying reports whether blocks this provider produced must be sent back,
+// which is the case whenever the server isn't holding the previous response.
+func (p *ResponsesAPIRequest) replaying() bool {
+	return p.Config.Stateless || p.Request.PreviousResponseID == ""
+}
+
+func (p *ResponsesAPIRequest) hostsWebSearch() bool {
+	return p.Config.WebSearchToolName != ""
+}
+
+// hostsWebFetch is false: the hosted search opens pages itself but there is
+// no separate fetch tool.
+func (p *ResponsesAPIRequest) hostsWebFetch() bool {
+	return false
+}
+
 func (p *ResponsesAPIRequest) InitSession(thread *Thread) {
 	tools := []ResponsesTool{}
-	for k := range thread.Tools {
+	toolDefs := thread.toolDefinitions()
+	for k := range toolDefs {
 		tool := ResponsesTool{
-			Description: thread.Tools[k].Description,
-			Parameters:  thread.Tools[k].Parameters,
+			Description: toolDefs[k].Description,
+			Parameters:  toolDefs[k].Parameters,
 			Name:        k,
 			Type:        "function",
 		}
@@ -42,11 +86,33 @@ func (p *ResponsesAPIRequest) InitSession(thread *Thread) {
 		})
 	}
 
+	if thread.ImageOutput {
+		tools = append(tools, ResponsesTool{
+			Type: "image_generation",
+		})
+	}
+	codeExecution := thread.CodeExecution && p.Config.CodeExecutionToolName != ""
+	if codeExecution {
+		tools = append(tools, ResponsesTool{
+			Type:      p.Config.CodeExecutionToolName,
+			Container: &ResponsesToolContainer{Type: "auto"},
+		})
+	}
+
 	p.Request = ResponsesRequest{
 		Inputs: []ResponsesInput{},
 		Tools:  tools,
 		Model:  thread.Model,
-		Stream: true,
+		Stream: p.Config.streaming(),
+	}
+	p.summaries = map[string]string{}
+	if p.Config.Stateless {
+		store := false
+		p.Request.Store = &store
+		p.Request.Include = []string{"reasoning.encrypted_content"}
+	}
+	if codeExecution {
+		p.Request.Include = append(p.Request.Include, "code_interpreter_call.outputs")
 	}
 
 	if thread.Reasoning.Effort != "" {
@@ -78,29 +144,118 @@ func (p *ResponsesAPIRequest) Update(block *ThreadBlock) {
 		if block.Image == nil {
 			return
 		}
-		imgContent := ResponsesContent{
+		p.appendUserContent(ResponsesContent{
 			Typ:      "input_image",
 			ImageUrl: block.Image.GetDataURL(),
+		})
+	case InferenceBlockInputDocument:
+		if block.Document == nil {
+			return
+		}
+		if block.Document.IsText() {
+			p.appendUserContent(ResponsesContent{
+				Typ:  "input_text",
+				Text: block.Document.textFallback(),
+			})
+			return
 		}
-		// Append to last user input if exists, else create new
+		p.appendUserContent(ResponsesContent{
+			Typ:      "input_file",
+			Filename: block.Document.Filename(),
+			FileData: block.Document.GetDataURL(),
+		})
+	case InferenceBlockInputAudio:
+		if block.Audio == nil {
+			return
+		}
+		data, format := block.Audio.portable()
+		p.appendUserContent(ResponsesContent{
+			Typ:        "input_audio",
+			InputAudio: &ResponsesInputAudio{Data: data, Format: format},
+		})
+	case InferenceBlockSystem:
+		p.Request.Instructions = block.Text
+	case InferenceBlockThinking:
+		if block.ProviderID == p.Name() {
+			p.summaries[block.ID] += block.Text
+		}
+	case InferenceBlockEncryptedThinking:
+		if block.ProviderID != p.Name() || !p.replaying() {
+			return
+		}
+		summary := []ResponsesSummary{}
+		if text := p.summaries[block.ID]; text != "" {
+			summary = append(summary, ResponsesSummary{Type: "summary_text", Text: text})
+		}
+		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+			Type:             "reasoning",
+			Summary:          &summary,
+			EncryptedContent: block.Text,
+		})
+	case InferenceBlockText:
+		if block.ProviderID == p.Name() && !p.replaying() {
+			return
+		}
+		content := ResponsesContent{Typ: "output_text", Text: block.Text}
 		if len(p.Request.Inputs) > 0 {
-			lastIdx := len(p.Request.Inputs) - 1
-			if p.Request.Inputs[lastIdx].Role == "user" {
-				p.Request.Inputs[lastIdx].Content = append(
-					p.Request.Inputs[lastIdx].Content,
-					imgContent,
-				)
+			last := &p.Request.Inputs[len(p.Request.Inputs)-1]
+			if last.Type == "message" && last.Role == "assistant" {
+				last.Content = append(last.Content, content)
 				return
 			}
 		}
 		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
-			Role:    "user",
-			Content: []ResponsesContent{imgContent},
+			Type:    "message",
+			Role:    "assistant",
+			Content: []ResponsesContent{content},
+		})
+	case InferenceBlockWebSearch:
+		if block.ProviderID != p.Name() || !p.replaying() || block.WebSearch == nil {
+			return
+		}
+		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+			Type:   "web_search_call",
+			Id:     block.ID,
+			Status: "completed",
+			Action: &ResponsesWebSearchAction{Type: "search", Query: block.WebSearch.Query},
+		})
+	case InferenceBlockViewWebpage:
+		if block.ProviderID != p.Name() || !p.replaying() {
+			return
+		}
+		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+			Type:   "web_search_call",
+			Id:     block.ID,
+			Status: "completed",
+			Action: &ResponsesWebSearchAction{Type: "open_page", Url: block.Text},
+		})
+	case InferenceBlockOutputImage:
+		if block.ProviderID != p.Name() || !p.replaying() || block.Image == nil {
+			return
+		}
+		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+			Type:   "image_generation_call",
+			Id:     block.ID,
+			Status: "completed",
+			Result: block.Image.GetBase64(),
+		})
+	case InferenceBlockServerTool:
+		tool := block.ServerTool
+		if block.ProviderID != p.Name() || !p.replaying() || tool == nil || tool.ResultType != "code_interpreter_call" {
+			return
+		}
+		var input ResponsesCodeInterpreterInput
+		json.Unmarshal([]byte(tool.Input), &input)
+		p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
+			Type:        "code_interpreter_call",
+			Id:          block.ID,
+			Status:      "completed",
+			Code:        input.Code,
+			ContainerId: tool.ContainerID,
+			Outputs:     json.RawMessage(tool.Result),
 		})
-	case InferenceBlockSystem:
-		p.Request.Instructions = block.Text
 	case InferenceBlockToolCall:
-		if block.ProviderID != p.Name() {
+		if block.ProviderID != p.Name() || p.replaying() {
 			p.Request.Inputs = append(p.Request.Inputs, ResponsesInput{
 				Type:       "function_call",
 				ToolCallId: block.ToolCall.ID,
@@ -121,11 +276,19 @@ func (p *ResponsesAPIRequest) Update(block *ThreadBlock) {
 }
 
 func (p *ResponsesAPIRequest) MakeRequest(thread *Thread) *http.Request {
+	return p.httpRequest(p.Config.resolveEndpoint("/v1/responses"))
+}
+
+func (p *ResponsesAPIRequest) httpRequest(endpoint string) *http.Request {
 	body, _ := json.Marshal(p.Request)
-	providerReq, _ := http.NewRequest("POST", p.Config.resolveEndpoint("/v1/responses"), bytes.NewReader(body))
+	providerReq, _ := http.NewRequest("POST", endpoint, bytes.NewReader(body))
 	providerReq.Header.Add("Content-Type", "application/json")
-	providerReq.Header.Add("Accept", "text/event-stream")
-	providerReq.Header.Add("Authorization", fmt.Sprintf("Bearer %s", p.Config.APIKey))
+	if p.Request.Stream {
+		providerReq.Header.Add("Accept", "text/event-stream")
+	} else {
+		providerReq.Header.Add("Accept", "application/json")
+	}
+	p.Config.authorize(providerReq)
 	return providerReq
 }
 
@@ -136,7 +299,60 @@ func (p *ResponsesAPIRequest) OnChunk(rawData []byte, thread *Thread) ChunkResul
 		return ErrorChunkResult(DecodingError("responses", err.Error()))
 	}
 	data.Raw = rawData
+	return p.onEvent(&data, thread)
+}
 
+// OnResponse replays a non-streaming response as the stream events that
+// would have produced it, so both transports build identical threads.
+func (p *ResponsesAPIRequest) OnResponse(rawData []byte, thread *Thread) ChunkResult {
+	var resp ResponsesResult
+	if err := json.Unmarshal(rawData, &resp); err != nil {
+		return ErrorChunkResult(DecodingError("responses", err.Error()))
+	}
+	for _, event := range responsesResultEvents(&resp) {
+		if result := p.onEvent(event, thread); result.Error != nil || result.Done {
+			return result
+		}
+	}
+	return DoneChunkResult()
+}
+
+func responsesResultEvents(resp *ResponsesResult) []*ResponsesStreamEvent {
+	events := []*ResponsesStreamEvent{}
+	for i := range resp.Output {
+		item := &resp.Output[i]
+		switch item.Type {
+		case "reasoning":
+			for _, summary := range item.Summary {
+				events = append(events, &ResponsesStreamEvent{Type: "response.reasoning_summary_text.delta", ItemId: item.Id, Delta: summary.Text})
+			}
+			if len(item.Summary) > 0 {
+				events = append(events, &ResponsesStreamEvent{Type: "response.reasoning_summary_text.done", ItemId: item.Id})
+			}
+		case "message":
+			for _, content := range item.Content {
+				if content.Typ != "output_text" {
+					continue
+				}
+				events = append(events, &ResponsesStreamEvent{Type: "response.output_text.delta", ItemId: item.Id, Delta: content.Text})
+				for _, annotation := range content.Annotations {
+					events = append(events, &ResponsesStreamEvent{Type: "response.output_text.annotation.added", ItemId: item.Id, Annotation: annotation})
+				}
+				events = append(events, &ResponsesStreamEvent{Type: "response.output_text.done", ItemId: item.Id})
+			}
+		}
+		events = append(events, &ResponsesStreamEvent{Type: "response.output_item.done", Item: item})
+	}
+	if resp.Error != nil {
+		raw, _ := json.Marshal(resp.Error)
+		events = append(events, &ResponsesStreamEvent{Type: "response.failed", Raw: raw})
+	} else {
+		events = append(events, &ResponsesStreamEvent{Type: "response.completed", Response: resp})
+	}
+	return events
+}
+
+func (p *ResponsesAPIRequest) onEvent(data *ResponsesStreamEvent, thread *Thread) ChunkResult {
 	switch data.Type {
 	case "response.output_text.delta":
 		thread.Text(data.ItemId, data.Delta)
@@ -150,28 +366,58 @@ func (p *ResponsesAPIRequest) OnChunk(rawData []byte, thread *Thread) ChunkResul
 			switch data.Item.Action.Type {
 			case "search":
 				thread.WebSearchQuery(data.Item.Id, data.Item.Action.Query)
+				for _, source := range data.Item.Action.Sources {
+					thread.WebSearchResult(data.Item.Id, ThreadWebSearchResult{URL: source.Url})
+				}
 				thread.CompleteWebSearch(data.Item.Id)
 			case "open_page":
 				thread.ViewWebpageUrl(data.Item.Id, data.Item.Action.Url)
 			}
+		case "image_generation_call":
+			thread.OutputImage(data.Item.Id, ThreadImage{
+				Base64:        data.Item.Result,
+				MediaType:     generatedImageMediaType(data.Item.OutputFormat),
+				RevisedPrompt: data.Item.RevisedPrompt,
+			})
+			thread.Complete(data.Item.Id)
+		case "code_interpreter_call":
+			p.codeInterpreterCall(data.Item, thread)
 		case "reasoning":
 			for s := range data.Summary {
 				thread.Thinking(data.ItemId, data.Summary[s].Text)
 			}
+			if data.Item.EncryptedContent != "" {
+				thread.EncryptedThinkingWithID(data.Item.Id, data.Item.EncryptedContent)
+			}
 		}
+	case "response.image_generation_call.partial_image":
+		thread.OutputImage(data.ItemId, ThreadImage{
+			Base64:    data.PartialImageB64,
+			MediaType: generatedImageMediaType(data.OutputFormat),
+		})
 	case "response.output_text.annotation.added":
-		thread.Cite(data.ItemId, data.Annotation.Url)
+		if data.Annotation.Typ == "container_file_citation" {
+			p.containerFile(data.Annotation, thread)
+		}
+		p.cite(data.ItemId, data.Annotation, thread)
 	case "response.reasoning_summary_text.delta":
 		thread.Thinking(data.ItemId, data.Delta)
 	case "response.reasoning_summary_text.done":
 		thread.Complete(data.ItemId)
-	case "response.completed":
+	case "response.completed", "response.incomplete":
+		thread.stopReason = data.Response.Status
+		if details := data.Response.IncompleteDetails; details != nil && details.Reason != "" {
+			thread.stopReason = details.Reason
+		}
 		usage := data.Response.Usage
 		thread.Result.CacheReadTokens += usage.InputDetails.CachedTokens
 		thread.Result.InputTokens += (usage.InputTokens + usage.PromptTokens - usage.InputDetails.CachedTokens)
 		thread.Result.OutputTokens += usage.OutputTokens + usage.CompletionTokens
+		thread.Result.ReasoningTokens += usage.OutputDetails.ReasoningTokens
 		thread.ThreadId = data.Response.Id
-		p.Request.PreviousResponseID = data.Response.Id
+		if !p.Config.Stateless {
+			p.Request.PreviousResponseID = data.Response.Id
+		}
 		return DoneChunkResult()
 	case "error", "response.failed":
 		msg := ""
@@ -186,6 +432,81 @@ func (p *ResponsesAPIRequest) OnChunk(rawData []byte, thread *Thread) ChunkResul
 	return AcceptedResult()
 }
 
+// cite records an annotation on a text item. The API counts offsets in
+// characters, which are converted to byte offsets into the block text.
+func (p *ResponsesAPIRequest) cite(itemId string, annotation ResponsesContentAnnotation, thread *Thread) {
+	citation := ThreadCitation{Type: CitationURL, URL: annotation.Url, Title: annotation.Title}
+	if annotation.Typ == "file_citation" || annotation.Typ == "container_file_citation" {
+		citation = ThreadCitation{Type: CitationDocument, Title: annotation.Filename}
+	}
+	if b := thread.getType(itemId, InferenceBlockText); b != nil {
+		citation.StartIndex = runeByteOffset(b.Text, annotation.StartIndex)
+		citation.EndIndex = runeByteOffset(b.Text, annotation.EndIndex)
+	}
+	thread.Cite(itemId, citation)
+}
+
+// codeInterpreterCall records a finished code interpreter call. Image
+// outputs are kept as files; files the code writes are added when the
+// answer cites them.
+func (p *ResponsesAPIRequest) codeInterpreterCall(item *ResponsesOutput, thread *Thread) {
+	input, _ := json.Marshal(ResponsesCodeInterpreterInput{Code: item.Code})
+	thread.ServerTool(item.Id, "code_interpreter")
+	thread.ServerToolInput(item.Id, string(input))
+	outputs := string(item.Outputs)
+	if outputs == "" || outputs == "null" {
+		outputs = "[]"
+	}
+	thread.ServerToolResult(item.Id, "code_interpreter_call", outputs, "")
+	if b := thread.getType(item.Id, InferenceBlockServerTool); b != nil {
+		b.ServerTool.ContainerID = item.ContainerId
+	}
+	var parsed []ResponsesCodeInterpreterOutput
+	json.Unmarshal(item.Outputs, &parsed)
+	for _, output := range parsed {
+		if output.Type == "image" && output.Url != "" {
+			thread.ServerToolFile(item.Id, ThreadServerToolFile{URL: output.Url})
+		}
+	}
+}
+
+// containerFile adds a cited container file to the latest code interpreter
+// call that ran in the same container.
+func (p *ResponsesAPIRequest) containerFile(annotation ResponsesContentAnnotation, thread *Thread) {
+	for i := len(thread.Blocks) - 1; i >= 0; i-- {
+		b := thread.Blocks[i]
+		if b.Type == InferenceBlockServerTool && b.ServerTool != nil && b.ServerTool.ContainerID == annotation.ContainerId {
+			thread.ServerToolFile(b.ID, ThreadServerToolFile{ID: annotation.FileId, Filename: annotation.Filename})
+			return
+		}
+	}
+}
+
+// runeByteOffset returns the byte offset of the n-th character of text,
+// clamped to its length.
+func runeByteOffset(text string, n int) int {
+	count := 0
+	for i := range text {
+		if count == n {
+			return i
+		}
+		count++
+	}
+	return len(text)
+}
+
+// generatedImageMediaType maps the image_generation output format to a media
+// type. The tool produces PNG unless asked otherwise.
+func generatedImageMediaType(format string) string {
+	switch format {
+	case "", "png":
+		return "image/png"
+	case "jpg":
+		return "image/jpeg"
+	}
+	return "image/" + format
+}
+
 func (p *ResponsesAPIRequest) ParseHttpError(code int, body []byte) *AIError {
 	return nil
 }
@@ -0,0 +1,390 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// responsesToolTurns answers with a reasoning item and a function call, then
+// with a final text message.
+var responsesToolTurns = [][]string{
+	{
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","delta":"Check the weather."}`,
+		`{"type":"response.reasoning_summary_text.done","item_id":"rs_1"}`,
+		`{"type":"response.output_item.done","item":{"type":"reasoning","id":"rs_1","encrypted_content":"gAAAA-enc"}}`,
+		`{"type":"response.output_item.done","item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"weather","arguments":"{\"city\":\"Paris\"}"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":10,"output_tokens":5}}}`,
+	},
+	{
+		`{"type":"response.output_text.delta","item_id":"msg_2","delta":"It is sunny."}`,
+		`{"type":"response.output_text.done","item_id":"msg_2"}`,
+		`{"type":"response.output_item.done","item":{"type":"message","id":"msg_2"}}`,
+		`{"type":"response.completed","response":{"id":"resp_2","usage":{"input_tokens":20,"output_tokens":4}}}`,
+	},
+}
+
+func responsesTestServer(t *testing.T, turns [][]string, requests *[]map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		*requests = append(*requests, req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(turns[(len(*requests)-1)%len(turns)]...))
+	}))
+}
+
+func responsesWeatherSession(config *ProviderConfig) *Session {
+	session := config.Session()
+	session.Thread.Model = "o4-mini"
+	session.Thread.Tools = map[string]ToolDefinition{
+		"weather": {Description: "Weather", Parameters: &JsonSchema{Type: "object"}},
+	}
+	session.Thread.HandleToolFunction = func(name string, args string) string {
+		return "sunny"
+	}
+	return session
+}
+
+func inputTypes(req map[string]any) []string {
+	types := []string{}
+	for _, item := range req["input"].([]any) {
+		fields := item.(map[string]any)
+		typ, _ := fields["type"].(string)
+		if typ == "" {
+			typ = fields["role"].(string)
+		}
+		types = append(types, typ)
+	}
+	return types
+}
+
+func TestUnit_Responses_StatelessReplaysEncryptedReasoning(t *testing.T) {
+	requests := []map[string]any{}
+	server := responsesTestServer(t, responsesToolTurns, &requests)
+	defer server.Close()
+
+	config := OpenAIProvider("key")
+	config.BaseURL = server.URL
+	config.Stateless = true
+	session := responsesWeatherSession(&config)
+	session.Thread.Input("Weather in Paris?")
+
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	encrypted := result.Blocks[2]
+	if encrypted.Type != InferenceBlockEncryptedThinking || encrypted.ID != "rs_1" || encrypted.Text != "gAAAA-enc" {
+		t.Fatalf("unexpected encrypted block %+v", encrypted)
+	}
+
+	second := requests[1]
+	if second["store"] != false {
+		t.Errorf("expected store=false, got %v", second["store"])
+	}
+	if include, _ := second["include"].([]any); len(include) != 1 || include[0] != "reasoning.encrypted_content" {
+		t.Errorf("unexpected include %v", second["include"])
+	}
+	if _, ok := second["previous_response_id"]; ok {
+		t.Errorf("stateless requests must not chain, got %v", second["previous_response_id"])
+	}
+
+	types := inputTypes(second)
+	expected := []string{"user", "reasoning", "function_call", "function_call_output"}
+	if len(types) != len(expected) {
+		t.Fatalf("expected inputs %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("expected inputs %v, got %v", expected, types)
+		}
+	}
+	reasoning := second["input"].([]any)[1].(map[string]any)
+	if reasoning["encrypted_content"] != "gAAAA-enc" {
+		t.Errorf("unexpected encrypted content %v", reasoning["encrypted_content"])
+	}
+	summary := reasoning["summary"].([]any)
+	if len(summary) != 1 || summary[0].(map[string]any)["text"] != "Check the weather." {
+		t.Errorf("unexpected summary %v", reasoning["summary"])
+	}
+}
+
+func TestUnit_Responses_StatefulChainsAndRestoredThreadReplays(t *testing.T) {
+	requests := []map[string]any{}
+	server := responsesTestServer(t, responsesToolTurns, &requests)
+	defer server.Close()
+
+	config := OpenAIProvider("key")
+	config.BaseURL = server.URL
+	session := responsesWeatherSession(&config)
+	session.Thread.Input("Weather in Paris?")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	second := requests[1]
+	if second["previous_response_id"] != "resp_1" {
+		t.Errorf("expected chaining to resp_1, got %v", second["previous_response_id"])
+	}
+	if _, ok := second["store"]; ok {
+		t.Errorf("did not expect store to be sent, got %v", second["store"])
+	}
+	if types := inputTypes(second); len(types) != 1 || types[0] != "function_call_output" {
+		t.Errorf("expected only the tool output, got %v", types)
+	}
+
+	// A restored thread has no server-side state to chain from, so its own
+	// reasoning, tool calls and text are replayed.
+	restored := responsesWeatherSession(&config)
+	restored.Thread.Restore(result.Snapshot())
+	restored.Thread.Input("And tomorrow?")
+	requests = requests[:0]
+	restored.Stream(func(*Thread) {})
+
+	first := requests[0]
+	if _, ok := first["previous_response_id"]; ok {
+		t.Errorf("restored thread must not chain, got %v", first["previous_response_id"])
+	}
+	types := inputTypes(first)
+	expected := []string{"user", "reasoning", "function_call", "function_call_output", "message", "user"}
+	if len(types) != len(expected) {
+		t.Fatalf("expected inputs %v, got %v", expected, types)
+	}
+	for i := range expected {
+		if types[i] != expected[i] {
+			t.Fatalf("expected inputs %v, got %v", expected, types)
+		}
+	}
+}
+
+func TestUnit_Responses_DocumentInputFile(t *testing.T) {
+	config := OpenAIProvider("key")
+	p := &ResponsesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Input("Summarize")
+	thread.InputDocumentBase64("JVBERi0xLjQ=", "application/pdf", "", false)
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+	var req struct {
+		Input []ResponsesInput `json:"input"`
+	}
+	json.Unmarshal(body, &req)
+	if len(req.Input) != 1 || len(req.Input[0].Content) != 2 {
+		t.Fatalf("expected document merged into the user input, got %s", body)
+	}
+	file := req.Input[0].Content[1]
+	if file.Typ != "input_file" || file.Filename != "document.pdf" || file.FileData != "data:application/pdf;base64,JVBERi0xLjQ=" {
+		t.Errorf("unexpected file content %+v", file)
+	}
+}
+
+func TestUnit_Responses_AudioInputWrapsPCM(t *testing.T) {
+	config := OpenAIProvider("key")
+	p := &ResponsesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.Input("Transcribe this")
+	thread.InputAudio([]byte{1, 0, 2, 0}, "audio/pcm;rate=16000")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	body, _ := io.ReadAll(p.MakeRequest(thread).Body)
+	var req struct {
+		Input []ResponsesInput `json:"input"`
+	}
+	json.Unmarshal(body, &req)
+	if len(req.Input) != 1 || len(req.Input[0].Content) != 2 {
+		t.Fatalf("expected audio merged into the user input, got %s", body)
+	}
+	audio := req.Input[0].Content[1]
+	if audio.Typ != "input_audio" || audio.InputAudio == nil || audio.InputAudio.Format != "wav" {
+		t.Fatalf("unexpected audio content %+v", audio)
+	}
+	wav, _ := (&ThreadAudio{Base64: audio.InputAudio.Data, MediaType: "audio/wav"}).Data()
+	if len(wav) != 48 || string(wav[:4]) != "RIFF" {
+		t.Errorf("expected PCM wrapped as WAV, got % x", wav)
+	}
+}
+
+func TestUnit_Responses_ImageGenerationCall(t *testing.T) {
+	requests := []map[string]any{}
+	server := responsesTestServer(t, [][]string{{
+		`{"type":"response.image_generation_call.partial_image","item_id":"ig_1","output_index":0,"partial_image_index":0,"partial_image_b64":"cGFydGlhbA=="}`,
+		`{"type":"response.output_item.done","item":{"type":"image_generation_call","id":"ig_1","status":"completed","result":"ZmluYWw=","revised_prompt":"A red fox","output_format":"png"}}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","delta":"Here it is."}`,
+		`{"type":"response.output_text.done","item_id":"msg_1"}`,
+		`{"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":10,"output_tokens":5}}}`,
+	}}, &requests)
+	defer server.Close()
+
+	config := OpenAIProvider("key")
+	config.BaseURL = server.URL
+	config.Stateless = true
+	session := config.Session()
+	session.Thread.Model = "gpt-5"
+	session.Thread.ImageOutput = true
+	session.Thread.Input("Draw a fox")
+
+	partial := false
+	result := session.Stream(func(thread *Thread) {
+		for _, b := range thread.Blocks {
+			if b.Type == InferenceBlockOutputImage && !b.Complete && b.Image.Base64 == "cGFydGlhbA==" {
+				partial = true
+			}
+		}
+	})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if tools := requests[0]["tools"].([]any); len(tools) != 1 || tools[0].(map[string]any)["type"] != "image_generation" {
+		t.Errorf("expected the image_generation tool, got %v", requests[0]["tools"])
+	}
+	if !partial {
+		t.Errorf("expected the partial image to be reported")
+	}
+	image := result.Blocks[1]
+	if image.Type != InferenceBlockOutputImage || !image.Complete || image.ID != "ig_1" {
+		t.Fatalf("unexpected image block %+v", image)
+	}
+	if image.Image.Base64 != "ZmluYWw=" || image.Image.MediaType != "image/png" || image.Image.RevisedPrompt != "A red fox" {
+		t.Errorf("unexpected image %+v", image.Image)
+	}
+
+	// Stateless threads send the generated image back.
+	session.Thread.Input("Make it blue")
+	session.Stream(func(*Thread) {})
+	items := requests[1]["input"].([]any)
+	call := items[1].(map[string]any)
+	if call["type"] != "image_generation_call" || call["id"] != "ig_1" || call["result"] != "ZmluYWw=" {
+		t.Errorf("unexpected replayed image %v", call)
+	}
+}
+
+func TestUnit_Responses_URLCitationOffsets(t *testing.T) {
+	config := OpenAIProvider("key")
+	p := &ResponsesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	events := []string{
+		`{"type":"response.output_text.delta","item_id":"msg_1","delta":"Café prices rose (example.com)."}`,
+		`{"type":"response.output_text.annotation.added","item_id":"msg_1","annotation":{"type":"url_citation","url":"https://example.com","title":"Prices","start_index":17,"end_index":30}}`,
+	}
+	for _, event := range events {
+		p.OnChunk([]byte(event), thread)
+	}
+	citations := thread.Blocks[0].Citations
+	if len(citations) != 1 {
+		t.Fatalf("expected one citation, got %+v", citations)
+	}
+	c := citations[0]
+	if c.Type != CitationURL || c.URL != "https://example.com" || c.Title != "Prices" {
+		t.Errorf("unexpected citation %+v", c)
+	}
+	if cited := thread.Blocks[0].Text[c.StartIndex:c.EndIndex]; cited != "(example.com)" {
+		t.Errorf("offsets should cover the cited text, got %q", cited)
+	}
+}
+
+func TestUnit_Responses_CodeInterpreterCall(t *testing.T) {
+	requests := []map[string]any{}
+	server := responsesTestServer(t, [][]string{{
+		`{"type":"response.output_item.done","item":{"type":"code_interpreter_call","id":"ci_1","status":"completed","code":"plot()","container_id":"cntr_1","outputs":[{"type":"logs","logs":"ok"},{"type":"image","url":"https://files.example/plot.png"}]}}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","delta":"See data.csv."}`,
+		`{"type":"response.output_text.annotation.added","item_id":"msg_1","annotation":{"type":"container_file_citation","container_id":"cntr_1","file_id":"cfile_1","filename":"data.csv","start_index":4,"end_index":12}}`,
+		`{"type":"response.output_text.done","item_id":"msg_1"}`,
+		`{"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":10,"output_tokens":5}}}`,
+	}}, &requests)
+	defer server.Close()
+
+	config := OpenAIProvider("key")
+	config.BaseURL = server.URL
+	config.Stateless = true
+	session := config.Session()
+	session.Thread.Model = "gpt-5"
+	session.Thread.CodeExecution = true
+	session.Thread.Input("Plot it")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	tools := requests[0]["tools"].([]any)
+	if len(tools) != 1 || tools[0].(map[string]any)["type"] != "code_interpreter" {
+		t.Errorf("expected the code_interpreter tool, got %v", tools)
+	}
+	include, _ := json.Marshal(requests[0]["include"])
+	if string(include) != `["reasoning.encrypted_content","code_interpreter_call.outputs"]` {
+		t.Errorf("unexpected include %s", include)
+	}
+
+	call := result.Blocks[1]
+	if call.Type != InferenceBlockServerTool || !call.Complete || call.ServerTool.Name != "code_interpreter" || call.ServerTool.ContainerID != "cntr_1" {
+		t.Fatalf("unexpected code interpreter block %+v", call)
+	}
+	files := call.ServerTool.Files
+	if len(files) != 2 || files[0].URL != "https://files.example/plot.png" || files[1].ID != "cfile_1" || files[1].Filename != "data.csv" {
+		t.Errorf("unexpected files %+v", files)
+	}
+	if c := result.Blocks[2].Citations; len(c) != 1 || c[0].Type != CitationDocument || c[0].Title != "data.csv" {
+		t.Errorf("unexpected citation %+v", c)
+	}
+
+	// Stateless threads send the call back.
+	session.Thread.Input("Thanks")
+	session.Stream(func(*Thread) {})
+	replayed := requests[1]["input"].([]any)[1].(map[string]any)
+	if replayed["type"] != "code_interpreter_call" || replayed["id"] != "ci_1" || replayed["code"] != "plot()" || replayed["container_id"] != "cntr_1" {
+		t.Errorf("unexpected replayed call %v", replayed)
+	}
+	if outputs, _ := replayed["outputs"].([]any); len(outputs) != 2 {
+		t.Errorf("expected the outputs to be replayed, got %v", replayed["outputs"])
+	}
+}
+
+func TestUnit_Responses_ReasoningUsage(t *testing.T) {
+	config := OpenAIProvider("key")
+	p := &ResponsesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	event := `{"type":"response.incomplete","response":{"id":"resp_1","status":"incomplete","incomplete_details":{"reason":"max_output_tokens"},
+		"usage":{"input_tokens":50,"input_tokens_details":{"cached_tokens":10},"output_tokens":400,"output_tokens_details":{"reasoning_tokens":380}}}}`
+	if result := p.OnChunk([]byte(event), thread); !result.Done {
+		t.Fatalf("expected the response to end, got %+v", result)
+	}
+	want := ThreadUsage{InputTokens: 40, CacheReadTokens: 10, OutputTokens: 400, ReasoningTokens: 380}
+	if thread.Result != want || thread.stopReason != "max_output_tokens" {
+		t.Errorf("unexpected usage %+v (%s)", thread.Result, thread.stopReason)
+	}
+}
+
+func TestUnit_Responses_ReplaysWebSearchCallIDs(t *testing.T) {
+	config := OpenAIProvider("key")
+	config.Stateless = true
+	p := &ResponsesAPIRequest{Config: &config}
+	thread := NewProviderState()
+	thread.CurrentProvider = p.Name()
+	thread.WebSearchQuery("ws_1", "weather paris")
+	thread.ViewWebpageUrl("ws_2", "https://example.com")
+	p.InitSession(thread)
+	for _, b := range thread.Blocks {
+		p.Update(b)
+	}
+	if len(p.Request.Inputs) != 2 {
+		t.Fatalf("expected two replayed calls, got %+v", p.Request.Inputs)
+	}
+	for i, want := range []string{"ws_1", "ws_2"} {
+		if item := p.Request.Inputs[i]; item.Type != "web_search_call" || item.Id != want {
+			t.Errorf("expected web_search_call %q, got %+v", want, item)
+		}
+	}
+}
@@ -7,7 +7,7 @@ type ResponsesRequest struct {
 	Inputs             []ResponsesInput    `json:"input"`
 	Tools              []ResponsesTool     `json:"tools,omitempty"`
 	Stream             bool                `json:"stream,omitempty"`
-	Store              bool                `json:"store,omitempty"`
+	Store              *bool               `json:"store,omitempty"`
 	Include            []string            `json:"include,omitempty"`
 	Instructions       string              `json:"instructions,omitempty"`
 	PreviousResponseID string              `json:"previous_response_id,omitempty"`
@@ -35,26 +35,42 @@ type ResponsesTool struct {
 	Name        string      `json:"name,omitempty"`
 	Parameters  *JsonSchema `json:"parameters,omitempty"`
 	Description string      `json:"description,omitempty"`
+	// Container is the sandbox for the code_interpreter tool.
+	Container *ResponsesToolContainer `json:"container,omitempty"`
+}
+
+type ResponsesToolContainer struct {
+	Type string `json:"type"`
 }
 
 type ResponsesResult struct {
-	Id     string            `json:"id"`
-	Output []ResponsesOutput `json:"output"`
-	Usage  ResponsesUsage    `json:"usage"`
-	Error  *any              `json:"error"`
+	Id                string                      `json:"id"`
+	Status            string                      `json:"status,omitempty"`
+	Output            []ResponsesOutput           `json:"output"`
+	Usage             ResponsesUsage              `json:"usage"`
+	IncompleteDetails *ResponsesIncompleteDetails `json:"incomplete_details,omitempty"`
+	Error             *any                        `json:"error"`
+}
+type ResponsesIncompleteDetails struct {
+	Reason string `json:"reason"`
 }
 type ResponsesUsage struct {
-	InputTokens      int64               `json:"input_tokens"`
-	PromptTokens     int64               `json:"prompt_tokens"`
-	InputDetails     ResponsesInputUsage `json:"input_tokens_details"`
-	OutputTokens     int64               `json:"output_tokens"`
-	CompletionTokens int64               `json:"completion_tokens"`
+	InputTokens      int64                `json:"input_tokens"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	InputDetails     ResponsesInputUsage  `json:"input_tokens_details"`
+	OutputTokens     int64                `json:"output_tokens"`
+	CompletionTokens int64                `json:"completion_tokens"`
+	OutputDetails    ResponsesOutputUsage `json:"output_tokens_details"`
 }
 type ResponsesInputUsage struct {
 	CachedTokens int64 `json:"cached_tokens"`
 }
+type ResponsesOutputUsage struct {
+	ReasoningTokens int64 `json:"reasoning_tokens"`
+}
 type ResponsesOutput struct {
 	Action    *ResponsesWebSearchAction `json:"action,omitempty"`
+	Summary   []ResponsesSummary        `json:"summary,omitempty"`
 	Role      string                    `json:"role,omitempty"`
 	Content   []ResponsesContent        `json:"content,omitempty"`
 	Type      string                    `json:"type,omitempty"`
@@ -62,6 +78,33 @@ type ResponsesOutput struct {
 	Name      string                    `json:"name,omitempty"`
 	CallId    string                    `json:"call_id,omitempty"`
 	Arguments string                    `json:"arguments,omitempty"`
+	// EncryptedContent is set on reasoning items when requested with
+	// include: ["reasoning.encrypted_content"].
+	EncryptedContent string `json:"encrypted_content,omitempty"`
+
+	// Image generation call items.
+	Result        string `json:"result,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+	OutputFormat  string `json:"output_format,omitempty"`
+
+	// Code interpreter call items.
+	Code        string          `json:"code,omitempty"`
+	ContainerId string          `json:"container_id,omitempty"`
+	Outputs     json.RawMessage `json:"outputs,omitempty"`
+}
+
+// ResponsesCodeInterpreterInput is the input kept on a code interpreter
+// server tool block.
+type ResponsesCodeInterpreterInput struct {
+	Code string `json:"code"`
+}
+
+// ResponsesCodeInterpreterOutput is a log or image output of a code
+// interpreter call.
+type ResponsesCodeInterpreterOutput struct {
+	Type string `json:"type"`
+	Logs string `json:"logs,omitempty"`
+	Url  string `json:"url,omitempty"`
 }
 type ResponsesOutputToolCall struct {
 	Id        string         `json:"id,omitempty"`
@@ -72,11 +115,28 @@ type ResponsesContent struct {
 	Typ         string                       `json:"type"`
 	Text        string                       `json:"text,omitempty"`
 	ImageUrl    string                       `json:"image_url,omitempty"`
+	Filename    string                       `json:"filename,omitempty"`
+	FileData    string                       `json:"file_data,omitempty"`
+	InputAudio  *ResponsesInputAudio         `json:"input_audio,omitempty"`
 	Annotations []ResponsesContentAnnotation `json:"annotations,omitempty"`
 }
+
+// ResponsesInputAudio is base64 audio in wav or mp3 format.
+type ResponsesInputAudio struct {
+	Data   string `json:"data"`
+	Format string `json:"format"`
+}
 type ResponsesContentAnnotation struct {
-	Typ string `json:"type"`
-	Url string `json:"url"`
+	Typ        string `json:"type"`
+	Url        string `json:"url,omitempty"`
+	Title      string `json:"title,omitempty"`
+	Filename   string `json:"filename,omitempty"`
+	StartIndex int    `json:"start_index,omitempty"`
+	EndIndex   int    `json:"end_index,omitempty"`
+	// Container file citations point to a file made by the code
+	// interpreter.
+	ContainerId string `json:"container_id,omitempty"`
+	FileId      string `json:"file_id,omitempty"`
 }
 
 type ResponsesInput struct {
@@ -89,6 +149,22 @@ type ResponsesInput struct {
 	Content    []ResponsesContent `json:"content,omitempty"`
 	ToolCallId string             `json:"call_id,omitempty"`
 	Output     any                `json:"output,omitempty"`
+
+	// Reasoning items. Summary is a pointer because the API requires the
+	// field even when empty.
+	Summary          *[]ResponsesSummary `json:"summary,omitempty"`
+	EncryptedContent string              `json:"encrypted_content,omitempty"`
+
+	// Web search call items.
+	Action *ResponsesWebSearchAction `json:"action,omitempty"`
+
+	// Image generation call items.
+	Result string `json:"result,omitempty"`
+
+	// Code interpreter call items.
+	Code        string          `json:"code,omitempty"`
+	ContainerId string          `json:"container_id,omitempty"`
+	Outputs     json.RawMessage `json:"outputs,omitempty"`
 }
 type ResponsesInputMessage struct {
 	Role    string             `json:"role"`
@@ -121,6 +197,10 @@ type ResponsesStreamEvent struct {
 	Part       ResponsesPartEvent         `json:"part"`
 	Text       string                     `json:"text,omitempty"`
 
+	// Image generation previews.
+	PartialImageB64 string `json:"partial_image_b64,omitempty"`
+	OutputFormat    string `json:"output_format,omitempty"`
+
 	ResponseID string           `json:"response_id,omitempty"`
 	ID         string           `json:"id,omitempty"`
 	Response   *ResponsesResult `json:"response,omitempty"`
@@ -132,8 +212,15 @@ type ResponsesStreamEvent struct {
 
 type ResponsesWebSearchAction struct {
 	Type  string `json:"type"`
-	Query string `json:"query"`
-	Url   string `json:"url"`
+	Query string `json:"query,omitempty"`
+	Url   string `json:"url,omitempty"`
+	// Sources is returned with include: ["web_search_call.action.sources"].
+	Sources []ResponsesWebSearchSource `json:"sources,omitempty"`
+}
+
+type ResponsesWebSearchSource struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
 }
 
 type ResponsesPartEvent struct {
@@ -3,7 +3,9 @@ package aikit
 import (
 	"encoding/json"
 	"encoding/xml"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestSnapshot_FullRoundTrip(t *testing.T) {
@@ -413,3 +415,317 @@ func TestThread_EncryptedThinkingProviderID(t *testing.T) {
 		t.Errorf("ProviderID mismatch: got %q, want %q", thread.Blocks[0].ProviderID, "messages.anthropic")
 	}
 }
+
+func TestSnapshot_DocumentSerialization(t *testing.T) {
+	thread := &Thread{
+		Blocks: []*ThreadBlock{},
+	}
+	thread.InputDocument([]byte("%PDF-1.4"), "application/pdf", "report.pdf", true)
+
+	data, err := json.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var fromJSON Snapshot
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	data, err = xml.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal(data, &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		if len(snapshot.Blocks) != 1 || snapshot.Blocks[0].Type != InferenceBlockInputDocument {
+			t.Fatalf("%s: expected one document block, got %+v", name, snapshot.Blocks)
+		}
+		doc := snapshot.Blocks[0].Document
+		if doc == nil {
+			t.Fatalf("%s: document not preserved", name)
+		}
+		if doc.Base64 != "JVBERi0xLjQ=" || doc.MediaType != "application/pdf" || doc.Title != "report.pdf" || !doc.Citations {
+			t.Errorf("%s: document mismatch: %+v", name, doc)
+		}
+	}
+}
+
+func TestSnapshot_AudioSerialization(t *testing.T) {
+	thread := &Thread{
+		Blocks: []*ThreadBlock{},
+	}
+	thread.InputAudio([]byte("RIFF"), "audio/wav")
+	thread.Audio("a1", ThreadAudio{Base64: "AQACAAM=", MediaType: "audio/pcm", ID: "audio_1", ExpiresAt: 1900000000, Transcript: "Hello"})
+
+	data, err := json.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var fromJSON Snapshot
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	data, err = xml.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal(data, &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		if len(snapshot.Blocks) != 2 || snapshot.Blocks[0].Audio == nil || snapshot.Blocks[1].Audio == nil {
+			t.Fatalf("%s: expected two audio blocks, got %+v", name, snapshot.Blocks)
+		}
+		if in := snapshot.Blocks[0]; in.Type != InferenceBlockInputAudio || in.Audio.Base64 != "UklGRg==" || in.Audio.MediaType != "audio/wav" {
+			t.Errorf("%s: input audio mismatch: %+v", name, in.Audio)
+		}
+		if out := *snapshot.Blocks[1].Audio; out != *thread.Blocks[1].Audio {
+			t.Errorf("%s: output audio mismatch: %+v", name, out)
+		}
+	}
+}
+
+func TestSnapshot_OutputImageSerialization(t *testing.T) {
+	thread := &Thread{
+		Blocks: []*ThreadBlock{},
+	}
+	thread.OutputImageWithSignature("ig_1", ThreadImage{Base64: "Zm94", MediaType: "image/png", RevisedPrompt: "A fox"}, "sig")
+	thread.Complete("ig_1")
+
+	data, err := json.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var fromJSON Snapshot
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	data, err = xml.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal(data, &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		if len(snapshot.Blocks) != 1 || snapshot.Blocks[0].Image == nil {
+			t.Fatalf("%s: expected one image block, got %+v", name, snapshot.Blocks)
+		}
+		b := snapshot.Blocks[0]
+		if b.Type != InferenceBlockOutputImage || b.ID != "ig_1" || !b.Complete || b.Signature != "sig" || *b.Image != *thread.Blocks[0].Image {
+			t.Errorf("%s: image block mismatch: %+v %+v", name, b, b.Image)
+		}
+	}
+}
+
+func TestSnapshot_CitationSerialization(t *testing.T) {
+	thread := &Thread{
+		Blocks: []*ThreadBlock{},
+	}
+	thread.Text("t1", "Revenue grew 12%.")
+	thread.Cite("t1", ThreadCitation{Type: CitationDocument, DocumentIndex: 2, Title: "Q3 report", CitedText: "Revenue grew 12%", StartIndex: 0, EndIndex: 17})
+	thread.Cite("t1", ThreadCitation{Type: CitationURL, URL: "https://example.com", Title: "Example", StartIndex: 8, EndIndex: 17})
+
+	data, err := json.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var fromJSON Snapshot
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	data, err = xml.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal(data, &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		got := snapshot.Blocks[0].Citations
+		if len(got) != 2 || got[0] != thread.Blocks[0].Citations[0] || got[1] != thread.Blocks[0].Citations[1] {
+			t.Errorf("%s: citations mismatch: %+v", name, got)
+		}
+	}
+}
+
+func TestSnapshot_LegacyStringCitations(t *testing.T) {
+	legacy := ThreadCitation{Type: CitationURL, URL: "https://example.com"}
+
+	var fromJSON Snapshot
+	if err := json.Unmarshal([]byte(`{"blocks":[{"type":"text","text":"Hi","complete":true,"citations":["https://example.com"]}]}`), &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal([]byte(`<Snapshot><blocks><block type="text" complete="true"><text>Hi</text><citations><citation>https://example.com</citation></citations></block></blocks></Snapshot>`), &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		if got := snapshot.Blocks[0].Citations; len(got) != 1 || got[0] != legacy {
+			t.Errorf("%s: expected legacy URL citation, got %+v", name, got)
+		}
+	}
+}
+
+func TestSnapshot_WebResultDetailsSerialization(t *testing.T) {
+	thread := &Thread{
+		Blocks: []*ThreadBlock{},
+	}
+	thread.WebSearchQuery("s1", "go 1.24")
+	thread.WebSearchResult("s1", ThreadWebSearchResult{Title: "Go 1.24", URL: "https://go.dev", PageAge: "2 days ago", Snippet: "Release notes", EncryptedContent: "Eqg"})
+	thread.WebSearchError("s2", "max_uses_exceeded")
+	thread.ViewWebpageUrl("f1", "https://go.dev")
+	thread.WebFetchResult("f1", ThreadWebFetch{
+		URL:         "https://go.dev",
+		RetrievedAt: "2025-08-25T10:30:00Z",
+		Page:        &ThreadDocument{Base64: "R28=", MediaType: "text/plain", Title: "Go"},
+	})
+	thread.WebFetchResult("f2", ThreadWebFetch{URL: "https://example.com", ErrorCode: "url_not_accessible"})
+
+	data, err := json.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	var fromJSON Snapshot
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	data, err = xml.Marshal(thread.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed to marshal XML: %v", err)
+	}
+	var fromXML Snapshot
+	if err := xml.Unmarshal(data, &fromXML); err != nil {
+		t.Fatalf("Failed to unmarshal XML: %v", err)
+	}
+
+	original, _ := json.Marshal(thread.Blocks)
+	for name, snapshot := range map[string]Snapshot{"json": fromJSON, "xml": fromXML} {
+		restored, _ := json.Marshal(snapshot.Blocks)
+		if string(restored) != string(original) {
+			t.Errorf("%s: blocks differ:\ngot  %s\nwant %s", name, restored, original)
+		}
+	}
+}
+
+func TestSnapshot_BlockMetadataSerialization(t *testing.T) {
+	created := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	thread := NewProviderState()
+	thread.Clock = func() time.Time { return created }
+	thread.InputFrom("alice", "Hello")
+	thread.Blocks[0].Metadata = ThreadMetadata{"channel": "support", "ticket": "1234"}
+	thread.Blocks[0].CompletedAt = created.Add(time.Second)
+
+	check := func(t *testing.T, snapshot *Snapshot) {
+		t.Helper()
+		b := snapshot.Blocks[0]
+		if b.Author != "alice" || !b.CreatedAt.Equal(created) || !b.CompletedAt.Equal(created.Add(time.Second)) {
+			t.Errorf("attribution or timestamps not preserved: %+v", b)
+		}
+		if len(b.Metadata) != 2 || b.Metadata["channel"] != "support" || b.Metadata["ticket"] != "1234" {
+			t.Errorf("metadata not preserved: %v", b.Metadata)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, _ := json.Marshal(thread.Snapshot())
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		check(t, &snapshot)
+	})
+	t.Run("xml", func(t *testing.T) {
+		data, err := xml.Marshal(thread.Snapshot())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), `<metadata><entry key="channel">support</entry><entry key="ticket">1234</entry></metadata>`) {
+			t.Errorf("unexpected metadata XML: %s", data)
+		}
+		var snapshot Snapshot
+		if err := xml.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		check(t, &snapshot)
+	})
+	t.Run("clone", func(t *testing.T) {
+		clone := thread.Blocks[0].Clone()
+		clone.Metadata["channel"] = "sales"
+		if thread.Blocks[0].Metadata["channel"] != "support" {
+			t.Errorf("expected the clone's metadata to be a copy")
+		}
+	})
+}
+
+func TestSnapshot_TurnUsageSerialization(t *testing.T) {
+	started := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	thread := NewProviderState()
+	thread.Input("Hello")
+	thread.Turns = []TurnUsage{
+		{
+			ThreadUsage:      ThreadUsage{InputTokens: 100, OutputTokens: 20, CacheWriteTokens: 50, CacheWrite1hTokens: 50},
+			Model:            "claude-sonnet-4-5",
+			Provider:         "anthropic",
+			StartedAt:        started,
+			Latency:          1500 * time.Millisecond,
+			TimeToFirstToken: 300 * time.Millisecond,
+			StopReason:       "tool_use",
+		},
+		{
+			ThreadUsage: ThreadUsage{InputTokens: 10, OutputTokens: 5, CacheReadTokens: 50, WebSearches: 1},
+			Model:       "claude-sonnet-4-5",
+			Provider:    "anthropic",
+			StartedAt:   started.Add(2 * time.Second),
+			Latency:     time.Second,
+			StopReason:  "end_turn",
+		},
+	}
+
+	check := func(t *testing.T, restored *Thread) {
+		t.Helper()
+		if len(restored.Turns) != 2 || restored.Turns[0] != thread.Turns[0] || restored.Turns[1] != thread.Turns[1] {
+			t.Errorf("turns not preserved:\n%+v\n%+v", restored.Turns, thread.Turns)
+		}
+		want := ThreadUsage{InputTokens: 110, OutputTokens: 25, CacheWriteTokens: 50, CacheWrite1hTokens: 50, CacheReadTokens: 50, WebSearches: 1}
+		if restored.Result != want {
+			t.Errorf("expected the total to be derived from the turns, got %+v", restored.Result)
+		}
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, _ := json.Marshal(thread.Snapshot())
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		restored := NewProviderState()
+		restored.Restore(&snapshot)
+		check(t, restored)
+	})
+	t.Run("xml", func(t *testing.T) {
+		data, _ := xml.Marshal(thread.Snapshot())
+		var snapshot Snapshot
+		if err := xml.Unmarshal(data, &snapshot); err != nil {
+			t.Fatal(err)
+		}
+		restored := NewProviderState()
+		restored.Restore(&snapshot)
+		check(t, restored)
+	})
+}
@@ -6,12 +6,19 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
 type GatewayTransport string
 
 const (
 	TransportSSE GatewayTransport = "sse"
+	// TransportEventStream is the AWS event-stream binary framing used by Bedrock.
+	TransportEventStream GatewayTransport = "eventstream"
+	// TransportNDJSON is newline-delimited JSON, one chunk per line (Ollama).
+	TransportNDJSON GatewayTransport = "ndjson"
+	// TransportJSON is a single non-streaming JSON response body.
+	TransportJSON GatewayTransport = "json"
 )
 
 type ChunkResult struct {
@@ -39,8 +46,17 @@ func ErrorChunkResult(err *AIError) ChunkResult {
 
 type Session struct {
 	Provider APIRequest
-	Thread    *Thread
+	Thread   *Thread
 	Debug    bool
+	// Store saves the thread under StoreID after every completed request.
+	// Resume sets both.
+	Store   ThreadStore
+	StoreID string
+
+	// storeVersion is the version of the thread last saved to Store.
+	storeVersion int64
+	// firstToken is when the current request first updated the thread.
+	firstToken time.Time
 }
 
 func CreateResponsesSession(config *ProviderConfig) *Session {
@@ -75,9 +91,88 @@ func CreateAIStudioSession(config *ProviderConfig) *Session {
 		},
 	}
 }
+func CreateBedrockSession(config *ProviderConfig) *Session {
+	return &Session{
+		Thread: NewProviderState(),
+		Provider: &BedrockAPIRequest{
+			Config: config,
+		},
+	}
+}
+func CreateOllamaSession(config *ProviderConfig) *Session {
+	return &Session{
+		Thread: NewProviderState(),
+		Provider: &OllamaAPIRequest{
+			Config: config,
+		},
+	}
+}
+func CreateAzureCompletionsSession(config *ProviderConfig) *Session {
+	return &Session{
+		Thread: NewProviderState(),
+		Provider: &AzureCompletionsAPIRequest{
+			CompletionsAPIRequest{Config: config},
+		},
+	}
+}
+func CreateAzureResponsesSession(config *ProviderConfig) *Session {
+	return &Session{
+		Thread: NewProviderState(),
+		Provider: &AzureResponsesAPIRequest{
+			ResponsesAPIRequest{Config: config},
+		},
+	}
+}
+
+// onChunk forwards a decoded payload to the provider and notifies onPartial.
+// It returns whether the stream should continue.
+func (s *Session) onChunk(data []byte, onPartial func(*Thread)) (bool, error) {
+	result := s.Provider.OnChunk(data, s.Thread)
+	if s.Thread.TakeUpdate() {
+		if s.firstToken.IsZero() {
+			s.firstToken = time.Now()
+		}
+		onPartial(s.Thread)
+	}
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.Done {
+		return false, nil
+	}
+	return true, nil
+}
+
+// update passes the blocks from index from onwards to the provider, running
+// tool calls that have no result yet. It returns the new block count.
+func (s *Session) update(from int) int {
+	for ; from < len(s.Thread.Blocks); from++ {
+		block := s.Thread.Blocks[from]
+
+		// Skip thinking blocks from different providers
+		if block.Type == InferenceBlockThinking || block.Type == InferenceBlockEncryptedThinking {
+			if block.ProviderID != "" && block.ProviderID != s.Provider.Name() {
+				continue
+			}
+		}
+
+		switch block.Type {
+		case InferenceBlockToolCall:
+			// Only execute tool if it doesn't already have a result (for restored sessions)
+			if block.ToolResult == nil && !s.Thread.runWebTool(block) {
+				res := s.Thread.HandleToolFunction(block.ToolCall.Name, block.ToolCall.Arguments)
+				s.Thread.ToolResult(block.ToolCall, res)
+			}
+		}
+		s.Provider.Update(block.asToolCall())
+	}
+	return from
+}
 
 func (s *Session) Stream(onPartial func(*Thread)) *Thread {
 	// Perform one-off initialization
+	s.Thread.clientTools = clientWebTools(s.Provider, s.Thread)
+	s.Thread.clientSearches = 0
 	s.Provider.InitSession(s.Thread)
 	s.Thread.CurrentProvider = s.Provider.Name()
 
@@ -87,29 +182,18 @@ func (s *Session) Stream(onPartial func(*Thread)) *Thread {
 		s.Provider.PrepareForUpdates()
 		// Update blocks from last turn.
 		// Will also handle tool calls synchronously.
-		for lastBlock < len(s.Thread.Blocks) {
-			block := s.Thread.Blocks[lastBlock]
-
-			// Skip thinking blocks from different providers
-			if block.Type == InferenceBlockThinking || block.Type == InferenceBlockEncryptedThinking {
-				if block.ProviderID != "" && block.ProviderID != s.Provider.Name() {
-					lastBlock++
-					continue
-				}
-			}
-
-			switch block.Type {
-			case InferenceBlockToolCall:
-				// Only execute tool if it doesn't already have a result (for restored sessions)
-				if block.ToolResult == nil {
-					res := s.Thread.HandleToolFunction(block.ToolCall.Name, block.ToolCall.Arguments)
-					s.Thread.ToolResult(block.ToolCall, res)
-				}
-			}
-			s.Provider.Update(block)
-			lastBlock++
+		lastBlock = s.update(lastBlock)
+		if s.Thread.ContextManager != nil && s.Thread.ContextManager.compact(s.Thread) {
+			// Earlier blocks changed, so the request is rebuilt.
+			s.Provider.InitSession(s.Thread)
+			s.Provider.PrepareForUpdates()
+			lastBlock = s.update(0)
 		}
 
+		before := s.Thread.Result
+		s.Thread.stopReason = ""
+		s.firstToken = time.Time{}
+		started := time.Now()
 		req := s.Provider.MakeRequest(s.Thread)
 		resp, err := http.DefaultClient.Do(req)
 		if s.Debug {
@@ -136,10 +220,11 @@ func (s *Session) Stream(onPartial func(*Thread)) *Thread {
 			log.Printf("[Session] Response status: %s", resp.Status)
 		}
 		defer resp.Body.Close()
-		transport := s.Provider.Transport()
-		switch transport {
+		s.Thread.beginResponse()
+		var streamErr error
+		switch s.Provider.Transport() {
 		case TransportSSE:
-			err := readSSE(s.Provider.Name(), resp.Body, func(ev sseEvent) (bool, error) {
+			streamErr = readSSE(s.Provider.Name(), resp.Body, func(ev sseEvent) (bool, error) {
 				if len(ev.data) == 0 {
 					return true, nil
 				}
@@ -149,29 +234,83 @@ func (s *Session) Stream(onPartial func(*Thread)) *Thread {
 				if s.Debug {
 					log.Printf("[Session] SSE Event: %s", string(ev.data))
 				}
-				result := s.Provider.OnChunk(ev.data, s.Thread)
-				if s.Thread.TakeUpdate() {
-					onPartial(s.Thread)
-				}
-				if result.Error != nil {
-					return false, result.Error
+				return s.onChunk(ev.data, onPartial)
+			})
+		case TransportEventStream:
+			streamErr = readEventStream(s.Provider.Name(), resp.Body, func(msg eventStreamMessage) (bool, error) {
+				if s.Debug {
+					log.Printf("[Session] Event Stream %s: %s", msg.eventType(), string(msg.payload))
 				}
-				if result.Done {
-					return false, nil
+				switch msg.messageType() {
+				case "exception", "error":
+					return false, eventStreamException(s.Provider.Name(), msg)
+				case "event":
+					return s.onChunk(msg.payload, onPartial)
 				}
 				return true, nil
 			})
+		case TransportNDJSON:
+			streamErr = readNDJSON(s.Provider.Name(), resp.Body, func(line []byte) (bool, error) {
+				if s.Debug {
+					log.Printf("[Session] NDJSON Line: %s", string(line))
+				}
+				return s.onChunk(line, onPartial)
+			})
+		case TransportJSON:
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				streamErr = &AIError{
+					Category: AIErrorCategoryStreamingError,
+					Provider: s.Provider.Name(),
+					Message:  err.Error(),
+				}
+				break
+			}
 			if s.Debug {
-				dbg, _ := json.MarshalIndent(s.Thread, "", "  ")
-				log.Printf("[Session] %s", string(dbg))
+				log.Printf("[Session] Response Body: %s", string(body))
 			}
-			if err != nil {
-				s.Thread.SetError(err)
-				return s.Thread
-			} else if s.Thread.IncompleteToolCalls() == 0 {
-				s.Thread.Success = true
-				return s.Thread
+			observer, ok := s.Provider.(responseObserver)
+			if !ok {
+				streamErr = ConfigurationError(s.Provider.Name(), "adapter returned TransportJSON but does not implement OnResponse")
+				break
+			}
+			result := observer.OnResponse(body, s.Thread)
+			if s.Thread.TakeUpdate() {
+				s.firstToken = time.Now()
+				onPartial(s.Thread)
 			}
+			if result.Error != nil {
+				streamErr = result.Error
+			}
+		}
+		s.Thread.endResponse()
+		turn := TurnUsage{
+			ThreadUsage: s.Thread.Result.since(before),
+			Model:       s.Thread.Model,
+			Provider:    s.Provider.Name(),
+			StartedAt:   started,
+			Latency:     time.Since(started),
+			StopReason:  s.Thread.stopReason,
+		}
+		if !s.firstToken.IsZero() {
+			turn.TimeToFirstToken = s.firstToken.Sub(started)
+		}
+		s.Thread.Turns = append(s.Thread.Turns, turn)
+		if s.Debug {
+			dbg, _ := json.MarshalIndent(s.Thread, "", "  ")
+			log.Printf("[Session] %s", string(dbg))
+		}
+		if streamErr != nil {
+			s.Thread.SetError(streamErr)
+			return s.Thread
+		}
+		if err := s.autosave(); err != nil {
+			s.Thread.SetError(err)
+			return s.Thread
+		}
+		if s.Thread.IncompleteToolCalls() == 0 {
+			s.Thread.Success = true
+			return s.Thread
 		}
 	}
 }
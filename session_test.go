@@ -0,0 +1,198 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// runTransportFixture streams a canned response from a local server and
+// returns the resulting thread.
+func runTransportFixture(t *testing.T, config ProviderConfig, contentType string, body string) *Thread {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		io.WriteString(w, body)
+	}))
+	defer server.Close()
+
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "test-model"
+	session.Thread.Clock = func() time.Time { return time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC) }
+	session.Thread.Input("Hello")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("request failed: %s", result.Error)
+	}
+	return result
+}
+
+func assertSameThread(t *testing.T, streamed *Thread, buffered *Thread) {
+	t.Helper()
+	streamedBlocks, _ := json.Marshal(streamed.Blocks)
+	bufferedBlocks, _ := json.Marshal(buffered.Blocks)
+	if string(streamedBlocks) != string(bufferedBlocks) {
+		t.Errorf("blocks differ:\nstream %s\njson   %s", streamedBlocks, bufferedBlocks)
+	}
+	if streamed.Result != buffered.Result {
+		t.Errorf("usage differs:\nstream %+v\njson   %+v", streamed.Result, buffered.Result)
+	}
+	if streamed.ThreadId != buffered.ThreadId {
+		t.Errorf("thread id differs: %q vs %q", streamed.ThreadId, buffered.ThreadId)
+	}
+}
+
+func sseBody(events ...string) string {
+	body := ""
+	for _, ev := range events {
+		body += "data: " + ev + "\n\n"
+	}
+	return body
+}
+
+func TestUnit_Transport_MessagesJSONMatchesStream(t *testing.T) {
+	stream := sseBody(
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":10,"output_tokens":0}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Let me think."}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"Hi "}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"there"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":7}}`,
+		`{"type":"message_stop"}`,
+	)
+	full := `{"id":"msg_1","type":"message","role":"assistant","content":[
+		{"type":"thinking","thinking":"Let me think.","signature":"sig"},
+		{"type":"text","text":"Hi there"}],
+		"stop_reason":"end_turn","usage":{"input_tokens":10,"output_tokens":7}}`
+
+	config := AnthropicProvider("key")
+	streamed := runTransportFixture(t, config, "text/event-stream", stream)
+	config.Transport = TransportJSON
+	buffered := runTransportFixture(t, config, "application/json", full)
+	assertSameThread(t, streamed, buffered)
+	if streamed.Blocks[0].Response != 0 || streamed.Blocks[1].Response != 1 || streamed.Blocks[2].Response != 1 {
+		t.Errorf("expected the reply to be numbered as the first response")
+	}
+}
+
+func TestUnit_Transport_CompletionsJSONMatchesStream(t *testing.T) {
+	stream := sseBody(
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","reasoning":"Thinking"}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hello "}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"world"}}]}`,
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"chatcmpl-1","choices":[],"usage":{"prompt_tokens":12,"completion_tokens":5,"prompt_tokens_details":{"cached_tokens":2}}}`,
+		`[DONE]`,
+	)
+	full := `{"id":"chatcmpl-1","choices":[{"index":0,"message":{"role":"assistant","content":"Hello world","reasoning":"Thinking"},"finish_reason":"stop"}],
+		"usage":{"prompt_tokens":12,"completion_tokens":5,"prompt_tokens_details":{"cached_tokens":2}}}`
+
+	config := GroqProvider("key")
+	streamed := runTransportFixture(t, config, "text/event-stream", stream)
+	config.Transport = TransportJSON
+	buffered := runTransportFixture(t, config, "application/json", full)
+	assertSameThread(t, streamed, buffered)
+}
+
+func TestUnit_Transport_ResponsesJSONMatchesStream(t *testing.T) {
+	stream := sseBody(
+		`{"type":"response.reasoning_summary_text.delta","item_id":"rs_1","delta":"Plan"}`,
+		`{"type":"response.reasoning_summary_text.done","item_id":"rs_1"}`,
+		`{"type":"response.output_item.done","item":{"type":"reasoning","id":"rs_1"}}`,
+		`{"type":"response.output_text.delta","item_id":"msg_1","delta":"Answer"}`,
+		`{"type":"response.output_text.done","item_id":"msg_1"}`,
+		`{"type":"response.output_item.done","item":{"type":"message","id":"msg_1"}}`,
+		`{"type":"response.completed","response":{"id":"resp_1","usage":{"input_tokens":9,"output_tokens":3,"input_tokens_details":{"cached_tokens":1}}}}`,
+	)
+	full := `{"id":"resp_1","output":[
+		{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"Plan"}]},
+		{"type":"message","id":"msg_1","role":"assistant","content":[{"type":"output_text","text":"Answer"}]}],
+		"usage":{"input_tokens":9,"output_tokens":3,"input_tokens_details":{"cached_tokens":1}}}`
+
+	config := OpenAIProvider("key")
+	streamed := runTransportFixture(t, config, "text/event-stream", stream)
+	config.Transport = TransportJSON
+	buffered := runTransportFixture(t, config, "application/json", full)
+	assertSameThread(t, streamed, buffered)
+}
+
+func TestUnit_Transport_AIStudioJSONMatchesStream(t *testing.T) {
+	stream := sseBody(
+		`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"Hel"}]}}]}`,
+		`{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"lo"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2}}`,
+	)
+	full := `{"responseId":"r1","candidates":[{"content":{"role":"model","parts":[{"text":"Hello"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":4,"candidatesTokenCount":2}}`
+
+	config := GoogleProvider("key")
+	streamed := runTransportFixture(t, config, "text/event-stream", stream)
+	config.Transport = TransportJSON
+	buffered := runTransportFixture(t, config, "application/json", full)
+	assertSameThread(t, streamed, buffered)
+}
+
+func TestUnit_Transport_OllamaJSONMatchesStream(t *testing.T) {
+	stream := `{"message":{"role":"assistant","content":"","thinking":"Hmm"},"done":false}
+{"message":{"role":"assistant","content":"Hi"},"done":false}
+{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":3,"eval_count":2}
+`
+	full := `{"message":{"role":"assistant","content":"Hi","thinking":"Hmm"},"done":true,"prompt_eval_count":3,"eval_count":2}`
+
+	config := OllamaProvider("")
+	streamed := runTransportFixture(t, config, "application/x-ndjson", stream)
+	config.Transport = TransportJSON
+	buffered := runTransportFixture(t, config, "application/json", full)
+	assertSameThread(t, streamed, buffered)
+}
+
+func TestUnit_Transport_JSONModeDisablesStreaming(t *testing.T) {
+	config := GroqProvider("key")
+	config.Transport = TransportJSON
+	p := &CompletionsAPIRequest{Config: &config}
+	p.InitSession(&Thread{Model: "m"})
+
+	if p.Transport() != TransportJSON {
+		t.Errorf("expected JSON transport, got %q", p.Transport())
+	}
+	req := p.MakeRequest(&Thread{Model: "m"})
+	body, _ := io.ReadAll(req.Body)
+	var decoded map[string]any
+	json.Unmarshal(body, &decoded)
+	if _, ok := decoded["stream"]; ok {
+		t.Errorf("did not expect stream field, got %s", body)
+	}
+	if _, ok := decoded["stream_options"]; ok {
+		t.Errorf("did not expect stream_options, got %s", body)
+	}
+}
+
+// streamOnlyRequest hides the OnResponse method of the adapter it wraps.
+type streamOnlyRequest struct{ APIRequest }
+
+func TestUnit_Transport_JSONNeedsOnResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	config := GroqProvider("key")
+	config.BaseURL = server.URL
+	config.Transport = TransportJSON
+	session := config.Session()
+	session.Provider = streamOnlyRequest{session.Provider}
+	session.Thread.Model = "test-model"
+	session.Thread.Input("Hello")
+	result := session.Stream(func(*Thread) {})
+	if result.Success || !strings.Contains(result.Error, "does not implement OnResponse") {
+		t.Fatalf("expected a configuration error, got %q", result.Error)
+	}
+}
@@ -0,0 +1,270 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// SnapshotVersion is the schema version written by Thread.Snapshot.
+// Snapshots without a version are version 1: just blocks, archive and
+// turns, with block IDs optional.
+const SnapshotVersion = 2
+
+// SnapshotMigration upgrades a snapshot document, decoded from JSON into
+// generic maps and slices, by one version.
+type SnapshotMigration func(doc map[string]any) error
+
+var (
+	snapshotMigrationsMu sync.RWMutex
+	snapshotMigrations   = map[int]SnapshotMigration{
+		1: migrateSnapshotV1,
+	}
+)
+
+// RegisterSnapshotMigration sets the migration from version from to
+// from+1, replacing any registered before. Applications that extend the
+// format register their own migrations alongside the built-in ones.
+func RegisterSnapshotMigration(from int, migrate SnapshotMigration) {
+	snapshotMigrationsMu.Lock()
+	defer snapshotMigrationsMu.Unlock()
+	snapshotMigrations[from] = migrate
+}
+
+// migrateSnapshotV1 gives every block an ID, so later versions can refer to
+// blocks by ID.
+func migrateSnapshotV1(doc map[string]any) error {
+	for _, key := range []string{"blocks", "archive"} {
+		blocks, _ := doc[key].([]any)
+		for i, item := range blocks {
+			block, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			if id, _ := block["id"].(string); id == "" {
+				typ, _ := block["type"].(string)
+				block["id"] = fmt.Sprintf("%s-%d", typ, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// migrateSnapshotDocument runs the migrations from the document's version
+// to SnapshotVersion.
+func migrateSnapshotDocument(doc map[string]any) error {
+	version := 1
+	if v, ok := doc["version"].(float64); ok && v != 0 {
+		version = int(v)
+	}
+	if version > SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is newer than %d", version, SnapshotVersion)
+	}
+	snapshotMigrationsMu.RLock()
+	defer snapshotMigrationsMu.RUnlock()
+	for ; version < SnapshotVersion; version++ {
+		migrate, ok := snapshotMigrations[version]
+		if !ok {
+			return fmt.Errorf("no snapshot migration from version %d", version)
+		}
+		if err := migrate(doc); err != nil {
+			return fmt.Errorf("snapshot migration from version %d: %w", version, err)
+		}
+	}
+	doc["version"] = SnapshotVersion
+	return nil
+}
+
+// SnapshotDecoder decodes snapshots of any version into the current
+// Snapshot struct.
+type SnapshotDecoder struct {
+	// Strict rejects unknown JSON fields and snapshots that fail Validate.
+	Strict bool
+}
+
+// DecodeSnapshot decodes a JSON snapshot of any version.
+func DecodeSnapshot(data []byte) (*Snapshot, error) {
+	return SnapshotDecoder{}.Decode(data)
+}
+
+// Decode decodes a JSON snapshot, migrating it to SnapshotVersion.
+func (d SnapshotDecoder) Decode(data []byte) (*Snapshot, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return d.decodeDocument(doc)
+}
+
+// DecodeXML decodes an XML snapshot, migrating it to SnapshotVersion.
+func (d SnapshotDecoder) DecodeXML(data []byte) (*Snapshot, error) {
+	var snapshot Snapshot
+	if err := xml.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Version < SnapshotVersion {
+		// Migrations work on the JSON form.
+		upgraded, err := upgradeSnapshot(&snapshot)
+		if err != nil {
+			return nil, err
+		}
+		snapshot = *upgraded
+	} else if snapshot.Version > SnapshotVersion {
+		return nil, fmt.Errorf("snapshot version %d is newer than %d", snapshot.Version, SnapshotVersion)
+	}
+	if d.Strict {
+		if err := snapshot.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshot, nil
+}
+
+func (d SnapshotDecoder) decodeDocument(doc map[string]any) (*Snapshot, error) {
+	if err := migrateSnapshotDocument(doc); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if d.Strict {
+		dec.DisallowUnknownFields()
+	}
+	var snapshot Snapshot
+	if err := dec.Decode(&snapshot); err != nil {
+		return nil, err
+	}
+	if d.Strict {
+		if err := snapshot.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return &snapshot, nil
+}
+
+// upgradeSnapshot migrates a snapshot that was decoded straight into the
+// Snapshot struct, such as one embedded in a larger document.
+func upgradeSnapshot(snapshot *Snapshot) (*Snapshot, error) {
+	if snapshot.Version == SnapshotVersion {
+		return snapshot, nil
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return SnapshotDecoder{}.decodeDocument(doc)
+}
+
+// snapshotTools returns the tool definitions sorted by name.
+func snapshotTools(tools map[string]ToolDefinition) []SnapshotTool {
+	if len(tools) == 0 {
+		return nil
+	}
+	list := make([]SnapshotTool, 0, len(tools))
+	for name, tool := range tools {
+		list = append(list, SnapshotTool{Name: name, ToolDefinition: tool})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Validate checks that the snapshot can be sent to a provider: known block
+// types, tool results that belong to a tool call, tool calls answered
+// before the conversation moves on, and media blocks with their data.
+// All problems are reported, each with the index of its block.
+func (s *Snapshot) Validate() error {
+	var errs []error
+	if s.Version > SnapshotVersion {
+		errs = append(errs, fmt.Errorf("snapshot version %d is newer than %d", s.Version, SnapshotVersion))
+	}
+	for i, tool := range s.Tools {
+		if tool.Name == "" {
+			errs = append(errs, fmt.Errorf("tool %d: missing name", i))
+		}
+	}
+	errs = append(errs, validateBlocks("archive", s.Archive)...)
+	errs = append(errs, validateBlocks("block", s.Blocks)...)
+	return errors.Join(errs...)
+}
+
+func validateBlocks(kind string, blocks []*ThreadBlock) []error {
+	var errs []error
+	fail := func(i int, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s %d: %s", kind, i, fmt.Sprintf(format, args...)))
+	}
+	toolCallIDs := map[string]int{}
+	// pending holds the tool calls still waiting for their results. Calls at
+	// the end of the thread may be waiting on the caller; any other block
+	// means the conversation moved on without them.
+	var pending []int
+	for i, b := range blocks {
+		if b == nil {
+			fail(i, "nil block")
+			continue
+		}
+		if b.Type != InferenceBlockToolCall {
+			for _, p := range pending {
+				fail(p, "tool call %q has no result", blocks[p].ToolCall.ID)
+			}
+			pending = nil
+		}
+		switch b.Type {
+		case InferenceBlockSystem, InferenceBlockInput, InferenceBlockThinking, InferenceBlockEncryptedThinking,
+			InferenceBlockText, InferenceBlockWebSearch, InferenceBlockViewWebpage, InferenceBlockServerTool:
+		case InferenceBlockToolCall:
+			if b.ToolCall == nil {
+				fail(i, "tool call block without a tool call")
+				break
+			}
+			if b.ToolCall.ID == "" || b.ToolCall.Name == "" {
+				fail(i, "tool call without an ID or name")
+			}
+			if first, ok := toolCallIDs[b.ToolCall.ID]; ok && b.ToolCall.ID != "" {
+				fail(i, "tool call ID %q repeats block %d", b.ToolCall.ID, first)
+			}
+			toolCallIDs[b.ToolCall.ID] = i
+			if b.ToolResult == nil {
+				pending = append(pending, i)
+			}
+		case InferenceBlockInputImage, InferenceBlockOutputImage:
+			if b.Image == nil || b.Image.Base64 == "" {
+				fail(i, "image block without image data")
+			} else if _, err := base64.StdEncoding.DecodeString(b.Image.Base64); err != nil {
+				fail(i, "invalid image data: %v", err)
+			}
+		case InferenceBlockInputDocument:
+			if b.Document == nil || b.Document.Base64 == "" {
+				fail(i, "document block without document data")
+			}
+		case InferenceBlockInputAudio, InferenceBlockAudio:
+			// Generated audio may be kept as a provider ID or a transcript.
+			if b.Audio == nil || (b.Audio.Base64 == "" && b.Audio.ID == "" && b.Audio.Transcript == "") {
+				fail(i, "audio block without audio data")
+			}
+		case "":
+			fail(i, "missing block type")
+		default:
+			fail(i, "unknown block type %q", b.Type)
+		}
+		if b.ToolResult != nil {
+			switch {
+			case b.ToolCall == nil:
+				fail(i, "tool result for %q without a tool call", b.ToolResult.ToolCallID)
+			case b.ToolResult.ToolCallID != "" && b.ToolResult.ToolCallID != b.ToolCall.ID:
+				fail(i, "tool result for %q on tool call %q", b.ToolResult.ToolCallID, b.ToolCall.ID)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,148 @@
+package aikit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSnapshot_LegacyMigration(t *testing.T) {
+	legacy := `{"blocks":[{"type":"input","text":"Hi","complete":true},{"id":"msg_1","type":"text","text":"Hello","complete":true}]}`
+	snapshot, err := DecodeSnapshot([]byte(legacy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Version != SnapshotVersion {
+		t.Errorf("expected version %d, got %d", SnapshotVersion, snapshot.Version)
+	}
+	if snapshot.Blocks[0].ID != "input-1" || snapshot.Blocks[1].ID != "msg_1" {
+		t.Errorf("expected missing IDs to be filled, got %q and %q", snapshot.Blocks[0].ID, snapshot.Blocks[1].ID)
+	}
+
+	data, _ := xml.Marshal(&Snapshot{Blocks: []*ThreadBlock{{Type: InferenceBlockInput, Text: "Hi", Complete: true}}})
+	snapshot, err = SnapshotDecoder{}.DecodeXML(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Version != SnapshotVersion || snapshot.Blocks[0].ID != "input-1" {
+		t.Errorf("expected the XML snapshot to be migrated, got %+v", snapshot)
+	}
+
+	if _, err := DecodeSnapshot([]byte(`{"version":99,"blocks":[]}`)); err == nil {
+		t.Errorf("expected an error for a newer version")
+	}
+}
+
+func TestSnapshot_Envelope(t *testing.T) {
+	thread := NewProviderState()
+	thread.Model = "gpt-5"
+	thread.CurrentProvider = "responses.openai"
+	thread.Tools = map[string]ToolDefinition{
+		"weather": {Description: "Get the weather", Parameters: &JsonSchema{
+			Type:       "object",
+			Properties: &map[string]*JsonSchema{"city": {Type: "string"}},
+			Required:   []string{"city"},
+		}},
+		"clock": {Description: "Get the time"},
+	}
+	thread.StructuredOutputSchema = exampleStructuredSchema()
+	thread.Input("Hello")
+
+	check := func(t *testing.T, snapshot *Snapshot) {
+		t.Helper()
+		if snapshot.Version != SnapshotVersion || snapshot.CreatedAt.IsZero() {
+			t.Errorf("missing version or creation time: %d %v", snapshot.Version, snapshot.CreatedAt)
+		}
+		if snapshot.Model != "gpt-5" || snapshot.Provider != "responses.openai" {
+			t.Errorf("unexpected model %q and provider %q", snapshot.Model, snapshot.Provider)
+		}
+		restored := NewProviderState()
+		restored.Restore(snapshot)
+		if restored.Model != "gpt-5" || len(restored.Tools) != 2 || restored.StructuredOutputSchema == nil {
+			t.Fatalf("configuration not restored: %+v", restored)
+		}
+		weather := restored.Tools["weather"]
+		if weather.Parameters == nil || (*weather.Parameters.Properties)["city"].Type != "string" || weather.Parameters.Required[0] != "city" {
+			t.Errorf("tool parameters not preserved: %+v", weather.Parameters)
+		}
+		if restored.StructuredOutputSchema.Type != thread.StructuredOutputSchema.Type {
+			t.Errorf("schema not preserved: %+v", restored.StructuredOutputSchema)
+		}
+	}
+
+	snapshot := thread.Snapshot()
+	if snapshot.Tools[0].Name != "clock" || snapshot.Tools[1].Name != "weather" {
+		t.Errorf("expected tools sorted by name, got %+v", snapshot.Tools)
+	}
+
+	t.Run("json", func(t *testing.T) {
+		data, _ := json.Marshal(snapshot)
+		decoded, err := SnapshotDecoder{Strict: true}.Decode(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, decoded)
+	})
+	t.Run("xml", func(t *testing.T) {
+		data, err := xml.Marshal(snapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := SnapshotDecoder{Strict: true}.DecodeXML(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		check(t, decoded)
+	})
+	t.Run("thread settings win", func(t *testing.T) {
+		restored := NewProviderState()
+		restored.Model = "gpt-5-mini"
+		restored.Restore(snapshot)
+		if restored.Model != "gpt-5-mini" {
+			t.Errorf("expected the thread's model to be kept, got %q", restored.Model)
+		}
+	})
+}
+
+func TestSnapshot_StrictValidation(t *testing.T) {
+	snapshot := &Snapshot{
+		Version: SnapshotVersion,
+		Blocks: []*ThreadBlock{
+			{Type: InferenceBlockInput, Text: "Hi"},
+			{Type: InferenceBlockToolCall, ToolCall: &ThreadToolCall{ID: "call_1", Name: "weather"}},
+			{Type: InferenceBlockText, Text: "Done", ToolResult: &ThreadToolResult{ToolCallID: "call_2"}},
+			{Type: InferenceBlockInputImage},
+			{Type: "video"},
+			{Type: InferenceBlockToolCall, ToolCall: &ThreadToolCall{ID: "call_3", Name: "weather"}},
+		},
+	}
+	err := snapshot.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		`block 1: tool call "call_1" has no result`,
+		`block 2: tool result for "call_2" without a tool call`,
+		`block 3: image block without image data`,
+		`block 4: unknown block type "video"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("missing %q in:\n%v", want, err)
+		}
+	}
+	if strings.Contains(err.Error(), "call_3") {
+		t.Errorf("a trailing tool call may still be waiting for its result:\n%v", err)
+	}
+
+	data, _ := json.Marshal(snapshot)
+	if _, err := DecodeSnapshot(data); err != nil {
+		t.Errorf("expected the lenient decoder to accept it, got %v", err)
+	}
+	if _, err := (SnapshotDecoder{Strict: true}).Decode(data); err == nil {
+		t.Errorf("expected the strict decoder to reject it")
+	}
+	if _, err := (SnapshotDecoder{Strict: true}).Decode([]byte(`{"version":2,"blocks":[],"extra":1}`)); err == nil {
+		t.Errorf("expected the strict decoder to reject unknown fields")
+	}
+}
@@ -0,0 +1,173 @@
+package aikit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+var (
+	// ErrThreadNotFound is returned by stores for unknown thread IDs.
+	ErrThreadNotFound = errors.New("thread not found")
+	// ErrVersionConflict is returned when a thread was saved by someone else
+	// since it was loaded. Load it again and retry.
+	ErrVersionConflict = errors.New("thread version conflict")
+)
+
+// ThreadStore persists thread snapshots by ID. Every write takes the
+// version the caller last saw (0 for a new thread) and fails with
+// ErrVersionConflict if the stored thread has moved on, so concurrent
+// writers don't overwrite each other.
+type ThreadStore interface {
+	// Save replaces the stored thread and returns its new version.
+	Save(id string, snapshot *Snapshot, version int64) (int64, error)
+	// Load returns the stored thread and its version.
+	Load(id string) (*Snapshot, int64, error)
+	// List returns the IDs of the stored threads, sorted.
+	List() ([]string, error)
+	// Delete removes a thread.
+	Delete(id string) error
+	// AppendBlocks adds blocks to the end of the stored thread without
+	// rewriting it and returns its new version.
+	AppendBlocks(id string, blocks []*ThreadBlock, version int64) (int64, error)
+}
+
+// versionConflict wraps ErrVersionConflict with the versions involved.
+func versionConflict(id string, expected, actual int64) error {
+	return fmt.Errorf("%w: %q is at version %d, not %d", ErrVersionConflict, id, actual, expected)
+}
+
+func threadNotFound(id string) error {
+	return fmt.Errorf("%w: %q", ErrThreadNotFound, id)
+}
+
+// MemoryStore is a ThreadStore kept in memory, for tests and short-lived
+// processes.
+type MemoryStore struct {
+	mu      sync.Mutex
+	threads map[string]*memoryThread
+}
+
+type memoryThread struct {
+	snapshot *Snapshot
+	version  int64
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{threads: map[string]*memoryThread{}}
+}
+
+func (m *MemoryStore) version(id string) int64 {
+	if t, ok := m.threads[id]; ok {
+		return t.version
+	}
+	return 0
+}
+
+func (m *MemoryStore) Save(id string, snapshot *Snapshot, version int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current := m.version(id); current != version {
+		return 0, versionConflict(id, version, current)
+	}
+	if m.threads == nil {
+		m.threads = map[string]*memoryThread{}
+	}
+	m.threads[id] = &memoryThread{snapshot: cloneSnapshot(snapshot), version: version + 1}
+	return version + 1, nil
+}
+
+func (m *MemoryStore) Load(id string) (*Snapshot, int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.threads[id]
+	if !ok {
+		return nil, 0, threadNotFound(id)
+	}
+	return cloneSnapshot(t.snapshot), t.version, nil
+}
+
+func (m *MemoryStore) List() ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.threads))
+	for id := range m.threads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.threads[id]; !ok {
+		return threadNotFound(id)
+	}
+	delete(m.threads, id)
+	return nil
+}
+
+func (m *MemoryStore) AppendBlocks(id string, blocks []*ThreadBlock, version int64) (int64, error) {
+	if len(blocks) == 0 {
+		return version, nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if current := m.version(id); current != version {
+		return 0, versionConflict(id, version, current)
+	}
+	if m.threads == nil {
+		m.threads = map[string]*memoryThread{}
+	}
+	t, ok := m.threads[id]
+	if !ok {
+		t = &memoryThread{snapshot: &Snapshot{}}
+		m.threads[id] = t
+	}
+	t.snapshot.Blocks = append(t.snapshot.Blocks, cloneBlocks(blocks)...)
+	t.version++
+	return t.version, nil
+}
+
+func cloneSnapshot(snapshot *Snapshot) *Snapshot {
+	c := *snapshot
+	c.Tools = slices.Clone(snapshot.Tools)
+	c.Blocks = cloneBlocks(snapshot.Blocks)
+	c.Archive = nil
+	if len(snapshot.Archive) > 0 {
+		c.Archive = cloneBlocks(snapshot.Archive)
+	}
+	c.Turns = slices.Clone(snapshot.Turns)
+	return &c
+}
+
+// Resume loads the thread stored under id into the session's thread and
+// keeps saving it there after every completed request.
+func (s *Session) Resume(store ThreadStore, id string) error {
+	snapshot, version, err := store.Load(id)
+	if err != nil {
+		return err
+	}
+	s.Thread.Restore(snapshot)
+	s.Store = store
+	s.StoreID = id
+	s.storeVersion = version
+	return nil
+}
+
+// autosave saves the thread to Store, if set.
+func (s *Session) autosave() error {
+	if s.Store == nil || s.StoreID == "" {
+		return nil
+	}
+	version, err := s.Store.Save(s.StoreID, s.Thread.Snapshot(), s.storeVersion)
+	if err != nil {
+		return err
+	}
+	s.storeVersion = version
+	return nil
+}
@@ -0,0 +1,282 @@
+package aikit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileStore is a ThreadStore in a directory. Each thread is saved as
+// <id>.json, and AppendBlocks writes to an append-only <id>.blocks.jsonl
+// log next to it, one block per line. Loading replays the log entries newer
+// than the JSON file, and Save removes the log once the JSON file holds its
+// blocks, so the log only grows between saves. A last line left unfinished
+// by a failed append is ignored and cut off by the next one. Writes are
+// serialized within the process only; don't share a directory between
+// processes without a lock of your own.
+type FileStore struct {
+	Dir string
+
+	mu sync.Mutex
+}
+
+// NewFileStore returns a FileStore in dir, creating the directory.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{Dir: dir}, nil
+}
+
+// fileThread is the content of <id>.json.
+type fileThread struct {
+	ID        string    `json:"id"`
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Snapshot  *Snapshot `json:"snapshot"`
+
+	// logSize is the length of the log up to its last complete line.
+	logSize int64
+}
+
+// fileBlock is one line of <id>.blocks.jsonl.
+type fileBlock struct {
+	Version int64        `json:"version"`
+	Block   *ThreadBlock `json:"block"`
+}
+
+func (f *FileStore) path(id string, ext string) (string, error) {
+	if id == "" || id == "." || id == ".." || strings.ContainsAny(id, `/\`) {
+		return "", fmt.Errorf("invalid thread id %q", id)
+	}
+	return filepath.Join(f.Dir, id+ext), nil
+}
+
+// read returns the stored thread with its log applied, or nil if there is
+// none.
+func (f *FileStore) read(id string) (*fileThread, error) {
+	jsonPath, err := f.path(id, ".json")
+	if err != nil {
+		return nil, err
+	}
+	var thread *fileThread
+	data, err := os.ReadFile(jsonPath)
+	switch {
+	case err == nil:
+		thread = &fileThread{}
+		if err := json.Unmarshal(data, thread); err != nil {
+			return nil, fmt.Errorf("%s: %w", jsonPath, err)
+		}
+		if thread.Snapshot == nil {
+			thread.Snapshot = &Snapshot{}
+		} else if thread.Snapshot, err = upgradeSnapshot(thread.Snapshot); err != nil {
+			return nil, fmt.Errorf("%s: %w", jsonPath, err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	}
+
+	logPath, _ := f.path(id, ".blocks.jsonl")
+	log, err := os.Open(logPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return thread, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer log.Close()
+	if thread == nil {
+		thread = &fileThread{ID: id, Snapshot: &Snapshot{}}
+	}
+	saved := thread.Version
+	reader := bufio.NewReader(log)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// Empty, or a line whose append didn't finish.
+			return thread, nil
+		} else if err != nil {
+			return nil, err
+		}
+		var entry fileBlock
+		if text := bytes.TrimSpace(data); len(text) > 0 {
+			if err := json.Unmarshal(text, &entry); err != nil {
+				if _, err := reader.Peek(1); err == io.EOF {
+					// A torn write can also end in a newline.
+					return thread, nil
+				}
+				return nil, fmt.Errorf("%s line %d: %w", logPath, line, err)
+			}
+		}
+		thread.logSize += int64(len(data))
+		if entry.Block == nil || entry.Version <= saved {
+			// Blank, or already part of the JSON file.
+			continue
+		}
+		thread.Snapshot.Blocks = append(thread.Snapshot.Blocks, entry.Block)
+		thread.Version = entry.Version
+	}
+}
+
+func (f *FileStore) Save(id string, snapshot *Snapshot, version int64) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current, err := f.read(id)
+	if err != nil {
+		return 0, err
+	}
+	if actual := storedVersion(current); actual != version {
+		return 0, versionConflict(id, version, actual)
+	}
+	data, err := json.Marshal(fileThread{
+		ID:        id,
+		Version:   version + 1,
+		UpdatedAt: time.Now().UTC(),
+		Snapshot:  snapshot,
+	})
+	if err != nil {
+		return 0, err
+	}
+	path, _ := f.path(id, ".json")
+	// Write to a temporary file first so readers never see half a thread.
+	tmp, err := os.CreateTemp(f.Dir, id+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return 0, err
+	}
+	// The snapshot includes every logged block. A log left behind by a
+	// failed remove is harmless: its entries are older than the JSON file
+	// and are skipped when loading.
+	logPath, _ := f.path(id, ".blocks.jsonl")
+	os.Remove(logPath)
+	return version + 1, nil
+}
+
+func (f *FileStore) Load(id string) (*Snapshot, int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	thread, err := f.read(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	if thread == nil {
+		return nil, 0, threadNotFound(id)
+	}
+	return thread.Snapshot, thread.Version, nil
+}
+
+func (f *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(f.Dir)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, ".blocks.jsonl"):
+			seen[strings.TrimSuffix(name, ".blocks.jsonl")] = true
+		case strings.HasSuffix(name, ".json"):
+			seen[strings.TrimSuffix(name, ".json")] = true
+		}
+	}
+	ids := make([]string, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (f *FileStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	found := false
+	for _, ext := range []string{".json", ".blocks.jsonl"} {
+		path, err := f.path(id, ext)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		if err == nil {
+			found = true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	if !found {
+		return threadNotFound(id)
+	}
+	return nil
+}
+
+func (f *FileStore) AppendBlocks(id string, blocks []*ThreadBlock, version int64) (int64, error) {
+	if len(blocks) == 0 {
+		return version, nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	current, err := f.read(id)
+	if err != nil {
+		return 0, err
+	}
+	if actual := storedVersion(current); actual != version {
+		return 0, versionConflict(id, version, actual)
+	}
+	path, _ := f.path(id, ".blocks.jsonl")
+	log, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer log.Close()
+	// Drop what a failed append left after the last complete line.
+	var size int64
+	if current != nil {
+		size = current.logSize
+	}
+	if err := log.Truncate(size); err != nil {
+		return 0, err
+	}
+	if _, err := log.Seek(size, io.SeekStart); err != nil {
+		return 0, err
+	}
+	w := bufio.NewWriter(log)
+	enc := json.NewEncoder(w)
+	for _, b := range blocks {
+		if err := enc.Encode(fileBlock{Version: version + 1, Block: b}); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	return version + 1, log.Sync()
+}
+
+func storedVersion(thread *fileThread) int64 {
+	if thread == nil {
+		return 0
+	}
+	return thread.Version
+}
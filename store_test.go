@@ -0,0 +1,195 @@
+package aikit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func testStores(t *testing.T) map[string]ThreadStore {
+	files, err := NewFileStore(filepath.Join(t.TempDir(), "threads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]ThreadStore{"memory": NewMemoryStore(), "file": files}
+}
+
+func TestUnit_Store_VersionsAndAppend(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			thread := forkTestThread()
+			version, err := store.Save("t1", thread.Snapshot(), 0)
+			if err != nil || version != 1 {
+				t.Fatalf("save: %d %v", version, err)
+			}
+			if _, err := store.Save("t1", thread.Snapshot(), 0); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("expected a conflict for a stale version, got %v", err)
+			}
+
+			thread.Input("And tomorrow?")
+			version, err = store.AppendBlocks("t1", thread.Blocks[4:], version)
+			if err != nil || version != 2 {
+				t.Fatalf("append: %d %v", version, err)
+			}
+			if _, err := store.AppendBlocks("t1", thread.Blocks[4:], 1); !errors.Is(err, ErrVersionConflict) {
+				t.Errorf("expected a conflict for a stale append, got %v", err)
+			}
+
+			snapshot, loaded, err := store.Load("t1")
+			if err != nil || loaded != 2 {
+				t.Fatalf("load: %d %v", loaded, err)
+			}
+			if len(snapshot.Blocks) != 5 || snapshot.Blocks[4].Text != "And tomorrow?" || snapshot.Blocks[2].ToolResult.Output != "sunny" {
+				t.Errorf("unexpected blocks %+v", snapshot.Blocks)
+			}
+
+			// A full save supersedes the appended blocks.
+			thread.TruncateAt(thread.Blocks[1].ID)
+			if version, err = store.Save("t1", thread.Snapshot(), 2); err != nil || version != 3 {
+				t.Fatalf("save: %d %v", version, err)
+			}
+			if snapshot, _, _ := store.Load("t1"); len(snapshot.Blocks) != 2 {
+				t.Errorf("expected the saved blocks only, got %d", len(snapshot.Blocks))
+			}
+
+			store.Save("t2", thread.Snapshot(), 0)
+			if ids, _ := store.List(); len(ids) != 2 || ids[0] != "t1" || ids[1] != "t2" {
+				t.Errorf("unexpected ids %v", ids)
+			}
+			if err := store.Delete("t1"); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := store.Load("t1"); !errors.Is(err, ErrThreadNotFound) {
+				t.Errorf("expected not found after delete, got %v", err)
+			}
+			if err := store.Delete("t1"); !errors.Is(err, ErrThreadNotFound) {
+				t.Errorf("expected not found, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUnit_Store_FileLayout(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	thread := forkTestThread()
+	version, _ := store.Save("t1", thread.Snapshot(), 0)
+	thread.Input("Next")
+	version, _ = store.AppendBlocks("t1", thread.Blocks[4:], version)
+
+	if _, err := os.Stat(filepath.Join(dir, "t1.json")); err != nil {
+		t.Errorf("expected a JSON file: %v", err)
+	}
+	log, err := os.ReadFile(filepath.Join(dir, "t1.blocks.jsonl"))
+	if err != nil || string(log) == "" || log[len(log)-1] != '\n' {
+		t.Errorf("expected a JSONL log, got %q (%v)", log, err)
+	}
+	if _, err := store.Save("../escape", thread.Snapshot(), 0); err == nil {
+		t.Errorf("expected ids with path separators to be rejected")
+	}
+
+	// Saving folds the log into the JSON file.
+	if version, _ = store.Save("t1", thread.Snapshot(), version); version != 3 {
+		t.Fatalf("unexpected version %d", version)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "t1.blocks.jsonl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the log to be removed, got %v", err)
+	}
+	snapshot, loaded, err := store.Load("t1")
+	if err != nil || loaded != 3 {
+		t.Fatalf("load: %d %v", loaded, err)
+	}
+	if len(snapshot.Blocks) != 5 {
+		t.Errorf("expected the logged block to be kept, got %d blocks", len(snapshot.Blocks))
+	}
+}
+
+func TestUnit_Store_FileTornAppend(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	thread := forkTestThread()
+	version, _ := store.Save("t1", thread.Snapshot(), 0)
+	thread.Input("Next")
+	version, _ = store.AppendBlocks("t1", thread.Blocks[4:], version)
+
+	// An append that stopped halfway through a line.
+	logPath := filepath.Join(dir, "t1.blocks.jsonl")
+	log, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+	log.WriteString(`{"version":3,"block":{"id":"input-`)
+	log.Close()
+
+	snapshot, loaded, err := store.Load("t1")
+	if err != nil || loaded != version || len(snapshot.Blocks) != 5 {
+		t.Fatalf("expected the torn line to be ignored: %d %v", loaded, err)
+	}
+	thread.Input("Again")
+	if version, err = store.AppendBlocks("t1", thread.Blocks[5:], version); err != nil || version != 3 {
+		t.Fatalf("append after a torn line: %d %v", version, err)
+	}
+	data, _ := os.ReadFile(logPath)
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != 2 || !json.Valid([]byte(lines[0])) || !json.Valid([]byte(lines[1])) {
+		t.Errorf("expected the torn line to be cut off:\n%s", data)
+	}
+	if snapshot, _, err := store.Load("t1"); err != nil || len(snapshot.Blocks) != 6 || snapshot.Blocks[5].Text != "Again" {
+		t.Errorf("unexpected load after the append: %v", err)
+	}
+	if _, err := store.Save("t1", thread.Snapshot(), version); err != nil {
+		t.Errorf("save: %v", err)
+	}
+}
+
+func TestUnit_Store_SessionAutosaveAndResume(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		id := fmt.Sprintf("c%d", requests)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, sseBody(
+			`{"id":"`+id+`","choices":[{"index":0,"delta":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`,
+			`{"id":"`+id+`","choices":[],"usage":{"prompt_tokens":10,"completion_tokens":2}}`,
+			`[DONE]`,
+		))
+	}))
+	defer server.Close()
+	config := OpenAICompletionsProvider("key")
+	config.BaseURL = server.URL
+	store := NewMemoryStore()
+
+	session := config.Session()
+	session.Thread.Model = "test-model"
+	session.Store, session.StoreID = store, "chat-1"
+	session.Thread.Input("Hello")
+	if result := session.Stream(func(*Thread) {}); !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+
+	resumed := config.Session()
+	resumed.Thread.Model = "test-model"
+	if err := resumed.Resume(store, "chat-1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed.Thread.Blocks) != 2 || len(resumed.Thread.Turns) != 1 || resumed.Thread.Result.InputTokens != 10 {
+		t.Fatalf("unexpected resumed thread %+v", resumed.Thread)
+	}
+	resumed.Thread.Input("Again")
+	if result := resumed.Stream(func(*Thread) {}); !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if snapshot, version, _ := store.Load("chat-1"); version != 2 || len(snapshot.Blocks) != 4 {
+		t.Errorf("expected the resumed turn saved, got version %d", version)
+	}
+
+	// The first session is now stale.
+	session.Thread.Input("Conflict")
+	if result := session.Stream(func(*Thread) {}); result.Success {
+		t.Errorf("expected the stale session to fail to save")
+	}
+}
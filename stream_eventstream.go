@@ -0,0 +1,202 @@
+package aikit
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// eventStreamMessage is a single decoded frame of the AWS event-stream
+// binary protocol (application/vnd.amazon.eventstream).
+type eventStreamMessage struct {
+	headers map[string]string
+	payload []byte
+}
+
+func (m eventStreamMessage) messageType() string {
+	return m.headers[":message-type"]
+}
+
+func (m eventStreamMessage) eventType() string {
+	if t, ok := m.headers[":event-type"]; ok {
+		return t
+	}
+	return m.headers[":exception-type"]
+}
+
+const (
+	eventStreamPreludeLength = 12
+	eventStreamTrailerLength = 4
+	eventStreamMaxMessage    = 16 * 1024 * 1024
+)
+
+func readEventStream(provider string, r io.Reader, onMessage func(eventStreamMessage) (bool, error)) error {
+	prelude := make([]byte, eventStreamPreludeLength)
+	for {
+		if _, err := io.ReadFull(r, prelude); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  err.Error(),
+			}
+		}
+
+		totalLength := binary.BigEndian.Uint32(prelude[0:4])
+		headersLength := binary.BigEndian.Uint32(prelude[4:8])
+		preludeCRC := binary.BigEndian.Uint32(prelude[8:12])
+		if crc32.ChecksumIEEE(prelude[0:8]) != preludeCRC {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  "event stream prelude checksum mismatch",
+			}
+		}
+		if totalLength > eventStreamMaxMessage || totalLength < eventStreamPreludeLength+eventStreamTrailerLength+headersLength {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  fmt.Sprintf("invalid event stream message length %d", totalLength),
+			}
+		}
+
+		frame := make([]byte, totalLength)
+		copy(frame, prelude)
+		if _, err := io.ReadFull(r, frame[eventStreamPreludeLength:]); err != nil {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  err.Error(),
+			}
+		}
+		messageCRC := binary.BigEndian.Uint32(frame[totalLength-eventStreamTrailerLength:])
+		if crc32.ChecksumIEEE(frame[:totalLength-eventStreamTrailerLength]) != messageCRC {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  "event stream message checksum mismatch",
+			}
+		}
+
+		headerBytes := frame[eventStreamPreludeLength : eventStreamPreludeLength+headersLength]
+		headers, err := decodeEventStreamHeaders(headerBytes)
+		if err != nil {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  err.Error(),
+			}
+		}
+		msg := eventStreamMessage{
+			headers: headers,
+			payload: frame[eventStreamPreludeLength+headersLength : totalLength-eventStreamTrailerLength],
+		}
+
+		cont, handlerErr := onMessage(msg)
+		if handlerErr != nil {
+			if err, ok := handlerErr.(*AIError); ok {
+				return err
+			}
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  handlerErr.Error(),
+			}
+		}
+		if !cont {
+			return nil
+		}
+	}
+}
+
+// decodeEventStreamHeaders decodes the header block of a frame. Non-string
+// values are formatted with fmt so callers can still inspect them.
+func decodeEventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := map[string]string{}
+	for len(data) > 0 {
+		nameLen := int(data[0])
+		if len(data) < 1+nameLen+1 {
+			return nil, errors.New("truncated event stream header")
+		}
+		name := string(data[1 : 1+nameLen])
+		typ := data[1+nameLen]
+		data = data[2+nameLen:]
+
+		var value string
+		switch typ {
+		case 0:
+			value = "true"
+		case 1:
+			value = "false"
+		case 2:
+			if len(data) < 1 {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = fmt.Sprint(int8(data[0]))
+			data = data[1:]
+		case 3:
+			if len(data) < 2 {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = fmt.Sprint(int16(binary.BigEndian.Uint16(data)))
+			data = data[2:]
+		case 4:
+			if len(data) < 4 {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = fmt.Sprint(int32(binary.BigEndian.Uint32(data)))
+			data = data[4:]
+		case 5, 8:
+			if len(data) < 8 {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = fmt.Sprint(int64(binary.BigEndian.Uint64(data)))
+			data = data[8:]
+		case 6, 7:
+			if len(data) < 2 {
+				return nil, errors.New("truncated event stream header")
+			}
+			valueLen := int(binary.BigEndian.Uint16(data))
+			if len(data) < 2+valueLen {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = string(data[2 : 2+valueLen])
+			data = data[2+valueLen:]
+		case 9:
+			if len(data) < 16 {
+				return nil, errors.New("truncated event stream header")
+			}
+			value = fmt.Sprintf("%x", data[:16])
+			data = data[16:]
+		default:
+			return nil, fmt.Errorf("unknown event stream header type %d", typ)
+		}
+		headers[name] = value
+	}
+	return headers, nil
+}
+
+// eventStreamException converts an exception frame into an AIError.
+func eventStreamException(provider string, msg eventStreamMessage) *AIError {
+	var body struct {
+		Message string `json:"message"`
+	}
+	message := string(msg.payload)
+	if err := json.Unmarshal(msg.payload, &body); err == nil && body.Message != "" {
+		message = body.Message
+	}
+	switch msg.eventType() {
+	case "throttlingException", "serviceUnavailableException":
+		return RateLimitError(provider, message)
+	case "accessDeniedException", "unrecognizedClientException":
+		return AuthenticationError(provider, message)
+	case "validationException", "resourceNotFoundException":
+		return ConfigurationError(provider, message)
+	}
+	return UnknownError(provider, fmt.Sprintf("%s: %s", msg.eventType(), message))
+}
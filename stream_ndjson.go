@@ -0,0 +1,46 @@
+package aikit
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+// readNDJSON reads newline-delimited JSON, invoking onLine for every
+// non-empty line until it returns false or the stream ends.
+func readNDJSON(provider string, r io.Reader, onLine func([]byte) (bool, error)) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return &AIError{
+				Category: AIErrorCategoryStreamingError,
+				Provider: provider,
+				Message:  err.Error(),
+			}
+		}
+
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			cont, handlerErr := onLine(line)
+			if handlerErr != nil {
+				if err, ok := handlerErr.(*AIError); ok {
+					return err
+				}
+				return &AIError{
+					Category: AIErrorCategoryStreamingError,
+					Provider: provider,
+					Message:  handlerErr.Error(),
+				}
+			}
+			if !cont {
+				return nil
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package aikit
+
+import "strings"
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+type thinkTagState int
+
+const (
+	thinkTagStart thinkTagState = iota
+	thinkTagInside
+	thinkTagAfter
+	thinkTagText
+)
+
+// thinkTagParser splits streamed content that begins with an inline
+// <think>...</think> section (DeepSeek R1, Qwen and other open reasoning
+// models) into thinking and text. Tags may be split across chunks, so any
+// text that could still be the start of a tag is held back until the next
+// feed. Content that doesn't open with <think> passes through unchanged.
+type thinkTagParser struct {
+	state   thinkTagState
+	pending string
+}
+
+// feed consumes the next piece of content and returns the thinking and text
+// that can be emitted so far.
+func (t *thinkTagParser) feed(content string) (thinking string, text string) {
+	t.pending += content
+	if t.state == thinkTagStart {
+		trimmed := strings.TrimLeft(t.pending, " \t\r\n")
+		switch {
+		case trimmed == "" || strings.HasPrefix(thinkOpenTag, trimmed):
+			return "", ""
+		case strings.HasPrefix(trimmed, thinkOpenTag):
+			t.state = thinkTagInside
+			t.pending = trimmed[len(thinkOpenTag):]
+		default:
+			t.state = thinkTagText
+		}
+	}
+	if t.state == thinkTagInside {
+		if idx := strings.Index(t.pending, thinkCloseTag); idx >= 0 {
+			thinking = t.pending[:idx]
+			t.pending = t.pending[idx+len(thinkCloseTag):]
+			t.state = thinkTagAfter
+		} else {
+			hold := partialTagSuffix(t.pending, thinkCloseTag)
+			thinking = t.pending[:len(t.pending)-hold]
+			t.pending = t.pending[len(t.pending)-hold:]
+			return thinking, ""
+		}
+	}
+	if t.state == thinkTagAfter {
+		// Models separate the closing tag from the answer with blank lines.
+		t.pending = strings.TrimLeft(t.pending, " \t\r\n")
+		if t.pending == "" {
+			return thinking, ""
+		}
+		t.state = thinkTagText
+	}
+	text = t.pending
+	t.pending = ""
+	return thinking, text
+}
+
+// flush returns whatever is still held back at the end of the message.
+func (t *thinkTagParser) flush() (thinking string, text string) {
+	pending := t.pending
+	t.pending = ""
+	switch t.state {
+	case thinkTagInside:
+		return pending, ""
+	case thinkTagAfter:
+		return "", ""
+	}
+	return "", pending
+}
+
+// partialTagSuffix returns the length of the longest suffix of s that is a
+// proper prefix of tag.
+func partialTagSuffix(s string, tag string) int {
+	for n := min(len(tag)-1, len(s)); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// wrapThinkTags prefixes text with thinking in the inline tag format.
+func wrapThinkTags(thinking string, text string) string {
+	return thinkOpenTag + thinking + thinkCloseTag + "\n\n" + text
+}
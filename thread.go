@@ -3,6 +3,8 @@ package aikit
 import (
 	"encoding/base64"
 	"fmt"
+	"slices"
+	"time"
 )
 
 // ReasoningConfig configures reasoning behavior for the thread.
@@ -13,6 +15,45 @@ type ReasoningConfig struct {
 	Budget int    `json:"budget,omitempty" xml:"budget,attr,omitempty"`
 }
 
+// CacheTTL is how long a prompt cache entry lives.
+type CacheTTL string
+
+const (
+	CacheTTL5m CacheTTL = "5m"
+	CacheTTL1h CacheTTL = "1h"
+)
+
+// CacheConfig selects which parts of the prompt get cache breakpoints on
+// providers with explicit prompt caching (Anthropic Messages, Bedrock).
+// Providers allow a limited number of breakpoints (four for Anthropic), which
+// are spent in order on tools, system, then the most recent user turns.
+type CacheConfig struct {
+	// System caches the system prompt.
+	System bool `json:"system,omitempty" xml:"system,attr,omitempty"`
+	// Tools caches the tool definitions.
+	Tools bool `json:"tools,omitempty" xml:"tools,attr,omitempty"`
+	// LastUserTurns caches the conversation up to each of the last N user
+	// turns. Zero disables conversation caching.
+	LastUserTurns int `json:"last_user_turns,omitempty" xml:"last_user_turns,attr,omitempty"`
+	// TTL is the cache lifetime. Empty uses the provider default (5m).
+	TTL CacheTTL `json:"ttl,omitempty" xml:"ttl,attr,omitempty"`
+}
+
+// DefaultCacheConfig caches the conversation up to the latest user turn.
+// It is used when Thread.Cache is nil.
+var DefaultCacheConfig = CacheConfig{LastUserTurns: 1}
+
+// AudioConfig asks models that can speak (OpenAI audio models, Gemini TTS)
+// to answer with audio.
+type AudioConfig struct {
+	// Voice is the provider's voice name, e.g. "alloy" or "Kore".
+	Voice string `json:"voice,omitempty" xml:"voice,attr,omitempty"`
+	// Format is the Chat Completions output format ("wav", "mp3", "pcm16",
+	// ...). Empty uses pcm16 when streaming, which is the only format OpenAI
+	// streams, and wav otherwise. Gemini always returns 24kHz PCM.
+	Format string `json:"format,omitempty" xml:"format,attr,omitempty"`
+}
+
 type Thread struct {
 	// Configuration
 	Reasoning              ReasoningConfig                       `json:"reasoning"`
@@ -22,20 +63,79 @@ type Thread struct {
 	MaxWebSearches         int                                   `json:"max_web_searches"`
 	WebFetchEnabled        bool                                  `json:"web_fetch_enabled"`
 	HandleToolFunction     func(name string, args string) string `json:"-"`
-	UpdateOnFinalize       bool                                  `json:"update_on_finalize"`
-	CoalesceTextBlocks     bool                                  `json:"coalesce_text_blocks"`
+	// WebSearcher and WebFetcher run web_search and web_fetch function
+	// tools for providers without hosted web tools. They are only offered
+	// when MaxWebSearches or WebFetchEnabled is set.
+	WebSearcher        WebSearcher  `json:"-"`
+	WebFetcher         WebFetcher   `json:"-"`
+	UpdateOnFinalize   bool         `json:"update_on_finalize"`
+	CoalesceTextBlocks bool         `json:"coalesce_text_blocks"`
+	Cache              *CacheConfig `json:"cache,omitempty"`
+	AudioOutput        *AudioConfig `json:"audio_output,omitempty"`
+	// ImageOutput lets the model generate images: Gemini image models get
+	// the IMAGE response modality and the Responses API gets the
+	// image_generation tool.
+	ImageOutput bool `json:"image_output,omitempty"`
+	// CodeExecution enables the provider's sandboxed code execution tool:
+	// Anthropic code execution or the Responses API code interpreter.
+	CodeExecution bool `json:"code_execution,omitempty"`
+	// ContextManager compacts the thread before requests once it grows past
+	// its token budget.
+	ContextManager *ContextManager `json:"-"`
+	// Pricing prices the thread's usage in Cost. Nil uses DefaultPricing.
+	Pricing *PricingTable `json:"-"`
+	// Clock returns the time used for block and snapshot timestamps. Nil
+	// uses time.Now.
+	Clock func() time.Time `json:"-"`
 
 	Success bool
 	Error   string
-	Result  ThreadUsage
+	// Result is the total usage of the thread: the sum of Turns, kept up to
+	// date while a request streams.
+	Result ThreadUsage
+	// Turns records each request made by Session.Stream, in order. They are
+	// kept in snapshots, so a resumed thread keeps its history.
+	Turns []TurnUsage `json:"turns,omitempty"`
 
 	Model    string `json:"model"`
 	ThreadId string `json:"thread_id"`
 
 	Blocks []*ThreadBlock `json:"blocks"`
+	// BlockSeq is the number of the last generated block id.
+	BlockSeq int `json:"block_seq,omitempty"`
+	// Archive holds the original blocks that context management removed,
+	// trimmed or summarized, oldest first.
+	Archive []*ThreadBlock `json:"archive,omitempty"`
 
 	updated         bool
 	CurrentProvider string
+	// stopReason is set by the adapter when the provider says why the
+	// current response ended.
+	stopReason string
+	// response is the number of the model response being streamed, or 0
+	// between responses.
+	response int
+
+	// clientTools are the web tools aikit runs itself for the current
+	// provider, and clientSearches counts their searches in this session.
+	clientTools    map[string]ToolDefinition
+	clientSearches int
+}
+
+// toolDefinitions returns the caller's tools plus the client-side web
+// tools for the current provider.
+func (s *Thread) toolDefinitions() map[string]ToolDefinition {
+	if len(s.clientTools) == 0 {
+		return s.Tools
+	}
+	tools := make(map[string]ToolDefinition, len(s.Tools)+len(s.clientTools))
+	for name, tool := range s.clientTools {
+		tools[name] = tool
+	}
+	for name, tool := range s.Tools {
+		tools[name] = tool
+	}
+	return tools
 }
 
 // TakeUpdate returns the current update flag and resets it to false.
@@ -58,22 +158,127 @@ func (s *Thread) TakeUpdate() bool {
 //	data, _ := json.Marshal(snapshot)
 //
 //	// Restore conversation
-//	var restored Snapshot
-//	json.Unmarshal(data, &restored)
-//	newThread.Restore(&restored)
-//	// Re-configure: newThread.Model, newThread.Tools, etc.
+//	restored, err := DecodeSnapshot(data)
+//	newThread.Restore(restored)
+//	// Re-configure: newThread.HandleToolFunction, etc.
+//
+// Snapshots carry a schema Version; DecodeSnapshot migrates older versions
+// to the current one.
 type Snapshot struct {
-	Blocks []*ThreadBlock `json:"blocks" xml:"blocks>block"`
+	Version   int       `json:"version,omitempty" xml:"version,attr,omitempty"`
+	CreatedAt time.Time `json:"created_at,omitzero" xml:"created_at,attr,omitempty"`
+	Model     string    `json:"model,omitempty" xml:"model,attr,omitempty"`
+	Provider  string    `json:"provider,omitempty" xml:"provider,attr,omitempty"`
+	// Tools and Schema are the thread's tool definitions and structured
+	// output schema, sorted by tool name.
+	Tools  []SnapshotTool `json:"tools,omitempty" xml:"tools>tool,omitempty"`
+	Schema *JsonSchema    `json:"schema,omitempty" xml:"schema,omitempty"`
+
+	Blocks  []*ThreadBlock `json:"blocks" xml:"blocks>block"`
+	Archive []*ThreadBlock `json:"archive,omitempty" xml:"archive>block,omitempty"`
+	Turns   []TurnUsage    `json:"turns,omitempty" xml:"turns>turn,omitempty"`
+	// BlockSeq continues the thread's block id numbering.
+	BlockSeq int `json:"block_seq,omitempty" xml:"block_seq,attr,omitempty"`
+}
+
+// SnapshotTool is a named tool definition in a snapshot.
+type SnapshotTool struct {
+	Name string `json:"name" xml:"name,attr"`
+	ToolDefinition
 }
 
 // ThreadUsage tracks token and resource usage from inference calls.
 type ThreadUsage struct {
-	CacheReadTokens  int64
-	CacheWriteTokens int64
-	InputTokens      int64
-	OutputTokens     int64
-	WebSearches      int
-	PageViews        int
+	CacheReadTokens  int64 `xml:"cache_read_tokens,attr,omitempty"`
+	CacheWriteTokens int64 `xml:"cache_write_tokens,attr,omitempty"`
+	// CacheWrite1hTokens is the part of CacheWriteTokens written with the
+	// one hour TTL, which is billed at a higher rate.
+	CacheWrite1hTokens int64 `xml:"cache_write_1h_tokens,attr,omitempty"`
+	InputTokens        int64 `xml:"input_tokens,attr,omitempty"`
+	OutputTokens       int64 `xml:"output_tokens,attr,omitempty"`
+	// ReasoningTokens is the part of OutputTokens spent on hidden reasoning.
+	ReasoningTokens int64 `xml:"reasoning_tokens,attr,omitempty"`
+	// AudioInputTokens and AudioOutputTokens are the parts of the input and
+	// output that were audio.
+	AudioInputTokens  int64 `xml:"audio_input_tokens,attr,omitempty"`
+	AudioOutputTokens int64 `xml:"audio_output_tokens,attr,omitempty"`
+	// AcceptedPredictionTokens and RejectedPredictionTokens count the tokens
+	// of a predicted output (OpenAI) that appeared in the completion or not.
+	// Rejected tokens are still billed as output.
+	AcceptedPredictionTokens int64 `xml:"accepted_prediction_tokens,attr,omitempty"`
+	RejectedPredictionTokens int64 `xml:"rejected_prediction_tokens,attr,omitempty"`
+	WebSearches              int   `xml:"web_searches,attr,omitempty"`
+	PageViews                int   `xml:"page_views,attr,omitempty"`
+}
+
+// TurnUsage is the usage of one request to a provider.
+type TurnUsage struct {
+	ThreadUsage
+	Model    string `json:"model,omitempty" xml:"model,attr,omitempty"`
+	Provider string `json:"provider,omitempty" xml:"provider,attr,omitempty"`
+	// StartedAt is when the request was sent.
+	StartedAt time.Time `json:"started_at" xml:"started_at,attr"`
+	// Latency is the time until the response was complete, and
+	// TimeToFirstToken the time until the first content arrived.
+	Latency          time.Duration `json:"latency" xml:"latency,attr"`
+	TimeToFirstToken time.Duration `json:"time_to_first_token,omitempty" xml:"time_to_first_token,attr,omitempty"`
+	// StopReason is the provider's reason for ending the response, e.g.
+	// "end_turn", "tool_calls", "MAX_TOKENS" or "max_output_tokens".
+	StopReason string `json:"stop_reason,omitempty" xml:"stop_reason,attr,omitempty"`
+}
+
+// totalUsage adds up the usage of turns.
+func totalUsage(turns []TurnUsage) ThreadUsage {
+	total := ThreadUsage{}
+	for _, turn := range turns {
+		total = total.plus(turn.ThreadUsage)
+	}
+	return total
+}
+
+// since returns the usage accumulated after before was recorded.
+func (u ThreadUsage) since(before ThreadUsage) ThreadUsage {
+	return ThreadUsage{
+		CacheReadTokens:          u.CacheReadTokens - before.CacheReadTokens,
+		CacheWriteTokens:         u.CacheWriteTokens - before.CacheWriteTokens,
+		CacheWrite1hTokens:       u.CacheWrite1hTokens - before.CacheWrite1hTokens,
+		InputTokens:              u.InputTokens - before.InputTokens,
+		OutputTokens:             u.OutputTokens - before.OutputTokens,
+		ReasoningTokens:          u.ReasoningTokens - before.ReasoningTokens,
+		AudioInputTokens:         u.AudioInputTokens - before.AudioInputTokens,
+		AudioOutputTokens:        u.AudioOutputTokens - before.AudioOutputTokens,
+		AcceptedPredictionTokens: u.AcceptedPredictionTokens - before.AcceptedPredictionTokens,
+		RejectedPredictionTokens: u.RejectedPredictionTokens - before.RejectedPredictionTokens,
+		WebSearches:              u.WebSearches - before.WebSearches,
+		PageViews:                u.PageViews - before.PageViews,
+	}
+}
+
+// plus returns the sum of two usages.
+func (u ThreadUsage) plus(other ThreadUsage) ThreadUsage {
+	return ThreadUsage{
+		CacheReadTokens:          u.CacheReadTokens + other.CacheReadTokens,
+		CacheWriteTokens:         u.CacheWriteTokens + other.CacheWriteTokens,
+		CacheWrite1hTokens:       u.CacheWrite1hTokens + other.CacheWrite1hTokens,
+		InputTokens:              u.InputTokens + other.InputTokens,
+		OutputTokens:             u.OutputTokens + other.OutputTokens,
+		ReasoningTokens:          u.ReasoningTokens + other.ReasoningTokens,
+		AudioInputTokens:         u.AudioInputTokens + other.AudioInputTokens,
+		AudioOutputTokens:        u.AudioOutputTokens + other.AudioOutputTokens,
+		AcceptedPredictionTokens: u.AcceptedPredictionTokens + other.AcceptedPredictionTokens,
+		RejectedPredictionTokens: u.RejectedPredictionTokens + other.RejectedPredictionTokens,
+		WebSearches:              u.WebSearches + other.WebSearches,
+		PageViews:                u.PageViews + other.PageViews,
+	}
+}
+
+// CacheConfigValue returns the thread's cache configuration, or
+// DefaultCacheConfig when none is set.
+func (s *Thread) CacheConfigValue() CacheConfig {
+	if s.Cache != nil {
+		return *s.Cache
+	}
+	return DefaultCacheConfig
 }
 
 func NewProviderState() *Thread {
@@ -146,37 +351,120 @@ func (s *Thread) IncompleteToolCalls() int {
 	return count
 }
 
-// Snapshot creates a serializable snapshot of the Thread's conversation blocks.
+// Snapshot creates a serializable snapshot of the Thread's conversation
+// blocks, model, tools and output schema. The blocks are copied, so the
+// snapshot doesn't change as the thread continues.
 func (s *Thread) Snapshot() *Snapshot {
-	return &Snapshot{
-		Blocks: s.Blocks,
-	}
+	snapshot := &Snapshot{
+		Version:   SnapshotVersion,
+		CreatedAt: s.now(),
+		Model:     s.Model,
+		Provider:  s.CurrentProvider,
+		Tools:     snapshotTools(s.Tools),
+		Schema:    s.StructuredOutputSchema,
+		Blocks:    cloneBlocks(s.Blocks),
+	}
+	if len(s.Archive) > 0 {
+		snapshot.Archive = cloneBlocks(s.Archive)
+	}
+	snapshot.Turns = slices.Clone(s.Turns)
+	snapshot.BlockSeq = s.BlockSeq
+	return snapshot
 }
 
-// Restore restores the Thread's blocks from a snapshot.
+// Restore restores the Thread's blocks, and archive, from a copy of the
+// snapshot's blocks. The snapshot's model, tools and schema are used only
+// where the thread has none of its own.
 func (s *Thread) Restore(snapshot *Snapshot) {
-	s.Blocks = make([]*ThreadBlock, len(snapshot.Blocks))
-	copy(s.Blocks, snapshot.Blocks)
+	if s.Model == "" {
+		s.Model = snapshot.Model
+	}
+	if len(s.Tools) == 0 && len(snapshot.Tools) > 0 {
+		s.Tools = make(map[string]ToolDefinition, len(snapshot.Tools))
+		for _, tool := range snapshot.Tools {
+			s.Tools[tool.Name] = tool.ToolDefinition
+		}
+	}
+	if s.StructuredOutputSchema == nil {
+		s.StructuredOutputSchema = snapshot.Schema
+	}
+	s.Blocks = cloneBlocks(snapshot.Blocks)
+	s.Archive = nil
+	if len(snapshot.Archive) > 0 {
+		s.Archive = cloneBlocks(snapshot.Archive)
+	}
+	s.Turns = slices.Clone(snapshot.Turns)
+	s.BlockSeq = snapshot.BlockSeq
+	s.Result = totalUsage(s.Turns)
 }
 
 func (s *Thread) create(id string, typ ThreadBlockType) *ThreadBlock {
-	b := &ThreadBlock{
+	b := s.newBlock(id, typ)
+	s.Blocks = append(s.Blocks, b)
+	return b
+}
+
+// newBlock returns a block stamped with the current provider and time,
+// without adding it to the thread.
+func (s *Thread) newBlock(id string, typ ThreadBlockType) *ThreadBlock {
+	return &ThreadBlock{
 		ID:         id,
 		Type:       typ,
 		ProviderID: s.CurrentProvider,
+		CreatedAt:  s.now(),
+		Response:   s.response,
 	}
-	s.Blocks = append(s.Blocks, b)
-	return b
 }
 
+// beginResponse numbers the blocks added until endResponse as one model
+// response, after the highest number already in the thread.
+func (s *Thread) beginResponse() {
+	last := 0
+	for _, b := range s.Blocks {
+		last = max(last, b.Response)
+	}
+	s.response = last + 1
+}
+
+func (s *Thread) endResponse() {
+	s.response = 0
+}
+
+// now returns the current time from Clock, in UTC.
+func (s *Thread) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock().UTC()
+	}
+	return time.Now().UTC()
+}
+
+// complete marks a block complete, recording when it first was.
+func (s *Thread) complete(b *ThreadBlock) {
+	if !b.Complete || b.CompletedAt.IsZero() {
+		b.CompletedAt = s.now()
+	}
+	b.Complete = true
+}
+
+// NewBlockId returns a new id for a block of type typ. Ids are numbered by
+// BlockSeq rather than the block count, so they stay unique after blocks are
+// truncated or compacted away.
 func (s *Thread) NewBlockId(typ ThreadBlockType) string {
-	return fmt.Sprintf("%s-%d", typ, len(s.Blocks)+1)
+	// Threads built or restored without a counter start after their blocks.
+	s.BlockSeq = max(s.BlockSeq, len(s.Blocks))
+	for {
+		s.BlockSeq++
+		id := fmt.Sprintf("%s-%d", typ, s.BlockSeq)
+		if s.blockIndex(id) < 0 {
+			return id
+		}
+	}
 }
 
 func (s *Thread) Complete(id string) {
 	for blockIdx := range s.Blocks {
 		if s.Blocks[blockIdx].ID == id {
-			s.Blocks[blockIdx].Complete = true
+			s.complete(s.Blocks[blockIdx])
 			if s.UpdateOnFinalize {
 				s.updated = true
 			}
@@ -194,37 +482,131 @@ func (s *Thread) getType(id string, ofType ThreadBlockType) *ThreadBlock {
 	return nil
 }
 func (s *Thread) System(text string) {
-	b := s.create("", InferenceBlockSystem)
+	b := s.create(s.NewBlockId(InferenceBlockSystem), InferenceBlockSystem)
 	b.Text = text
-	b.Complete = true
+	s.complete(b)
 }
 func (s *Thread) Input(text string) {
-	b := s.create("", InferenceBlockInput)
+	b := s.create(s.NewBlockId(InferenceBlockInput), InferenceBlockInput)
 	b.Text = text
-	b.Complete = true
+	s.complete(b)
+}
+
+// InputFrom adds input written by author, for conversations with several
+// participants. Chat Completions sends the author as the message name.
+func (s *Thread) InputFrom(author string, text string) {
+	b := s.create(s.NewBlockId(InferenceBlockInput), InferenceBlockInput)
+	b.Text = text
+	b.Author = author
+	s.complete(b)
 }
 
 // InputImage adds an image to the thread using raw bytes.
 // The bytes are immediately encoded to base64 and stored.
 // mediaType should be a valid MIME type (e.g., "image/jpeg", "image/png", "image/gif", "image/webp").
 func (s *Thread) InputImage(data []byte, mediaType string) {
-	b := s.create("", InferenceBlockInputImage)
+	b := s.create(s.NewBlockId(InferenceBlockInputImage), InferenceBlockInputImage)
 	b.Image = &ThreadImage{
 		Base64:    base64.StdEncoding.EncodeToString(data),
 		MediaType: mediaType,
 	}
-	b.Complete = true
+	s.complete(b)
 }
 
 // InputImageBase64 adds an image to the thread using a pre-encoded base64 string.
 // mediaType should be a valid MIME type (e.g., "image/jpeg", "image/png", "image/gif", "image/webp").
 func (s *Thread) InputImageBase64(base64Data string, mediaType string) {
-	b := s.create("", InferenceBlockInputImage)
+	b := s.create(s.NewBlockId(InferenceBlockInputImage), InferenceBlockInputImage)
 	b.Image = &ThreadImage{
 		Base64:    base64Data,
 		MediaType: mediaType,
 	}
-	b.Complete = true
+	s.complete(b)
+}
+
+// InputDocument adds a document (e.g. "application/pdf" or "text/plain") to
+// the thread using raw bytes. title is optional; citations asks providers
+// that support it to cite the document in their answer.
+func (s *Thread) InputDocument(data []byte, mediaType string, title string, citations bool) {
+	s.InputDocumentBase64(base64.StdEncoding.EncodeToString(data), mediaType, title, citations)
+}
+
+// InputDocumentBase64 adds a document using a pre-encoded base64 string.
+func (s *Thread) InputDocumentBase64(base64Data string, mediaType string, title string, citations bool) {
+	b := s.create(s.NewBlockId(InferenceBlockInputDocument), InferenceBlockInputDocument)
+	b.Document = &ThreadDocument{
+		Base64:    base64Data,
+		MediaType: mediaType,
+		Title:     title,
+		Citations: citations,
+	}
+	s.complete(b)
+}
+
+// InputAudio adds audio to the thread using raw bytes, e.g. "audio/wav" or
+// "audio/mpeg". Raw PCM ("audio/pcm" or "audio/L16;rate=16000") is wrapped
+// as WAV for providers that need a container.
+func (s *Thread) InputAudio(data []byte, mediaType string) {
+	s.InputAudioBase64(base64.StdEncoding.EncodeToString(data), mediaType)
+}
+
+// InputAudioBase64 adds audio using a pre-encoded base64 string.
+func (s *Thread) InputAudioBase64(base64Data string, mediaType string) {
+	b := s.create(s.NewBlockId(InferenceBlockInputAudio), InferenceBlockInputAudio)
+	b.Audio = &ThreadAudio{
+		Base64:    base64Data,
+		MediaType: mediaType,
+	}
+	s.complete(b)
+}
+
+// Audio appends a piece of generated audio to the output block with the
+// given id. The delta's base64 data and transcript are appended; its other
+// fields are set when present.
+func (s *Thread) Audio(id string, delta ThreadAudio) {
+	if delta.Base64 == "" && delta.Transcript == "" && delta.ID == "" && delta.ExpiresAt == 0 {
+		return
+	}
+	b := s.findOrCreateIDBlock(id, InferenceBlockAudio)
+	if b.Audio == nil {
+		b.Audio = &ThreadAudio{}
+	}
+	if delta.MediaType != "" {
+		b.Audio.MediaType = delta.MediaType
+	}
+	if delta.SampleRate != 0 {
+		b.Audio.SampleRate = delta.SampleRate
+	}
+	if delta.ID != "" {
+		b.Audio.ID = delta.ID
+	}
+	if delta.ExpiresAt != 0 {
+		b.Audio.ExpiresAt = delta.ExpiresAt
+	}
+	b.Audio.appendBase64(delta.Base64)
+	b.Audio.Transcript += delta.Transcript
+	s.updated = true
+}
+
+// OutputImage sets the image of the generated image block with the given
+// id. Providers that stream partial previews call it again with each newer
+// image and complete the block once the final image arrives.
+func (s *Thread) OutputImage(id string, image ThreadImage) {
+	s.OutputImageWithSignature(id, image, "")
+}
+
+// OutputImageWithSignature is OutputImage for providers that sign generated
+// images and need the signature back on later turns (Gemini).
+func (s *Thread) OutputImageWithSignature(id string, image ThreadImage, signature string) {
+	if image.Base64 == "" {
+		return
+	}
+	b := s.findOrCreateIDBlock(id, InferenceBlockOutputImage)
+	b.Image = &image
+	if signature != "" {
+		b.Signature = signature
+	}
+	s.updated = true
 }
 func (s *Thread) Text(id string, text string) {
 	if text == "" {
@@ -253,7 +635,7 @@ func (s *Thread) Coalesce(id string, typ ThreadBlockType) *ThreadBlock {
 	b := s.create(id, typ)
 	return b
 }
-func (s *Thread) Cite(id string, citation string) {
+func (s *Thread) Cite(id string, citation ThreadCitation) {
 	b := s.findOrCreateIDBlock(id, InferenceBlockText)
 	if b == nil {
 		b = s.create(s.NewBlockId(InferenceBlockText), InferenceBlockText)
@@ -299,6 +681,15 @@ func (s *Thread) EncryptedThinking(text string) {
 	b := s.create("", InferenceBlockEncryptedThinking)
 	b.Text += text
 }
+
+// EncryptedThinkingWithID records an opaque reasoning item under the
+// provider's item id so it can be paired with its summary on replay.
+func (s *Thread) EncryptedThinkingWithID(id string, text string) {
+	b := s.create(id, InferenceBlockEncryptedThinking)
+	b.Text += text
+	s.complete(b)
+	s.updated = true
+}
 func (s *Thread) ToolCall(id string, name string, arguments string) {
 	var b *ThreadBlock
 	for blockIdx := range s.Blocks {
@@ -356,7 +747,7 @@ func (s *Thread) ToolResult(toolCall *ThreadToolCall, output string) {
 			ToolCallID: toolCall.ID,
 			Output:     output,
 		}
-		b.Complete = true
+		s.complete(b)
 		s.updated = true
 	}
 }
@@ -395,18 +786,87 @@ func (s *Thread) WebSearchResult(id string, result ThreadWebSearchResult) {
 }
 func (s *Thread) CompleteWebSearch(id string) {
 	b := s.findOrCreateIDBlock(id, InferenceBlockWebSearch)
-	b.Complete = true
-	s.Result.WebSearches++
+	if !b.Complete {
+		// Providers report the query and the results separately; the
+		// search is only counted once.
+		s.Result.WebSearches++
+	}
+	s.complete(b)
+	s.updated = true
+}
+
+// WebSearchError records that a hosted search failed with the provider's
+// error code.
+func (s *Thread) WebSearchError(id string, code string) {
+	b := s.findOrCreateIDBlock(id, InferenceBlockWebSearch)
+	if b.WebSearch == nil {
+		b.WebSearch = &ThreadWebSearch{}
+	}
+	b.WebSearch.ErrorCode = code
+	s.CompleteWebSearch(id)
+}
+func (s *Thread) serverTool(id string) *ThreadServerTool {
+	b := s.findOrCreateIDBlock(id, InferenceBlockServerTool)
+	if b.ServerTool == nil {
+		b.ServerTool = &ThreadServerTool{}
+	}
 	s.updated = true
+	return b.ServerTool
+}
+
+// ServerTool starts a call to a provider-run tool such as code execution.
+func (s *Thread) ServerTool(id string, name string) {
+	s.serverTool(id).Name = name
 }
+
+// ServerToolInput appends streamed JSON input to a server tool call.
+func (s *Thread) ServerToolInput(id string, partial string) {
+	s.serverTool(id).Input += partial
+}
+
+// ServerToolResult records the raw result of a server tool call and
+// completes it. A non-empty errorCode marks the call as failed.
+func (s *Thread) ServerToolResult(id string, resultType string, result string, errorCode string) {
+	tool := s.serverTool(id)
+	tool.ResultType = resultType
+	tool.Result = result
+	tool.ErrorCode = errorCode
+	s.Complete(id)
+}
+
+// ServerToolFile records a file produced by a server tool call, once.
+func (s *Thread) ServerToolFile(id string, file ThreadServerToolFile) {
+	tool := s.serverTool(id)
+	for i, existing := range tool.Files {
+		if (file.ID != "" && existing.ID == file.ID) || (file.URL != "" && existing.URL == file.URL) {
+			if file.Filename != "" {
+				tool.Files[i].Filename = file.Filename
+			}
+			return
+		}
+	}
+	tool.Files = append(tool.Files, file)
+}
+
 func (s *Thread) ViewWebpage(id string) {
 	b := s.findOrCreateIDBlock(id, InferenceBlockViewWebpage)
 	b.Complete = false
 }
+
+// WebFetchResult records the fetched page, or its error code, on a
+// view_webpage block.
+func (s *Thread) WebFetchResult(id string, result ThreadWebFetch) {
+	b := s.findOrCreateIDBlock(id, InferenceBlockViewWebpage)
+	b.WebFetch = &result
+	if b.Text == "" {
+		b.Text = result.URL
+	}
+	s.updated = true
+}
 func (s *Thread) ViewWebpageUrl(id string, url string) {
 	b := s.findOrCreateIDBlock(id, InferenceBlockViewWebpage)
 	b.Text = url
-	b.Complete = true
+	s.complete(b)
 	s.Result.PageViews++
 	s.updated = true
 }
@@ -1,8 +1,13 @@
 package aikit
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 )
 
 type ThreadBlockType string
@@ -11,12 +16,17 @@ const (
 	InferenceBlockSystem            ThreadBlockType = "system"
 	InferenceBlockInput             ThreadBlockType = "input"
 	InferenceBlockInputImage        ThreadBlockType = "input_image"
+	InferenceBlockInputDocument     ThreadBlockType = "input_document"
+	InferenceBlockInputAudio        ThreadBlockType = "input_audio"
 	InferenceBlockThinking          ThreadBlockType = "thinking"
 	InferenceBlockEncryptedThinking ThreadBlockType = "encrypted_thinking"
 	InferenceBlockText              ThreadBlockType = "text"
+	InferenceBlockAudio             ThreadBlockType = "audio"
+	InferenceBlockOutputImage       ThreadBlockType = "output_image"
 	InferenceBlockToolCall          ThreadBlockType = "tool_call"
 	InferenceBlockWebSearch         ThreadBlockType = "web_search"
 	InferenceBlockViewWebpage       ThreadBlockType = "view_webpage"
+	InferenceBlockServerTool        ThreadBlockType = "server_tool"
 )
 
 type ThreadToolCall struct {
@@ -33,11 +43,128 @@ type ThreadToolResult struct {
 type ThreadWebSearch struct {
 	Query   string                  `json:"query" xml:"query,attr"`
 	Results []ThreadWebSearchResult `json:"results" xml:"results>result"`
+	// ErrorCode is set when the search failed, e.g. "max_uses_exceeded" or
+	// "too_many_requests".
+	ErrorCode string `json:"error_code,omitempty" xml:"error_code,attr,omitempty"`
 }
 
 type ThreadWebSearchResult struct {
 	Title string `json:"title" xml:"title,attr"`
 	URL   string `json:"url" xml:"url,attr"`
+	// PageAge is how old the page is as reported by the search provider,
+	// e.g. "April 30, 2025".
+	PageAge string `json:"page_age,omitempty" xml:"page_age,attr,omitempty"`
+	Snippet string `json:"snippet,omitempty" xml:"snippet,omitempty"`
+	// EncryptedContent is the opaque page content Anthropic needs back to
+	// cite the result on later turns.
+	EncryptedContent string `json:"encrypted_content,omitempty" xml:"encrypted_content,omitempty"`
+}
+
+// ThreadWebFetch is the result of a hosted web fetch. The fetched page is
+// kept as a document (plain text, or base64 PDF data) so it can be replayed.
+type ThreadWebFetch struct {
+	URL         string          `json:"url" xml:"url,attr"`
+	RetrievedAt string          `json:"retrieved_at,omitempty" xml:"retrieved_at,attr,omitempty"`
+	Page        *ThreadDocument `json:"page,omitempty" xml:"page,omitempty"`
+	// ErrorCode is set when the fetch failed, e.g. "url_not_accessible" or
+	// "max_uses_exceeded".
+	ErrorCode string `json:"error_code,omitempty" xml:"error_code,attr,omitempty"`
+}
+
+// Title returns the fetched page's title, if any.
+func (f *ThreadWebFetch) Title() string {
+	if f.Page == nil {
+		return ""
+	}
+	return f.Page.Title
+}
+
+// Content returns the fetched page's text.
+func (f *ThreadWebFetch) Content() string {
+	if f.Page == nil {
+		return ""
+	}
+	return f.Page.Text()
+}
+
+// ThreadServerTool is a call to a tool the provider runs itself other than
+// web search and fetch, such as Anthropic code execution and its bash and
+// text editor tools, or the OpenAI code interpreter. The input and result
+// are kept as the provider's JSON so they can be replayed.
+type ThreadServerTool struct {
+	Name  string `json:"name" xml:"name,attr"`
+	Input string `json:"input,omitempty" xml:"input,omitempty"`
+	// ResultType is the provider's type for the result, e.g.
+	// "bash_code_execution_tool_result" or "code_interpreter_call".
+	ResultType string `json:"result_type,omitempty" xml:"result_type,attr,omitempty"`
+	Result     string `json:"result,omitempty" xml:"result,omitempty"`
+	// ErrorCode is set when the tool failed, e.g. "execution_time_exceeded".
+	ErrorCode   string                 `json:"error_code,omitempty" xml:"error_code,attr,omitempty"`
+	ContainerID string                 `json:"container_id,omitempty" xml:"container_id,attr,omitempty"`
+	Files       []ThreadServerToolFile `json:"files,omitempty" xml:"files>file,omitempty"`
+}
+
+// ThreadServerToolFile is a file produced by a server tool. Anthropic files
+// are downloaded with the Files API using ID; OpenAI images may have a URL.
+type ThreadServerToolFile struct {
+	ID       string `json:"id,omitempty" xml:"id,attr,omitempty"`
+	Filename string `json:"filename,omitempty" xml:"filename,attr,omitempty"`
+	URL      string `json:"url,omitempty" xml:"url,attr,omitempty"`
+}
+
+// CitationType is the kind of source a citation points to.
+type CitationType string
+
+const (
+	CitationURL      CitationType = "url"
+	CitationDocument CitationType = "document"
+)
+
+// ThreadCitation attributes part of a text block to a source. StartIndex and
+// EndIndex are byte offsets into the block's Text; Anthropic cites whole
+// blocks, so its citations span all of the text.
+type ThreadCitation struct {
+	Type CitationType `json:"type" xml:"type,attr"`
+	URL  string       `json:"url,omitempty" xml:"url,attr,omitempty"`
+	// DocumentIndex is the position of the cited document among the
+	// thread's document inputs, for document citations.
+	DocumentIndex int    `json:"document_index,omitempty" xml:"document_index,attr,omitempty"`
+	Title         string `json:"title,omitempty" xml:"title,attr,omitempty"`
+	// CitedText is the quoted source passage (Anthropic) or the supported
+	// segment of the answer (Gemini).
+	CitedText  string `json:"cited_text,omitempty" xml:"cited_text,omitempty"`
+	StartIndex int    `json:"start_index" xml:"start_index,attr"`
+	EndIndex   int    `json:"end_index" xml:"end_index,attr"`
+}
+
+// UnmarshalJSON also accepts the plain URL strings that older snapshots
+// stored as citations.
+func (c *ThreadCitation) UnmarshalJSON(data []byte) error {
+	var url string
+	if err := json.Unmarshal(data, &url); err == nil {
+		*c = ThreadCitation{Type: CitationURL, URL: url}
+		return nil
+	}
+	type citation ThreadCitation
+	return json.Unmarshal(data, (*citation)(c))
+}
+
+// UnmarshalXML also accepts the <citation>URL</citation> elements that older
+// snapshots stored.
+func (c *ThreadCitation) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type citation ThreadCitation
+	var v struct {
+		citation
+		Legacy string `xml:",chardata"`
+	}
+	if err := d.DecodeElement(&v, &start); err != nil {
+		return err
+	}
+	*c = ThreadCitation(v.citation)
+	if c.Type == "" {
+		*c = ThreadCitation{Type: CitationURL, URL: strings.TrimSpace(v.Legacy)}
+	}
+	return nil
 }
 
 // ThreadImage represents image data for vision input.
@@ -45,6 +172,9 @@ type ThreadWebSearchResult struct {
 type ThreadImage struct {
 	Base64    string `json:"base64" xml:"base64"`
 	MediaType string `json:"media_type" xml:"media_type,attr"`
+	// RevisedPrompt is the prompt the image generator actually used, for
+	// generated images.
+	RevisedPrompt string `json:"revised_prompt,omitempty" xml:"revised_prompt,omitempty"`
 }
 
 // GetBase64 returns the base64-encoded image data.
@@ -58,6 +188,104 @@ func (img *ThreadImage) GetDataURL() string {
 	return fmt.Sprintf("data:%s;base64,%s", img.MediaType, img.GetBase64())
 }
 
+// ThreadDocument represents a file given to the model as input, such as a
+// PDF or plain text. Like images, documents are stored base64-encoded.
+type ThreadDocument struct {
+	Base64    string `json:"base64" xml:"base64"`
+	MediaType string `json:"media_type" xml:"media_type,attr"`
+	Title     string `json:"title,omitempty" xml:"title,attr,omitempty"`
+	// Citations asks providers that support it (Anthropic) to cite passages
+	// of the document in their answer.
+	Citations bool `json:"citations,omitempty" xml:"citations,attr,omitempty"`
+}
+
+// GetBase64 returns the base64-encoded document data.
+func (doc *ThreadDocument) GetBase64() string {
+	return doc.Base64
+}
+
+// GetDataURL returns a data URL suitable for OpenAI-style APIs.
+func (doc *ThreadDocument) GetDataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", doc.MediaType, doc.GetBase64())
+}
+
+// IsText reports whether the document is plain text rather than a binary
+// format.
+func (doc *ThreadDocument) IsText() bool {
+	return strings.HasPrefix(doc.MediaType, "text/")
+}
+
+// Filename returns the title, or a generic name with an extension matching
+// the media type for providers that require a filename.
+func (doc *ThreadDocument) Filename() string {
+	if doc.Title != "" {
+		return doc.Title
+	}
+	switch {
+	case doc.MediaType == "application/pdf":
+		return "document.pdf"
+	case doc.IsText():
+		return "document.txt"
+	}
+	return "document"
+}
+
+// Text returns the document's text. Plain text documents are decoded as-is
+// and PDFs go through a basic text extractor, which handles uncompressed and
+// Flate-compressed text but not embedded fonts with custom encodings.
+// Returns "" if no text could be recovered.
+func (doc *ThreadDocument) Text() string {
+	data, err := base64.StdEncoding.DecodeString(doc.Base64)
+	if err != nil {
+		return ""
+	}
+	if doc.IsText() {
+		return string(data)
+	}
+	if doc.MediaType == "application/pdf" {
+		return extractPDFText(data)
+	}
+	return ""
+}
+
+// textFallback renders the document as plain text for providers without
+// native document input.
+func (doc *ThreadDocument) textFallback() string {
+	text := strings.TrimSpace(doc.Text())
+	if text == "" {
+		return fmt.Sprintf("[Document %s (%s) could not be converted to text]", doc.Filename(), doc.MediaType)
+	}
+	return fmt.Sprintf("Document: %s\n\n%s", doc.Filename(), text)
+}
+
+// ThreadAudio holds audio given to the model as input or spoken by the model
+// as output. Audio is stored base64-encoded; raw PCM (audio/pcm or
+// audio/L16) can be wrapped into a WAV file with WAV.
+type ThreadAudio struct {
+	Base64    string `json:"base64" xml:"base64"`
+	MediaType string `json:"media_type" xml:"media_type,attr"`
+	// SampleRate is the rate of raw PCM audio in Hz. Zero means 24000, which
+	// is what OpenAI and Gemini produce.
+	SampleRate int `json:"sample_rate,omitempty" xml:"sample_rate,attr,omitempty"`
+	// ID is the provider's id for generated audio (OpenAI), used to refer
+	// back to it on later turns instead of resending the data.
+	ID string `json:"id,omitempty" xml:"audio_id,attr,omitempty"`
+	// ExpiresAt is when the provider forgets ID, in Unix seconds.
+	ExpiresAt int64 `json:"expires_at,omitempty" xml:"expires_at,attr,omitempty"`
+	// Transcript is the text of generated audio, when the provider sends it.
+	Transcript string `json:"transcript,omitempty" xml:"transcript,omitempty"`
+}
+
+// GetBase64 returns the base64-encoded audio data.
+func (a *ThreadAudio) GetBase64() string {
+	return a.Base64
+}
+
+// GetDataURL returns a data URL for the audio.
+func (a *ThreadAudio) GetDataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", a.MediaType, a.GetBase64())
+}
+
 type ThreadBlock struct {
 	ID   string          `json:"id,omitempty" xml:"id,attr,omitempty"`
 	Type ThreadBlockType `json:"type" xml:"type,attr"`
@@ -67,11 +295,68 @@ type ThreadBlock struct {
 	ToolCall   *ThreadToolCall   `json:"tool_call,omitempty" xml:"tool_call,omitempty"`
 	ToolResult *ThreadToolResult `json:"tool_result,omitempty" xml:"tool_result,omitempty"`
 	WebSearch  *ThreadWebSearch  `json:"web_search,omitempty" xml:"web_search,omitempty"`
+	WebFetch   *ThreadWebFetch   `json:"web_fetch,omitempty" xml:"web_fetch,omitempty"`
+	ServerTool *ThreadServerTool `json:"server_tool,omitempty" xml:"server_tool,omitempty"`
 	Image      *ThreadImage      `json:"image,omitempty" xml:"image,omitempty"`
+	Document   *ThreadDocument   `json:"document,omitempty" xml:"document,omitempty"`
+	Audio      *ThreadAudio      `json:"audio,omitempty" xml:"audio,omitempty"`
 	Complete   bool              `json:"complete" xml:"complete,attr"`
 	Continued  bool              `json:"continued,omitempty" xml:"continued,attr,omitempty"`
-	Citations  []string          `json:"citations,omitempty" xml:"citations>citation,omitempty"`
+	Citations  []ThreadCitation  `json:"citations,omitempty" xml:"citations>citation,omitempty"`
 	ProviderID string            `json:"provider_id,omitempty" xml:"provider_id,attr,omitempty"`
+
+	// CreatedAt and CompletedAt are set by the thread when the block is
+	// added and when it's complete.
+	CreatedAt   time.Time `json:"created_at,omitzero" xml:"created_at,attr,omitempty"`
+	CompletedAt time.Time `json:"completed_at,omitzero" xml:"completed_at,attr,omitempty"`
+	// Response numbers the model response that produced the block, so
+	// adapters only group blocks from the same response into one message.
+	// It is 0 for inputs and for blocks added outside Session.Stream.
+	Response int `json:"response,omitempty" xml:"response,attr,omitempty"`
+	// Author names who wrote an input block in a conversation with several
+	// participants. Providers that support message names receive it.
+	Author string `json:"author,omitempty" xml:"author,attr,omitempty"`
+	// Metadata is free-form data for the application. It isn't sent to
+	// providers.
+	Metadata ThreadMetadata `json:"metadata,omitempty" xml:"metadata,omitempty"`
+}
+
+// ThreadMetadata is a block's free-form metadata. In XML each entry is an
+// <entry key="..."> element, sorted by key.
+type ThreadMetadata map[string]string
+
+type threadMetadataEntry struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+func (m ThreadMetadata) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	entries := struct {
+		Entries []threadMetadataEntry `xml:"entry"`
+	}{}
+	for _, key := range keys {
+		entries.Entries = append(entries.Entries, threadMetadataEntry{Key: key, Value: m[key]})
+	}
+	return e.EncodeElement(entries, start)
+}
+
+func (m *ThreadMetadata) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var entries struct {
+		Entries []threadMetadataEntry `xml:"entry"`
+	}
+	if err := d.DecodeElement(&entries, &start); err != nil {
+		return err
+	}
+	*m = make(ThreadMetadata, len(entries.Entries))
+	for _, entry := range entries.Entries {
+		(*m)[entry.Key] = entry.Value
+	}
+	return nil
 }
 
 func (b *ThreadBlock) Description() string {
@@ -86,6 +371,31 @@ func (b *ThreadBlock) Description() string {
 			return fmt.Sprintf("| Image: [%s, ~%d bytes]", b.Image.MediaType, size)
 		}
 		return "| Image: [embedded]"
+	case InferenceBlockInputDocument:
+		if b.Document != nil {
+			size := len(b.Document.Base64) * 3 / 4
+			return fmt.Sprintf("| Document: [%s, %s, ~%d bytes]", b.Document.Filename(), b.Document.MediaType, size)
+		}
+		return "| Document: [embedded]"
+	case InferenceBlockInputAudio:
+		if b.Audio != nil {
+			size := len(b.Audio.Base64) * 3 / 4
+			return fmt.Sprintf("| Audio: [%s, ~%d bytes]", b.Audio.MediaType, size)
+		}
+		return "| Audio: [embedded]"
+	case InferenceBlockAudio:
+		if b.Audio == nil {
+			return ""
+		}
+		if b.Audio.Transcript != "" {
+			return "\n\n(spoken) " + b.Audio.Transcript
+		}
+		return fmt.Sprintf("\n\n(spoken) [%s, ~%d bytes]", b.Audio.MediaType, len(b.Audio.Base64)*3/4)
+	case InferenceBlockOutputImage:
+		if b.Image != nil {
+			return fmt.Sprintf("\n\n[Generated image: %s, ~%d bytes]", b.Image.MediaType, len(b.Image.Base64)*3/4)
+		}
+		return ""
 	case InferenceBlockThinking:
 		return "| Thinking: " + strings.ReplaceAll(b.Text, "\n", "\n|\t")
 	case InferenceBlockEncryptedThinking:
@@ -93,11 +403,40 @@ func (b *ThreadBlock) Description() string {
 	case InferenceBlockText:
 		return "\n\n" + b.Text
 	case InferenceBlockToolCall:
+		if b.ToolCall == nil {
+			return ""
+		}
+		if b.ToolResult == nil {
+			return fmt.Sprintf("-> %s\n<- (no result)", b.ToolCall.Name)
+		}
 		return fmt.Sprintf("-> %s\n<- %s", b.ToolCall.Name, string(b.ToolResult.Output))
 	case InferenceBlockWebSearch:
+		if b.WebSearch == nil {
+			return ""
+		}
+		if b.WebSearch.ErrorCode != "" {
+			return fmt.Sprintf("| Search for '%s' failed: %s", b.WebSearch.Query, b.WebSearch.ErrorCode)
+		}
 		return fmt.Sprintf("| Searched for '%s'", b.WebSearch.Query)
 	case InferenceBlockViewWebpage:
+		if b.WebFetch != nil && b.WebFetch.ErrorCode != "" {
+			return fmt.Sprintf("| Viewing '%s' failed: %s", b.Text, b.WebFetch.ErrorCode)
+		}
+		if b.WebFetch != nil && b.WebFetch.Title() != "" {
+			return fmt.Sprintf("| Viewed '%s' (%s)", b.Text, b.WebFetch.Title())
+		}
 		return fmt.Sprintf("| Viewed '%s'", b.Text)
+	case InferenceBlockServerTool:
+		if b.ServerTool == nil {
+			return ""
+		}
+		if b.ServerTool.ErrorCode != "" {
+			return fmt.Sprintf("| Ran %s, failed: %s", b.ServerTool.Name, b.ServerTool.ErrorCode)
+		}
+		if len(b.ServerTool.Files) > 0 {
+			return fmt.Sprintf("| Ran %s (%d files)", b.ServerTool.Name, len(b.ServerTool.Files))
+		}
+		return fmt.Sprintf("| Ran %s", b.ServerTool.Name)
 	default:
 		return ""
 	}
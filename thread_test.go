@@ -2,6 +2,7 @@ package aikit
 
 import (
 	"testing"
+	"time"
 )
 
 func TestUnit_Thread_GetTypeFindsBlockAtIndexZero(t *testing.T) {
@@ -141,6 +142,44 @@ func TestUnit_Thread_CompleteBlock(t *testing.T) {
 	}
 }
 
+func TestUnit_Thread_BlockTimestamps(t *testing.T) {
+	now := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	thread := &Thread{Clock: func() time.Time { return now }}
+	thread.InputFrom("alice", "Hi")
+	thread.Text("block_1", "Hello")
+
+	now = now.Add(2 * time.Second)
+	thread.Text("block_1", " there")
+	thread.Complete("block_1")
+	now = now.Add(time.Second)
+	thread.Complete("block_1")
+
+	input, text := thread.Blocks[0], thread.Blocks[1]
+	if input.Author != "alice" || !input.CreatedAt.Equal(input.CompletedAt) {
+		t.Errorf("unexpected input block %+v", input)
+	}
+	if want := now.Add(-3 * time.Second); !text.CreatedAt.Equal(want) {
+		t.Errorf("expected the text block created at %v, got %v", want, text.CreatedAt)
+	}
+	if want := now.Add(-time.Second); !text.CompletedAt.Equal(want) {
+		t.Errorf("expected the first completion time %v, got %v", want, text.CompletedAt)
+	}
+	if snapshot := thread.Snapshot(); !snapshot.CreatedAt.Equal(now) {
+		t.Errorf("expected the snapshot to use the clock, got %v", snapshot.CreatedAt)
+	}
+}
+
+func TestUnit_Thread_DescriptionPendingToolCall(t *testing.T) {
+	thread := &Thread{}
+	thread.ToolCall("call_1", "weather", `{"city":"Paris"}`)
+	if got := thread.Blocks[0].Description(); got != "-> weather\n<- (no result)" {
+		t.Errorf("unexpected description %q", got)
+	}
+	if got := (&ThreadBlock{Type: InferenceBlockWebSearch}).Description(); got != "" {
+		t.Errorf("expected an empty description for a search without details, got %q", got)
+	}
+}
+
 func TestUnit_Thread_WebSearchFlow(t *testing.T) {
 	thread := &Thread{Blocks: []*ThreadBlock{}}
 	thread.WebSearch("search_1")
@@ -343,7 +382,7 @@ func TestUnit_Thread_MutationMethodsSetUpdated(t *testing.T) {
 		{
 			name: "Cite",
 			mutate: func(thread *Thread) {
-				thread.Cite("id", "citation")
+				thread.Cite("id", ThreadCitation{Type: CitationURL, URL: "https://example.com"})
 			},
 		},
 		{
@@ -0,0 +1,184 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+)
+
+// EstimateTokens estimates the prompt size of the thread for model (the
+// thread's model when empty) without a request: the text of every block
+// through TokenizerFor, the tool definitions and output schema, and a
+// per-item cost for images, documents and audio.
+func (s *Thread) EstimateTokens(model string) int {
+	if model == "" {
+		model = s.Model
+	}
+	tokenizer := TokenizerFor(model)
+	// Every request has a few tokens of framing, and so does each message.
+	tokens := 3
+	if tools := s.toolDefinitions(); len(tools) > 0 {
+		data, _ := json.Marshal(tools)
+		tokens += tokenizer.Count(string(data))
+	}
+	if s.StructuredOutputSchema != nil {
+		data, _ := json.Marshal(s.StructuredOutputSchema)
+		tokens += tokenizer.Count(string(data))
+	}
+	for _, b := range s.Blocks {
+		tokens += estimateBlockTokens(tokenizer, b)
+	}
+	return tokens
+}
+
+func estimateBlockTokens(tokenizer Tokenizer, b *ThreadBlock) int {
+	tokens := 4 + tokenizer.Count(b.Text)
+	if b.ToolCall != nil {
+		tokens += tokenizer.Count(b.ToolCall.Name) + tokenizer.Count(b.ToolCall.Arguments)
+	}
+	if b.ToolResult != nil {
+		tokens += tokenizer.Count(b.ToolResult.Output)
+	}
+	if b.WebSearch != nil {
+		tokens += tokenizer.Count(b.WebSearch.Query)
+		if b.WebSearch.Results != nil {
+			tokens += tokenizer.Count(formatWebSearchResults(b.WebSearch.Results))
+		}
+	}
+	if b.WebFetch != nil {
+		tokens += tokenizer.Count(b.WebFetch.Content())
+	}
+	if b.ServerTool != nil {
+		tokens += tokenizer.Count(b.ServerTool.Input) + tokenizer.Count(b.ServerTool.Result)
+	}
+	switch {
+	case b.Image != nil && b.isInput():
+		tokens += estimateImageTokens(b.Image)
+	case b.Document != nil:
+		tokens += estimateDocumentTokens(tokenizer, b.Document)
+	case b.Audio != nil:
+		tokens += estimateAudioTokens(tokenizer, b.Audio)
+	}
+	return tokens
+}
+
+// estimateImageTokens uses OpenAI's high detail formula: the image is scaled
+// to fit 2048x2048, then its short side to 768, and costs 170 tokens per
+// 512px tile plus 85. Other providers are within the same range.
+func estimateImageTokens(img *ThreadImage) int {
+	data, err := base64.StdEncoding.DecodeString(img.Base64)
+	if err != nil {
+		return 765
+	}
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		// Formats without a decoder (webp) count as a 1024x1024 image.
+		return 765
+	}
+	w, h := float64(config.Width), float64(config.Height)
+	if scale := 2048 / math.Max(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	if scale := 768 / math.Min(w, h); scale < 1 {
+		w, h = w*scale, h*scale
+	}
+	tiles := math.Ceil(w/512) * math.Ceil(h/512)
+	return 85 + 170*int(tiles)
+}
+
+func estimateDocumentTokens(tokenizer Tokenizer, doc *ThreadDocument) int {
+	if text := doc.Text(); text != "" {
+		return tokenizer.Count(text)
+	}
+	// Scanned or unreadable PDFs are billed as page images.
+	return 1500
+}
+
+// estimateAudioTokens counts about 32 tokens per second of PCM or WAV
+// audio. Other formats fall back to the transcript, or a flat cost.
+func estimateAudioTokens(tokenizer Tokenizer, audio *ThreadAudio) int {
+	if audio.Transcript != "" {
+		return tokenizer.Count(audio.Transcript)
+	}
+	data, err := audio.Data()
+	if err != nil {
+		return 500
+	}
+	bytesPerSecond := 0
+	switch {
+	case audio.IsPCM():
+		bytesPerSecond = audio.Rate() * 2
+	case audio.IsWAV() && len(data) > 44:
+		bytesPerSecond = int(binary.LittleEndian.Uint32(data[28:32]))
+		data = data[44:]
+	}
+	if bytesPerSecond <= 0 {
+		return 500
+	}
+	return int(math.Ceil(float64(len(data)) / float64(bytesPerSecond) * 32))
+}
+
+// tokenCounter is implemented by adapters whose provider can count the
+// tokens of a prompt without running it. The count URL is derived from
+// BaseURL, so a custom Endpoint, which replaces the whole URL, is an error.
+type tokenCounter interface {
+	makeCountTokensRequest(thread *Thread) (*http.Request, error)
+	parseCountTokens(body []byte) (int64, error)
+}
+
+// CountTokens asks the provider how many input tokens the thread's next
+// request would use (Anthropic count_tokens, Gemini countTokens). Tools are
+// not run and nothing is added to the thread. Other providers return a
+// configuration error; use Thread.EstimateTokens for those.
+func (s *Session) CountTokens() (int64, error) {
+	counter, ok := s.Provider.(tokenCounter)
+	if !ok {
+		return 0, ConfigurationError(s.Provider.Name(), "token counting is not supported")
+	}
+	s.Thread.clientTools = clientWebTools(s.Provider, s.Thread)
+	s.Provider.InitSession(s.Thread)
+	s.Provider.PrepareForUpdates()
+	for _, block := range s.Thread.Blocks {
+		if block.Type == InferenceBlockThinking || block.Type == InferenceBlockEncryptedThinking {
+			if block.ProviderID != "" && block.ProviderID != s.Provider.Name() {
+				continue
+			}
+		}
+		s.Provider.Update(block.asToolCall())
+	}
+
+	req, err := counter.makeCountTokensRequest(s.Thread)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode >= 300 {
+		if parsedErr := s.Provider.ParseHttpError(resp.StatusCode, body); parsedErr != nil {
+			return 0, parsedErr
+		}
+		return 0, &AIError{
+			Category: AIErrorCategoryHTTPStatus,
+			Message:  fmt.Sprintf("Unhandled error. Received status code %d with body %s", resp.StatusCode, strings.TrimSpace(string(body))),
+			Provider: s.Provider.Name(),
+		}
+	}
+	return counter.parseCountTokens(body)
+}
@@ -0,0 +1,203 @@
+package aikit
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Tokenizer counts the tokens a model would see for a piece of text.
+type Tokenizer interface {
+	Count(text string) int
+}
+
+// BPETokenizer is a byte-level BPE encoder in the style of OpenAI's
+// tiktoken, loaded from a vocabulary file such as o200k_base.tiktoken.
+type BPETokenizer struct {
+	ranks map[string]int
+}
+
+// bpeSplit is the pre-tokenizer of cl100k_base and o200k_base without the
+// lookahead RE2 lacks; Encode handles trailing whitespace itself.
+var bpeSplit = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// NewBPETokenizer reads a tiktoken vocabulary: one base64 token and its rank
+// per line.
+func NewBPETokenizer(r io.Reader) (*BPETokenizer, error) {
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		token, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("vocabulary line %d: expected a token and a rank", line)
+		}
+		data, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary line %d: %w", line, err)
+		}
+		n, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("vocabulary line %d: %w", line, err)
+		}
+		ranks[string(data)] = n
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("empty vocabulary")
+	}
+	return &BPETokenizer{ranks: ranks}, nil
+}
+
+// LoadBPETokenizer reads a tiktoken vocabulary file.
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return NewBPETokenizer(f)
+}
+
+// Encode returns the token ranks of text.
+func (t *BPETokenizer) Encode(text string) []int {
+	tokens := []int{}
+	for _, piece := range bpePieces(text) {
+		if rank, ok := t.ranks[piece]; ok {
+			tokens = append(tokens, rank)
+			continue
+		}
+		tokens = append(tokens, t.merge(piece)...)
+	}
+	return tokens
+}
+
+func (t *BPETokenizer) Count(text string) int {
+	return len(t.Encode(text))
+}
+
+// bpePieces splits text the way tiktoken does before merging. A run of
+// whitespace followed by a word leaves its last character to that word.
+func bpePieces(text string) []string {
+	pieces := []string{}
+	for len(text) > 0 {
+		loc := bpeSplit.FindStringIndex(text)
+		if loc == nil {
+			pieces = append(pieces, text)
+			break
+		}
+		end := loc[1]
+		if piece := text[:end]; end < len(text) && strings.TrimSpace(piece) == "" && !strings.ContainsAny(piece, "\r\n") {
+			if next, _ := utf8.DecodeRuneInString(text[end:]); !unicode.IsSpace(next) {
+				_, last := utf8.DecodeLastRuneInString(piece)
+				if end-last > 0 {
+					end -= last
+				}
+			}
+		}
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
+
+// merge applies the BPE merges to one piece, always joining the adjacent
+// pair with the lowest rank.
+func (t *BPETokenizer) merge(piece string) []int {
+	parts := make([]string, len(piece))
+	for i := range piece {
+		parts[i] = piece[i : i+1]
+	}
+	for len(parts) > 1 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i < len(parts)-1; i++ {
+			if rank, ok := t.ranks[parts[i]+parts[i+1]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	tokens := make([]int, 0, len(parts))
+	for _, part := range parts {
+		if rank, ok := t.ranks[part]; ok {
+			tokens = append(tokens, rank)
+		} else {
+			// Bytes outside the vocabulary still cost a token each.
+			for range part {
+				tokens = append(tokens, -1)
+			}
+		}
+	}
+	return tokens
+}
+
+// HeuristicTokenizer estimates tokens without a vocabulary: about
+// CharsPerToken characters of Latin text per token, and one token for each
+// character of scripts without spaces such as Chinese or Japanese.
+type HeuristicTokenizer struct {
+	// CharsPerToken defaults to 4.
+	CharsPerToken float64
+}
+
+func (h HeuristicTokenizer) Count(text string) int {
+	perToken := h.CharsPerToken
+	if perToken <= 0 {
+		perToken = 4
+	}
+	latin, wide := 0, 0
+	for _, r := range text {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			wide++
+		} else {
+			latin++
+		}
+	}
+	return int(math.Ceil(float64(latin)/perToken)) + wide
+}
+
+var (
+	tokenizersMu sync.RWMutex
+	tokenizers   = map[string]Tokenizer{}
+)
+
+// RegisterTokenizer sets the tokenizer for models whose name starts with
+// prefix, e.g. "gpt-4o" or "claude-".
+func RegisterTokenizer(prefix string, tokenizer Tokenizer) {
+	tokenizersMu.Lock()
+	defer tokenizersMu.Unlock()
+	tokenizers[prefix] = tokenizer
+}
+
+// TokenizerFor returns the registered tokenizer with the longest prefix of
+// model, or a HeuristicTokenizer.
+func TokenizerFor(model string) Tokenizer {
+	tokenizersMu.RLock()
+	defer tokenizersMu.RUnlock()
+	var found Tokenizer = HeuristicTokenizer{}
+	longest := -1
+	for prefix, tokenizer := range tokenizers {
+		if strings.HasPrefix(model, prefix) && len(prefix) > longest {
+			found, longest = tokenizer, len(prefix)
+		}
+	}
+	return found
+}
@@ -0,0 +1,95 @@
+package aikit
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+// testVocabulary builds a tiktoken file with every byte plus a few merges.
+func testVocabulary() string {
+	var sb strings.Builder
+	rank := 0
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), rank)
+		rank++
+	}
+	for _, merge := range []string{"he", "ll", "llo", "hello", " w", " wor", "or", " world"} {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(merge)), rank)
+		rank++
+	}
+	return sb.String()
+}
+
+func TestUnit_Tokenizer_BPE(t *testing.T) {
+	tokenizer, err := NewBPETokenizer(strings.NewReader(testVocabulary()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pieces := bpePieces("hello  world!"); len(pieces) != 4 || pieces[1] != " " || pieces[2] != " world" {
+		t.Errorf("unexpected pieces %q", pieces)
+	}
+	// "hello" and " world" are single tokens, "!" is a byte.
+	if n := tokenizer.Count("hello world!"); n != 3 {
+		t.Errorf("expected 3 tokens, got %d (%v)", n, tokenizer.Encode("hello world!"))
+	}
+	// "hellp" merges to "he", "ll" and the bytes "p".
+	if tokens := tokenizer.Encode("hellp"); len(tokens) != 3 {
+		t.Errorf("unexpected merges %v", tokens)
+	}
+	if _, err := NewBPETokenizer(strings.NewReader("not-base64! 1\n")); err == nil {
+		t.Errorf("expected an error for a malformed vocabulary")
+	}
+
+	RegisterTokenizer("test-bpe", tokenizer)
+	defer RegisterTokenizer("test-bpe", HeuristicTokenizer{})
+	if TokenizerFor("test-bpe-mini") != Tokenizer(tokenizer) {
+		t.Errorf("expected the registered tokenizer for its prefix")
+	}
+	if _, ok := TokenizerFor("other-model").(HeuristicTokenizer); !ok {
+		t.Errorf("expected the heuristic for unknown models")
+	}
+}
+
+func TestUnit_Tokenizer_Heuristic(t *testing.T) {
+	h := HeuristicTokenizer{}
+	if n := h.Count("abcdefgh"); n != 2 {
+		t.Errorf("expected 2 tokens, got %d", n)
+	}
+	if n := h.Count("你好世界"); n != 4 {
+		t.Errorf("expected one token per Han character, got %d", n)
+	}
+}
+
+func TestUnit_Tokenizer_EstimateThread(t *testing.T) {
+	thread := &Thread{Model: "estimate-model"}
+	thread.Input(strings.Repeat("word ", 100))
+	base := thread.EstimateTokens("")
+	if base < 120 || base > 140 {
+		t.Errorf("unexpected text estimate %d", base)
+	}
+
+	thread.Tools = map[string]ToolDefinition{"weather": {Description: strings.Repeat("x", 400)}}
+	withTools := thread.EstimateTokens("")
+	if withTools-base < 100 {
+		t.Errorf("tools not counted: %d then %d", base, withTools)
+	}
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1024, 1024)))
+	thread.InputImageBase64(base64.StdEncoding.EncodeToString(buf.Bytes()), "image/png")
+	// 1024x1024 scales to 768x768: four tiles.
+	if got := thread.EstimateTokens("") - withTools; got != 4+85+4*170 {
+		t.Errorf("unexpected image estimate %d", got)
+	}
+
+	withImage := thread.EstimateTokens("")
+	thread.InputDocumentBase64(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("abcd", 50))), "text/plain", "notes.txt", false)
+	if got := thread.EstimateTokens("") - withImage; got != 4+50 {
+		t.Errorf("unexpected document estimate %d", got)
+	}
+}
@@ -3,6 +3,7 @@ package aikit
 import (
 	_ "embed"
 	"encoding/json"
+	"encoding/xml"
 	"os"
 )
 
@@ -29,6 +30,27 @@ type JsonSchema struct {
 
 type ToolJsonSchema = JsonSchema
 
+// MarshalXML writes the schema as JSON text, since its properties are a map
+// that encoding/xml can't represent.
+func (j JsonSchema) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	type plain JsonSchema
+	data, err := json.Marshal(plain(j))
+	if err != nil {
+		return err
+	}
+	return e.EncodeElement(string(data), start)
+}
+
+// UnmarshalXML reads a schema written by MarshalXML.
+func (j *JsonSchema) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var text string
+	if err := d.DecodeElement(&text, &start); err != nil {
+		return err
+	}
+	type plain JsonSchema
+	return json.Unmarshal([]byte(text), (*plain)(j))
+}
+
 func GetTools(filename string) map[string]JsonSchema {
 	var defs map[string]JsonSchema
 	bytes, err := os.ReadFile(filename)
@@ -0,0 +1,416 @@
+package aikit
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Names of the function tools used for client-side web search and fetch.
+const (
+	WebSearchToolName = "web_search"
+	WebFetchToolName  = "web_fetch"
+)
+
+// WebSearcher runs web searches for providers without a hosted search tool.
+// Set Thread.WebSearcher to back search with your own index.
+type WebSearcher interface {
+	Search(query string) ([]ThreadWebSearchResult, error)
+}
+
+// WebSearchFunc adapts a plain function to WebSearcher.
+type WebSearchFunc func(query string) ([]ThreadWebSearchResult, error)
+
+func (f WebSearchFunc) Search(query string) ([]ThreadWebSearchResult, error) {
+	return f(query)
+}
+
+// WebFetcher retrieves web pages for providers without a hosted fetch tool.
+type WebFetcher interface {
+	Fetch(url string) (*ThreadWebFetch, error)
+}
+
+// WebFetchFunc adapts a plain function to WebFetcher.
+type WebFetchFunc func(url string) (*ThreadWebFetch, error)
+
+func (f WebFetchFunc) Fetch(url string) (*ThreadWebFetch, error) {
+	return f(url)
+}
+
+// hostedWebTools is implemented by adapters whose provider runs web search
+// or web fetch itself. Providers without it get the client-side tools.
+type hostedWebTools interface {
+	hostsWebSearch() bool
+	hostsWebFetch() bool
+}
+
+// clientWebTools returns the function tools to expose for the thread's
+// WebSearcher and WebFetcher when the provider has no hosted equivalent.
+// Tools the caller defined with the same name take precedence.
+func clientWebTools(provider APIRequest, thread *Thread) map[string]ToolDefinition {
+	hostedSearch, hostedFetch := false, false
+	if hosted, ok := provider.(hostedWebTools); ok {
+		hostedSearch, hostedFetch = hosted.hostsWebSearch(), hosted.hostsWebFetch()
+	}
+	tools := map[string]ToolDefinition{}
+	if thread.MaxWebSearches > 0 && thread.WebSearcher != nil && !hostedSearch {
+		tools[WebSearchToolName] = ToolDefinition{
+			Description: "Search the web. Returns a list of result titles, URLs and snippets.",
+			Parameters: &JsonSchema{
+				Type: "object",
+				Properties: &map[string]*JsonSchema{
+					"query": {Type: "string", Description: "The search query."},
+				},
+				Required: []string{"query"},
+			},
+		}
+	}
+	if thread.WebFetchEnabled && thread.WebFetcher != nil && !hostedFetch {
+		tools[WebFetchToolName] = ToolDefinition{
+			Description: "Fetch a web page and return its text content.",
+			Parameters: &JsonSchema{
+				Type: "object",
+				Properties: &map[string]*JsonSchema{
+					"url": {Type: "string", Description: "The absolute http or https URL to fetch."},
+				},
+				Required: []string{"url"},
+			},
+		}
+	}
+	for name := range thread.Tools {
+		delete(tools, name)
+	}
+	return tools
+}
+
+// runWebTool executes a client-side web tool call and turns the block into a
+// web_search or view_webpage block. The tool call and its result are kept on
+// the block so it is still replayed as a function call. It returns false if
+// the call is not for a client-side web tool.
+func (s *Thread) runWebTool(block *ThreadBlock) bool {
+	if _, ok := s.clientTools[block.ToolCall.Name]; !ok {
+		return false
+	}
+	var args struct {
+		Query string `json:"query"`
+		URL   string `json:"url"`
+	}
+	_ = json.Unmarshal([]byte(block.ToolCall.Arguments), &args)
+
+	var output string
+	switch block.ToolCall.Name {
+	case WebSearchToolName:
+		block.Type = InferenceBlockWebSearch
+		if s.clientSearches >= s.MaxWebSearches {
+			// Searches over the limit are not run and not counted.
+			block.WebSearch = &ThreadWebSearch{
+				Query:     args.Query,
+				Results:   []ThreadWebSearchResult{},
+				ErrorCode: "max_uses_exceeded",
+			}
+			output = fmt.Sprintf("Search limit of %d reached. Answer with the information you have.", s.MaxWebSearches)
+			break
+		}
+		s.clientSearches++
+		s.WebSearchQuery(block.ID, args.Query)
+		block.WebSearch.Results = []ThreadWebSearchResult{}
+		results, err := s.WebSearcher.Search(args.Query)
+		if err != nil {
+			block.WebSearch.ErrorCode = "unavailable"
+			output = "Search failed: " + err.Error()
+			break
+		}
+		block.WebSearch.Results = results
+		output = formatWebSearchResults(results)
+	case WebFetchToolName:
+		block.Type = InferenceBlockViewWebpage
+		s.ViewWebpageUrl(block.ID, args.URL)
+		fetch, err := s.WebFetcher.Fetch(args.URL)
+		if err != nil {
+			s.WebFetchResult(block.ID, ThreadWebFetch{URL: args.URL, ErrorCode: "url_not_accessible"})
+			output = "Fetch failed: " + err.Error()
+			break
+		}
+		if fetch.URL == "" {
+			fetch.URL = args.URL
+		}
+		s.WebFetchResult(block.ID, *fetch)
+		output = formatWebFetch(fetch)
+	}
+	block.ToolResult = &ThreadToolResult{
+		ToolCallID: block.ToolCall.ID,
+		Output:     output,
+	}
+	s.complete(block)
+	s.updated = true
+	return true
+}
+
+// asToolCall returns how a block is sent to providers: client-side web tool
+// calls are replayed as the function calls they were, anything else as is.
+func (b *ThreadBlock) asToolCall() *ThreadBlock {
+	if b.ToolCall == nil || b.Type == InferenceBlockToolCall {
+		return b
+	}
+	call := *b
+	call.Type = InferenceBlockToolCall
+	return &call
+}
+
+func formatWebSearchResults(results []ThreadWebSearchResult) string {
+	if len(results) == 0 {
+		return "No results found."
+	}
+	var sb strings.Builder
+	for i, r := range results {
+		fmt.Fprintf(&sb, "%d. %s\n   %s\n", i+1, r.Title, r.URL)
+		if r.PageAge != "" {
+			fmt.Fprintf(&sb, "   %s\n", r.PageAge)
+		}
+		if r.Snippet != "" {
+			fmt.Fprintf(&sb, "   %s\n", r.Snippet)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func formatWebFetch(fetch *ThreadWebFetch) string {
+	var sb strings.Builder
+	if title := fetch.Title(); title != "" {
+		fmt.Fprintf(&sb, "Title: %s\n", title)
+	}
+	fmt.Fprintf(&sb, "URL: %s\n\n", fetch.URL)
+	sb.WriteString(fetch.Content())
+	return sb.String()
+}
+
+// htmlFetchClient is used by an HTMLFetcher without a Client. Pages are
+// fetched on behalf of a model, so a stalled server must not hold up the
+// session, and the model must not reach hosts on the local network. The
+// address is checked after DNS resolution, for redirects too, and proxies
+// are not used.
+var htmlFetchClient = func() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	dialer := &net.Dialer{Timeout: 30 * time.Second, Control: publicAddressOnly}
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Transport: transport, Timeout: 30 * time.Second}
+}()
+
+// publicAddressOnly is a net.Dialer Control function that refuses loopback,
+// private, link-local, multicast and unspecified addresses.
+func publicAddressOnly(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("web fetch: %s is not a public address", ip)
+	}
+	return nil
+}
+
+// HTMLFetcher is a WebFetcher that downloads a page over HTTP and converts
+// HTML to plain text. Plain text and PDF responses are kept as they are.
+//
+// The model chooses the URLs, and the pages it reads can steer it, so by
+// default only public addresses are fetched: loopback, private and
+// link-local hosts such as cloud metadata endpoints are refused. Set Client
+// to fetch internal hosts.
+type HTMLFetcher struct {
+	// Client defaults to a client with a 30 second timeout that only
+	// connects to public addresses.
+	Client    *http.Client
+	UserAgent string
+	// MaxBytes limits how much of the response body is read. Defaults to
+	// 5MB.
+	MaxBytes int64
+	// MaxChars truncates the extracted text. Zero keeps all of it.
+	MaxChars int
+}
+
+func (f *HTMLFetcher) Fetch(rawURL string) (*ThreadWebFetch, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid url %q", rawURL)
+	}
+	req, err := http.NewRequest("GET", rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,application/pdf;q=0.8")
+	client := f.Client
+	if client == nil {
+		client = htmlFetchClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetching %s: status %d", rawURL, resp.StatusCode)
+	}
+	maxBytes := f.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 5 << 20
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	page := &ThreadDocument{MediaType: "text/plain"}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		title, text := HTMLToText(string(body))
+		page.Title = title
+		page.Base64 = base64.StdEncoding.EncodeToString([]byte(f.truncate(text)))
+	case strings.HasPrefix(mediaType, "text/"):
+		page.Base64 = base64.StdEncoding.EncodeToString([]byte(f.truncate(string(body))))
+	case mediaType == "application/pdf":
+		page.MediaType = mediaType
+		page.Base64 = base64.StdEncoding.EncodeToString(body)
+	default:
+		return nil, fmt.Errorf("fetching %s: unsupported content type %q", rawURL, mediaType)
+	}
+	return &ThreadWebFetch{
+		URL:         resp.Request.URL.String(),
+		RetrievedAt: time.Now().UTC().Format(time.RFC3339),
+		Page:        page,
+	}, nil
+}
+
+func (f *HTMLFetcher) truncate(text string) string {
+	if f.MaxChars <= 0 {
+		return text
+	}
+	runes := []rune(text)
+	if len(runes) <= f.MaxChars {
+		return text
+	}
+	return string(runes[:f.MaxChars])
+}
+
+// htmlSkippedElements have content that is never shown as text.
+var htmlSkippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "head": true, "iframe": true, "object": true,
+}
+
+// htmlBlockElements start a new line.
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "br": true, "hr": true, "li": true, "ul": true,
+	"ol": true, "dl": true, "dt": true, "dd": true, "table": true, "tr": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"section": true, "article": true, "header": true, "footer": true,
+	"nav": true, "aside": true, "main": true, "blockquote": true, "pre": true,
+	"figure": true, "figcaption": true, "form": true, "address": true,
+}
+
+// HTMLToText extracts the title and the readable text of an HTML page.
+// Scripts, styles and other non-content elements are dropped, block
+// elements become line breaks and list items are prefixed with "- ".
+func HTMLToText(page string) (title string, text string) {
+	var out strings.Builder
+	var titleText strings.Builder
+	skip := ""
+	inTitle := false
+	for i := 0; i < len(page); {
+		if page[i] != '<' {
+			end := strings.IndexByte(page[i:], '<')
+			if end < 0 {
+				end = len(page) - i
+			}
+			chunk := page[i : i+end]
+			switch {
+			case inTitle:
+				titleText.WriteString(chunk)
+			case skip == "":
+				// Source line breaks are whitespace; only block
+				// elements break lines.
+				out.WriteString(strings.ReplaceAll(chunk, "\n", " "))
+			}
+			i += end
+			continue
+		}
+		if strings.HasPrefix(page[i:], "<!--") {
+			end := strings.Index(page[i+4:], "-->")
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+		end := strings.IndexByte(page[i:], '>')
+		if end < 0 {
+			break
+		}
+		tag := page[i+1 : i+end]
+		i += end + 1
+
+		closing := strings.HasPrefix(tag, "/")
+		name := strings.TrimPrefix(tag, "/")
+		if idx := strings.IndexAny(name, " \t\r\n/"); idx >= 0 {
+			name = name[:idx]
+		}
+		name = strings.ToLower(name)
+
+		if name == "title" {
+			inTitle = !closing
+			continue
+		}
+		if skip != "" {
+			if closing && name == skip {
+				skip = ""
+			}
+			continue
+		}
+		if !closing && htmlSkippedElements[name] && !strings.HasSuffix(tag, "/") {
+			skip = name
+			continue
+		}
+		if htmlBlockElements[name] {
+			out.WriteString("\n")
+			if name == "li" && !closing {
+				out.WriteString("- ")
+			}
+		} else if name == "td" || name == "th" {
+			out.WriteString(" ")
+		}
+	}
+
+	title = strings.Join(strings.Fields(html.UnescapeString(titleText.String())), " ")
+	var lines []string
+	for _, line := range strings.Split(html.UnescapeString(out.String()), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" && line != "-" {
+			lines = append(lines, line)
+		}
+	}
+	return title, strings.Join(lines, "\n")
+}
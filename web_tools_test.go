@@ -0,0 +1,173 @@
+package aikit
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUnit_WebTools_HTMLToText(t *testing.T) {
+	page := `<!DOCTYPE html><html><head><title> Paris &amp; weather </title>
+<style>body { color: red }</style><script>var x = "<p>no</p>";</script></head>
+<body><!-- nav --><h1>Forecast</h1><p>Sunny,
+   <b>22&deg;C</b>.</p><ul><li>Mon</li><li>Tue</li></ul><br/>Bye</body></html>`
+	title, text := HTMLToText(page)
+	if title != "Paris & weather" {
+		t.Errorf("unexpected title %q", title)
+	}
+	want := "Forecast\nSunny, 22°C.\n- Mon\n- Tue\nBye"
+	if text != want {
+		t.Errorf("got %q, want %q", text, want)
+	}
+}
+
+func TestUnit_WebTools_HTMLFetcherLimits(t *testing.T) {
+	release := make(chan struct{})
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, strings.Repeat("a", 100))
+	}))
+	defer site.Close()
+	defer close(release)
+
+	if htmlFetchClient.Timeout == 0 {
+		t.Errorf("expected the default client to have a timeout")
+	}
+	// The test server is on loopback, which the default client refuses.
+	if _, err := (&HTMLFetcher{}).Fetch(site.URL + "/big"); err == nil || !strings.Contains(err.Error(), "not a public address") {
+		t.Errorf("expected a loopback fetch to be refused, got %v", err)
+	}
+	for _, addr := range []string{"169.254.169.254:80", "10.0.0.1:443", "[::1]:80", "[::ffff:192.168.1.1]:80", "0.0.0.0:80"} {
+		if publicAddressOnly("tcp", addr, nil) == nil {
+			t.Errorf("expected %s to be refused", addr)
+		}
+	}
+	if err := publicAddressOnly("tcp", "93.184.215.14:443", nil); err != nil {
+		t.Errorf("expected a public address to be allowed, got %v", err)
+	}
+
+	fetch, err := (&HTMLFetcher{Client: site.Client(), MaxBytes: 10}).Fetch(site.URL + "/big")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetch.Content() != strings.Repeat("a", 10) {
+		t.Errorf("expected the body to be cut at MaxBytes, got %q", fetch.Content())
+	}
+	fetcher := &HTMLFetcher{Client: &http.Client{Timeout: 50 * time.Millisecond}}
+	if _, err := fetcher.Fetch(site.URL + "/slow"); err == nil {
+		t.Errorf("expected a stalled fetch to time out")
+	}
+}
+
+func TestUnit_WebTools_ClientSideToolsOnCompletions(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, `<html><head><title>Paris</title></head><body><p>Sunny today.</p></body></html>`)
+	}))
+	defer site.Close()
+
+	var requests []map[string]any
+	turns := []string{
+		sseBody(
+			`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"call_s","type":"function","function":{"name":"web_search","arguments":"{\"query\":\"paris weather\"}"}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_f","type":"function","function":{"name":"web_fetch","arguments":"{\"url\":\"`+site.URL+`/paris\"}"}}]}}]}`,
+			`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":2,"id":"call_s2","type":"function","function":{"name":"web_search","arguments":"{\"query\":\"again\"}"}}]},"finish_reason":"tool_calls"}]}`,
+			`[DONE]`,
+		),
+		sseBody(
+			`{"id":"c2","choices":[{"index":0,"delta":{"role":"assistant","content":"Sunny."},"finish_reason":"stop"}]}`,
+			`[DONE]`,
+		),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]any
+		json.NewDecoder(r.Body).Decode(&req)
+		requests = append(requests, req)
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, turns[len(requests)-1])
+	}))
+	defer server.Close()
+
+	config := OpenAICompletionsProvider("key")
+	config.BaseURL = server.URL
+	session := config.Session()
+	session.Thread.Model = "test-model"
+	session.Thread.MaxWebSearches = 1
+	session.Thread.WebFetchEnabled = true
+	session.Thread.WebSearcher = WebSearchFunc(func(query string) ([]ThreadWebSearchResult, error) {
+		return []ThreadWebSearchResult{{Title: "Paris forecast", URL: site.URL + "/paris", Snippet: "Sunny"}}, nil
+	})
+	session.Thread.WebFetcher = &HTMLFetcher{Client: site.Client()}
+	session.Thread.HandleToolFunction = func(name string, args string) string {
+		t.Fatalf("web tool %s was passed to HandleToolFunction", name)
+		return ""
+	}
+	session.Thread.Input("Weather in Paris?")
+	result := session.Stream(func(*Thread) {})
+	if !result.Success {
+		t.Fatalf("stream failed: %s", result.Error)
+	}
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(requests))
+	}
+
+	var toolNames []string
+	for _, tool := range requests[0]["tools"].([]any) {
+		toolNames = append(toolNames, tool.(map[string]any)["function"].(map[string]any)["name"].(string))
+	}
+	if len(toolNames) != 2 || !strings.Contains(strings.Join(toolNames, ","), WebSearchToolName) || !strings.Contains(strings.Join(toolNames, ","), WebFetchToolName) {
+		t.Errorf("expected the client web tools, got %v", toolNames)
+	}
+
+	if result.Result.WebSearches != 1 || result.Result.PageViews != 1 {
+		t.Errorf("expected 1 search and 1 page view, got %+v", result.Result)
+	}
+	search, fetch, limited := result.Blocks[1], result.Blocks[2], result.Blocks[3]
+	if search.Type != InferenceBlockWebSearch || search.WebSearch.Query != "paris weather" || len(search.WebSearch.Results) != 1 {
+		t.Errorf("unexpected search block %+v", search)
+	}
+	if fetch.Type != InferenceBlockViewWebpage || fetch.WebFetch == nil || fetch.WebFetch.Title() != "Paris" || fetch.WebFetch.Content() != "Sunny today." {
+		t.Errorf("unexpected fetch block %+v", fetch)
+	}
+	if limited.Type != InferenceBlockWebSearch || limited.WebSearch.ErrorCode != "max_uses_exceeded" {
+		t.Errorf("expected the second search to hit the limit, got %+v", limited.WebSearch)
+	}
+
+	// The calls are replayed as function calls with their results.
+	messages := requests[1]["messages"].([]any)
+	assistant := messages[1].(map[string]any)
+	if calls := assistant["tool_calls"].([]any); len(calls) != 3 {
+		t.Fatalf("expected 3 replayed tool calls, got %v", assistant)
+	}
+	tool := messages[2].(map[string]any)
+	if tool["role"] != "tool" || tool["tool_call_id"] != "call_s" || !strings.Contains(tool["content"].(string), "Paris forecast") {
+		t.Errorf("unexpected search result message %v", tool)
+	}
+	if tool := messages[3].(map[string]any); !strings.Contains(tool["content"].(string), "Sunny today.") {
+		t.Errorf("unexpected fetch result message %v", tool)
+	}
+}
+
+func TestUnit_WebTools_HostedToolsTakePrecedence(t *testing.T) {
+	thread := &Thread{
+		MaxWebSearches:  2,
+		WebFetchEnabled: true,
+		WebSearcher:     WebSearchFunc(func(string) ([]ThreadWebSearchResult, error) { return nil, nil }),
+		WebFetcher:      &HTMLFetcher{},
+	}
+	anthropic := AnthropicProvider("key")
+	if tools := clientWebTools(anthropic.Session().Provider, thread); len(tools) != 0 {
+		t.Errorf("expected no client tools with hosted search and fetch, got %v", tools)
+	}
+	groq := GroqProvider("key")
+	if tools := clientWebTools(groq.Session().Provider, thread); len(tools) != 2 {
+		t.Errorf("expected both client tools, got %v", tools)
+	}
+}